@@ -7,6 +7,9 @@ import (
 	"path/filepath"
 
 	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/fsutil"
+	"github.com/KakkoiDev/aidb/internal/journal"
+	"github.com/KakkoiDev/aidb/internal/metadata"
 	"github.com/spf13/cobra"
 )
 
@@ -19,8 +22,9 @@ Examples:
   aidb add TASK.md
   aidb add *.md
   aidb add docs/`,
-	Args: cobra.MinimumNArgs(1),
-	RunE: runAdd,
+	Args:        cobra.MinimumNArgs(1),
+	RunE:        runAdd,
+	Annotations: writesDB,
 }
 
 func init() {
@@ -64,18 +68,46 @@ func runAdd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create storage dir: %w", err)
 	}
 
-	// Process each file
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata: %w", err)
+	}
+
+	j, err := journal.Begin(cfg.DBDir, "add", args)
+	if err != nil {
+		return fmt.Errorf("failed to start journal: %w", err)
+	}
+
+	// Process each file. Files that cannot be added are skipped, but a
+	// failure after something was moved rolls back the whole batch.
 	for _, srcPath := range files {
-		if err := addFile(cfg, srcPath, storageDir, cwd); err != nil {
-			printError(fmt.Sprintf("%s: %v", filepath.Base(srcPath), err))
-			continue
+		steps := j.Len()
+		if err := addFile(cfg, meta, j, srcPath, storageDir, cwd); err != nil {
+			if j.Len() == steps {
+				printError(fmt.Sprintf("%s: %v", filepath.Base(srcPath), err))
+				continue
+			}
+			rollbackJournal(cfg, j)
+			return fmt.Errorf("%s: %w (all files of this add were restored)", filepath.Base(srcPath), err)
 		}
 	}
 
+	if err := meta.Save(); err != nil {
+		printWarning(fmt.Sprintf("failed to record link locations: %v", err))
+	}
+	if err := j.Commit(); err != nil {
+		printWarning(fmt.Sprintf("failed to finish journal: %v", err))
+	}
+
+	// Remember the checkout for aidb link --all
+	if root := checkoutRoot(cwd); root != "" {
+		recordCheckout(cfg, root)
+	}
+
 	return nil
 }
 
-func addFile(cfg *config.Config, srcPath, storageDir, cwd string) error {
+func addFile(cfg *config.Config, meta *metadata.Metadata, j *journal.Entry, srcPath, storageDir, cwd string) error {
 	info, err := os.Lstat(srcPath)
 	if err != nil {
 		return fmt.Errorf("file not found")
@@ -106,7 +138,7 @@ func addFile(cfg *config.Config, srcPath, storageDir, cwd string) error {
 
 	// Handle directory
 	if info.IsDir() {
-		return addDirectory(cfg, srcPath, dstPath)
+		return addDirectory(cfg, meta, j, srcPath, dstPath)
 	}
 
 	// Check if destination already exists
@@ -114,15 +146,20 @@ func addFile(cfg *config.Config, srcPath, storageDir, cwd string) error {
 		return fmt.Errorf("already exists in database")
 	}
 
-	// Move file to storage
-	if err := os.Rename(srcPath, dstPath); err != nil {
+	// Move file to storage (copies across filesystems); the journal
+	// moves it back if anything below fails
+	if err := j.Move(srcPath, dstPath); err != nil {
+		return fmt.Errorf("failed to journal: %w", err)
+	}
+	if err := fsutil.Move(srcPath, dstPath); err != nil {
 		return fmt.Errorf("failed to move: %w", err)
 	}
 
 	// Create symlink back
+	if err := j.Symlink(srcPath, dstPath); err != nil {
+		return fmt.Errorf("failed to journal: %w", err)
+	}
 	if err := os.Symlink(dstPath, srcPath); err != nil {
-		// Rollback: move file back
-		os.Rename(dstPath, srcPath)
 		return fmt.Errorf("failed to create symlink: %w", err)
 	}
 
@@ -132,11 +169,22 @@ func addFile(cfg *config.Config, srcPath, storageDir, cwd string) error {
 		printWarning(fmt.Sprintf("%s: git add failed", relPath))
 	}
 
+	if dbRel, err := filepath.Rel(cfg.DBDir, dstPath); err == nil {
+		if err := j.Meta(dbRel, meta.GetInfo(dbRel)); err != nil {
+			return fmt.Errorf("failed to journal: %w", err)
+		}
+		meta.AddLink(dbRel, hostID(), srcPath)
+		updateIndex(cfg, dbRel)
+	}
+
 	printSuccess(fmt.Sprintf("Added %s", relPath))
 	return nil
 }
 
-func addDirectory(cfg *config.Config, srcDir, dstDir string) error {
+func addDirectory(cfg *config.Config, meta *metadata.Metadata, j *journal.Entry, srcDir, dstDir string) error {
+	var added []string
+	defer func() { updateIndex(cfg, added...) }()
+
 	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
@@ -154,13 +202,18 @@ func addDirectory(cfg *config.Config, srcDir, dstDir string) error {
 		}
 
 		// Move file
-		if err := os.Rename(path, dstPath); err != nil {
+		if err := j.Move(path, dstPath); err != nil {
+			return err
+		}
+		if err := fsutil.Move(path, dstPath); err != nil {
 			return err
 		}
 
 		// Create symlink back
+		if err := j.Symlink(path, dstPath); err != nil {
+			return err
+		}
 		if err := os.Symlink(dstPath, path); err != nil {
-			os.Rename(dstPath, path)
 			return err
 		}
 
@@ -168,6 +221,14 @@ func addDirectory(cfg *config.Config, srcDir, dstDir string) error {
 		gitCmd := exec.Command("git", "-C", cfg.DBDir, "add", dstPath)
 		gitCmd.Run()
 
+		if dbRel, err := filepath.Rel(cfg.DBDir, dstPath); err == nil {
+			if err := j.Meta(dbRel, meta.GetInfo(dbRel)); err != nil {
+				return err
+			}
+			meta.AddLink(dbRel, hostID(), path)
+			added = append(added, dbRel)
+		}
+
 		printSuccess(fmt.Sprintf("Added %s", relPath))
 		return nil
 	})
@@ -5,28 +5,35 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
-	"text/template"
+	"strings"
 	"time"
 
 	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/scheduler"
 	"github.com/spf13/cobra"
 )
 
 var backupCmd = &cobra.Command{
 	Use:   "backup <enable|disable|status>",
 	Short: "Manage automatic backup",
-	Long: `Enable or disable automatic hourly backup (commit + push).
+	Long: `Enable or disable automatic periodic backup (commit + push).
+
+Uses launchd on macOS, a systemd --user timer on Linux when available, and
+a crontab entry otherwise. Set backup.scheduler to force one.
 
 Examples:
-  aidb backup enable   # Enable hourly backup
-  aidb backup disable  # Disable backup
-  aidb backup status   # Show backup configuration`,
+  aidb backup enable                 # Enable backup every backup.interval (1h)
+  aidb backup enable --interval 30m  # Enable backup every 30 minutes
+  aidb backup disable                # Disable backup
+  aidb backup status                 # Show scheduler state and last run`,
 	Args: cobra.ExactArgs(1),
 	RunE: runBackup,
 }
 
+var backupIntervalFlag string
+
 func init() {
+	backupCmd.Flags().StringVar(&backupIntervalFlag, "interval", "", "Time between backups, e.g. 30m or 2h (default: backup.interval)")
 	rootCmd.AddCommand(backupCmd)
 }
 
@@ -45,139 +52,163 @@ func runBackup(cmd *cobra.Command, args []string) error {
 	}
 }
 
-func enableBackup() error {
-	if runtime.GOOS != "darwin" {
-		return fmt.Errorf("automatic backup only supported on macOS (launchd)")
-	}
-
-	cfg, err := config.New()
-	if err != nil {
-		return err
-	}
-
+// backupJob describes the periodic backup-run invocation
+func backupJob(cfg *config.Config, interval time.Duration) (scheduler.Job, error) {
 	// Find aidb binary
 	aidbPath, err := os.Executable()
 	if err != nil {
-		return fmt.Errorf("failed to find aidb binary: %w", err)
+		return scheduler.Job{}, fmt.Errorf("failed to find aidb binary: %w", err)
 	}
+	return scheduler.Job{
+		Label:    "com.aidb.backup",
+		Command:  []string{aidbPath, "backup-run"},
+		Interval: interval,
+		LogPath:  filepath.Join(cfg.DBDir, "backup.log"),
+		HomeDir:  cfg.HomeDir,
+	}, nil
+}
 
-	// Create LaunchAgent plist
-	plistPath := filepath.Join(cfg.HomeDir, "Library", "LaunchAgents", "com.aidb.backup.plist")
-
-	plistTemplate := `<?xml version="1.0" encoding="UTF-8"?>
-<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
-<plist version="1.0">
-<dict>
-    <key>Label</key>
-    <string>com.aidb.backup</string>
-    <key>ProgramArguments</key>
-    <array>
-        <string>{{.AidbPath}}</string>
-        <string>backup-run</string>
-    </array>
-    <key>StartInterval</key>
-    <integer>3600</integer>
-    <key>RunAtLoad</key>
-    <true/>
-    <key>StandardOutPath</key>
-    <string>{{.LogPath}}</string>
-    <key>StandardErrorPath</key>
-    <string>{{.LogPath}}</string>
-</dict>
-</plist>
-`
+// backupInterval returns --interval if given, else the backup.interval setting
+func backupInterval(cfg *config.Config) (time.Duration, error) {
+	value := cfg.Settings.Backup.Interval
+	if backupIntervalFlag != "" {
+		value = backupIntervalFlag
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval: %s", value)
+	}
+	if interval < time.Minute {
+		return 0, fmt.Errorf("interval must be at least 1m: %s", value)
+	}
+	return interval, nil
+}
 
-	tmpl, err := template.New("plist").Parse(plistTemplate)
+func enableBackup() error {
+	cfg, err := config.New()
 	if err != nil {
 		return err
 	}
 
-	// Ensure LaunchAgents directory exists
-	if err := os.MkdirAll(filepath.Dir(plistPath), 0755); err != nil {
+	interval, err := backupInterval(cfg)
+	if err != nil {
 		return err
 	}
-
-	f, err := os.Create(plistPath)
+	job, err := backupJob(cfg, interval)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	logPath := filepath.Join(cfg.HomeDir, ".aidb", "backup.log")
-	if err := tmpl.Execute(f, map[string]string{
-		"AidbPath": aidbPath,
-		"LogPath":  logPath,
-	}); err != nil {
+	sched, err := scheduler.New(cfg.Settings.Backup.Scheduler, nil)
+	if err != nil {
 		return err
 	}
-
-	// Load the launch agent
-	exec.Command("launchctl", "unload", plistPath).Run() // Ignore error if not loaded
-	if err := exec.Command("launchctl", "load", plistPath).Run(); err != nil {
-		return fmt.Errorf("failed to load launch agent: %w", err)
+	if err := sched.Enable(job); err != nil {
+		return err
 	}
+	saveBackupEnabled(true)
 
-	printSuccess("Backup enabled (hourly commit + push)")
-	printInfo(fmt.Sprintf("Log file: %s", logPath))
+	printSuccess(fmt.Sprintf("Backup enabled via %s (commit + push every %s)", sched.Name(), interval))
+	printInfo(fmt.Sprintf("Log file: %s", job.LogPath))
 	return nil
 }
 
 func disableBackup() error {
-	if runtime.GOOS != "darwin" {
-		return fmt.Errorf("automatic backup only supported on macOS (launchd)")
-	}
-
 	cfg, err := config.New()
 	if err != nil {
 		return err
 	}
 
-	plistPath := filepath.Join(cfg.HomeDir, "Library", "LaunchAgents", "com.aidb.backup.plist")
+	job, err := backupJob(cfg, 0)
+	if err != nil {
+		return err
+	}
 
-	// Unload and remove
-	exec.Command("launchctl", "unload", plistPath).Run()
-	if err := os.Remove(plistPath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove plist: %w", err)
+	sched, err := scheduler.New(cfg.Settings.Backup.Scheduler, nil)
+	if err != nil {
+		return err
+	}
+	if err := sched.Disable(job); err != nil {
+		return err
 	}
+	saveBackupEnabled(false)
 
 	printSuccess("Backup disabled")
 	return nil
 }
 
 func backupStatus() error {
-	if runtime.GOOS != "darwin" {
-		printInfo("Automatic backup only supported on macOS")
-		return nil
+	cfg, err := config.New()
+	if err != nil {
+		return err
 	}
 
-	cfg, err := config.New()
+	job, err := backupJob(cfg, 0)
 	if err != nil {
 		return err
 	}
 
-	plistPath := filepath.Join(cfg.HomeDir, "Library", "LaunchAgents", "com.aidb.backup.plist")
+	sched, err := scheduler.New(cfg.Settings.Backup.Scheduler, nil)
+	if err != nil {
+		return err
+	}
+	status, err := sched.Status(job)
+	if err != nil {
+		return err
+	}
 
-	if _, err := os.Stat(plistPath); os.IsNotExist(err) {
-		printInfo("Backup is disabled")
+	if !status.Installed {
+		printInfo(fmt.Sprintf("Backup is disabled (%s)", sched.Name()))
 		return nil
 	}
-
-	// Check if loaded
-	out, _ := exec.Command("launchctl", "list", "com.aidb.backup").Output()
-	if len(out) > 0 {
-		printSuccess("Backup is enabled and running")
+	if status.Active {
+		printSuccess(fmt.Sprintf("Backup is enabled and running (%s)", sched.Name()))
 	} else {
-		printWarning("Backup plist exists but not loaded")
+		printWarning(fmt.Sprintf("Backup is installed but not loaded (%s: %s)", sched.Name(), status.Detail))
 	}
 
-	logPath := filepath.Join(cfg.HomeDir, ".aidb", "backup.log")
-	if info, err := os.Stat(logPath); err == nil {
-		printInfo(fmt.Sprintf("Last log update: %s", info.ModTime().Format(time.RFC3339)))
+	if data, err := os.ReadFile(job.LogPath); err == nil {
+		if at, result, ok := parseLastRun(string(data)); ok {
+			printInfo(fmt.Sprintf("Last run: %s (%s)", at.Format(time.RFC3339), result))
+		}
 	}
 
 	return nil
 }
 
+// saveBackupEnabled records backup.enabled in the config file
+func saveBackupEnabled(enabled bool) {
+	userCfg, err := config.LoadFile()
+	if err != nil {
+		return
+	}
+	userCfg.Backup.Enabled = enabled
+	if err := config.SaveFile(userCfg); err != nil {
+		printWarning(fmt.Sprintf("failed to save config: %v", err))
+	}
+}
+
+// parseLastRun finds the most recent "[timestamp] result" line written by backup-run
+func parseLastRun(log string) (time.Time, string, bool) {
+	lines := strings.Split(strings.TrimSpace(log), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := lines[i]
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		end := strings.Index(line, "] ")
+		if end < 0 {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, line[1:end])
+		if err != nil {
+			continue
+		}
+		return at, line[end+2:], true
+	}
+	return time.Time{}, "", false
+}
+
 // Internal command for backup execution
 var backupRunCmd = &cobra.Command{
 	Use:    "backup-run",
@@ -190,6 +221,14 @@ func init() {
 }
 
 func runBackupExec(cmd *cobra.Command, args []string) error {
+	if err := backupOnce(); err != nil {
+		fmt.Printf("[%s] Backup failed: %v\n", time.Now().Format(time.RFC3339), err)
+		return err
+	}
+	return nil
+}
+
+func backupOnce() error {
 	cfg, err := config.New()
 	if err != nil {
 		return err
@@ -222,6 +261,9 @@ func runBackupExec(cmd *cobra.Command, args []string) error {
 	if err := exec.Command("git", "-C", cfg.DBDir, "push").Run(); err != nil {
 		return fmt.Errorf("git push failed: %w", err)
 	}
+	if err := pushSeenRefs(cfg.DBDir); err != nil {
+		return err
+	}
 
 	fmt.Printf("[%s] Backup completed\n", time.Now().Format(time.RFC3339))
 	return nil
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestParseLastRun(t *testing.T) {
+	log := `[2026-01-02T10:00:00Z] Backup completed
+Error: something unrelated
+[2026-01-02T11:00:00Z] Backup failed: git push failed: exit status 128
+Error: git push failed: exit status 128
+`
+	at, result, ok := parseLastRun(log)
+	if !ok {
+		t.Fatal("expected a last run")
+	}
+	if at.Hour() != 11 {
+		t.Errorf("at = %s, want 11:00", at)
+	}
+	if result != "Backup failed: git push failed: exit status 128" {
+		t.Errorf("result = %q", result)
+	}
+
+	if _, _, ok := parseLastRun("no timestamps here\n"); ok {
+		t.Error("expected no last run")
+	}
+}
@@ -0,0 +1,397 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/fsutil"
+	"github.com/KakkoiDev/aidb/internal/journal"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var (
+	promoteTo        string
+	promoteOverwrite bool
+	promoteDryRun    bool
+)
+
+var branchCmd = &cobra.Command{
+	Use:   "branch",
+	Short: "Manage per-branch knowledge of the current project",
+	Long: `Show the branch trees stored for the current project, or promote one into another.
+
+Examples:
+  aidb branch                            # Branch trees and file counts
+  aidb branch promote feature/x          # Move feature/x files into the default branch
+  aidb branch promote feature/x --to dev # ...into dev
+  aidb branch promote feature/x --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runBranchList,
+}
+
+var branchPromoteCmd = &cobra.Command{
+	Use:   "promote <branch>",
+	Short: "Move a branch's files into another branch tree",
+	Long: `Move every file of <branch> into the target branch tree (default: the
+repository's default branch), carrying seen state across and repointing the
+files' symlinks in any checkout on this machine, as aidb mv does. The
+promotion is journaled, so aidb undo reverts it.
+
+Files identical in both trees are merged. Files that differ are left in
+<branch> and reported, unless --overwrite replaces the target's copy.`,
+	Args:        cobra.ExactArgs(1),
+	RunE:        runBranchPromote,
+	Annotations: writesDB,
+}
+
+func init() {
+	rootCmd.AddCommand(branchCmd)
+	branchCmd.AddCommand(branchPromoteCmd)
+	branchPromoteCmd.Flags().StringVar(&promoteTo, "to", "", "Target branch (default: origin/HEAD or main)")
+	branchPromoteCmd.Flags().BoolVar(&promoteOverwrite, "overwrite", false, "Replace differing files in the target")
+	branchPromoteCmd.Flags().BoolVar(&promoteDryRun, "dry-run", false, "Show what would be moved")
+}
+
+// BranchTree is one branch directory of a project
+type BranchTree struct {
+	Branch  string `json:"branch"`
+	Files   int    `json:"files"`
+	Current bool   `json:"current,omitempty"`
+}
+
+func runBranchList(cmd *cobra.Command, args []string) error {
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+	p, err := currentProject(cfg)
+	if err != nil {
+		return err
+	}
+
+	trees, err := branchTrees(cfg, p)
+	if err != nil {
+		return err
+	}
+
+	if flagJSON {
+		return writeJSON(cmd, trees)
+	}
+	if len(trees) == 0 {
+		printInfo(fmt.Sprintf("No files stored for %s", p.ID))
+		return nil
+	}
+	for _, t := range trees {
+		marker := " "
+		if t.Current {
+			marker = colorGreen("*")
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s %s %s\n", marker, t.Branch, colorGray(fmt.Sprintf("(%d file(s))", t.Files)))
+	}
+	return nil
+}
+
+// currentProject resolves the git project of the working directory
+func currentProject(cfg *config.Config) (*config.Project, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	p, err := cfg.ResolveProject(cwd)
+	if err != nil {
+		return nil, err
+	}
+	if p.Source == "path" {
+		return nil, fmt.Errorf("not in a git repository")
+	}
+	return p, nil
+}
+
+// branchTrees counts tracked files per branch of a project. Branch names may
+// contain slashes, so a branch is identified by the known git branches first
+// and otherwise by the first path component.
+func branchTrees(cfg *config.Config, p *config.Project) ([]BranchTree, error) {
+	prefix := p.Dir + "/"
+	_, paths, err := syncIndex(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	known := gitBranches()
+	counts := map[string]int{}
+	for _, relPath := range paths {
+		relPath = filepath.ToSlash(relPath)
+		if !strings.HasPrefix(relPath, prefix) {
+			continue
+		}
+		counts[branchOf(strings.TrimPrefix(relPath, prefix), known)]++
+	}
+
+	trees := []BranchTree{}
+	for branch, n := range counts {
+		trees = append(trees, BranchTree{Branch: branch, Files: n, Current: branch == p.Branch})
+	}
+	sort.Slice(trees, func(i, j int) bool { return trees[i].Branch < trees[j].Branch })
+	return trees, nil
+}
+
+// branchOf returns the branch a project-relative path belongs to
+func branchOf(rel string, known []string) string {
+	for _, b := range known {
+		if strings.HasPrefix(rel, b+"/") {
+			return b
+		}
+	}
+	return strings.SplitN(rel, "/", 2)[0]
+}
+
+// gitBranches lists local branches of the current repo, longest first so
+// feature/x wins over feature
+func gitBranches() []string {
+	out, err := exec.Command("git", "for-each-ref", "--format=%(refname:short)", "refs/heads").Output()
+	if err != nil {
+		return nil
+	}
+	branches := strings.Fields(string(out))
+	sort.Slice(branches, func(i, j int) bool { return len(branches[i]) > len(branches[j]) })
+	return branches
+}
+
+// promotion is the planned fate of one file
+type promotion struct {
+	From, To string // db-relative
+	Action   string // move, merge (identical), conflict or overwrite
+}
+
+func runBranchPromote(cmd *cobra.Command, args []string) error {
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+	p, err := currentProject(cfg)
+	if err != nil {
+		return err
+	}
+
+	source := strings.Trim(args[0], "/")
+	target := promoteTo
+	if target == "" {
+		cwd, _ := os.Getwd()
+		target = config.DefaultBranch(cwd)
+	}
+	if source == target {
+		return fmt.Errorf("cannot promote %s into itself", source)
+	}
+
+	projectDir := filepath.Join(cfg.DBDir, filepath.FromSlash(p.Dir))
+	srcDir, err := branchDir(projectDir, source)
+	if err != nil {
+		return err
+	}
+	dstDir, err := branchDir(projectDir, target)
+	if err != nil {
+		return err
+	}
+	if srcDir == dstDir {
+		return fmt.Errorf("cannot promote %s into itself", source)
+	}
+	if info, err := os.Stat(srcDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("no files stored for branch %s of %s", source, p.ID)
+	}
+
+	plan, err := planPromotion(cfg.DBDir, srcDir, dstDir)
+	if err != nil {
+		return err
+	}
+	if len(plan) == 0 {
+		printInfo(fmt.Sprintf("Nothing to promote from %s", source))
+		return nil
+	}
+
+	if promoteDryRun {
+		if flagJSON {
+			return writeJSON(cmd, plan)
+		}
+		for _, step := range plan {
+			fmt.Fprintf(cmd.OutOrStdout(), "  %-9s %s -> %s\n", step.Action, step.From, step.To)
+		}
+		return nil
+	}
+
+	if err := cfg.SaveProject(p); err != nil {
+		return err
+	}
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata: %w", err)
+	}
+	j, err := journal.Begin(cfg.DBDir, "promote", args)
+	if err != nil {
+		return fmt.Errorf("failed to start journal: %w", err)
+	}
+
+	var touched []string
+	promoted, relinked, conflicts := 0, 0, 0
+	for _, step := range plan {
+		from := filepath.Join(cfg.DBDir, step.From)
+		to := filepath.Join(cfg.DBDir, step.To)
+
+		if step.Action == "conflict" {
+			printWarning(fmt.Sprintf("Differs in %s, kept: %s", target, step.From))
+			conflicts++
+			continue
+		}
+		// The target's copy makes way, kept in the journal for undo
+		if step.Action == "merge" || step.Action == "overwrite" {
+			trash := j.Trash(to)
+			if err := j.Move(to, trash); err != nil {
+				rollbackJournal(cfg, j)
+				return fmt.Errorf("failed to journal: %w", err)
+			}
+			if err := os.MkdirAll(filepath.Dir(trash), 0755); err != nil {
+				rollbackJournal(cfg, j)
+				return err
+			}
+			if err := fsutil.Move(to, trash); err != nil {
+				rollbackJournal(cfg, j)
+				return fmt.Errorf("%s: %w (all files of this promote were restored)", step.To, err)
+			}
+		}
+		res, err := moveTracked(cfg, meta, j, mvSource{target: from}, to, "")
+		if err != nil {
+			rollbackJournal(cfg, j)
+			return fmt.Errorf("%s: %w (all files of this promote were restored)", step.From, err)
+		}
+		promoted++
+		relinked += len(res.Links)
+		touched = append(touched, step.From, step.To)
+	}
+
+	if err := meta.Save(); err != nil {
+		rollbackJournal(cfg, j)
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+	removeEmptyDirs(srcDir)
+	updateIndex(cfg, touched...)
+	if err := j.Commit(); err != nil {
+		printWarning(fmt.Sprintf("failed to finish journal: %v", err))
+	}
+
+	printSuccess(fmt.Sprintf("Promoted %d file(s) from %s to %s", promoted, source, target))
+	if relinked > 0 {
+		printInfo(fmt.Sprintf("Repointed %d symlink(s)", relinked))
+	}
+	if conflicts > 0 {
+		return fmt.Errorf("%d file(s) differ in %s (use --overwrite to replace them)", conflicts, target)
+	}
+	return nil
+}
+
+// branchDir returns the tree of branch inside projectDir, rejecting names
+// that would leave it (absolute paths, .. components, empty names)
+func branchDir(projectDir, branch string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(branch))
+	if branch == "" || filepath.IsAbs(clean) || clean == "." || slices.Contains(strings.Split(filepath.ToSlash(clean), "/"), "..") {
+		return "", fmt.Errorf("invalid branch: %q", branch)
+	}
+	dir := filepath.Join(projectDir, clean)
+	if rel, err := filepath.Rel(projectDir, dir); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("invalid branch: %q", branch)
+	}
+	return dir, nil
+}
+
+// planPromotion decides what happens to each file under srcDir
+func planPromotion(dbDir, srcDir, dstDir string) ([]promotion, error) {
+	var plan []promotion
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, _ := filepath.Rel(srcDir, path)
+		to := filepath.Join(dstDir, rel)
+
+		step := promotion{Action: "move"}
+		step.From, _ = filepath.Rel(dbDir, path)
+		step.To, _ = filepath.Rel(dbDir, to)
+
+		if existing, err := os.ReadFile(to); err == nil {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			switch {
+			case bytes.Equal(existing, data):
+				step.Action = "merge"
+			case promoteOverwrite:
+				step.Action = "overwrite"
+			default:
+				step.Action = "conflict"
+			}
+		}
+		plan = append(plan, step)
+		return nil
+	})
+	return plan, err
+}
+
+// removeEmptyDirs deletes dir and its subdirectories if they hold no files
+func removeEmptyDirs(dir string) {
+	var dirs []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	// Deepest first; os.Remove fails harmlessly on non-empty directories
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+}
+
+// worktreeRoots lists the working trees of the current repo, main tree first
+func worktreeRoots() []string {
+	out, err := exec.Command("git", "worktree", "list", "--porcelain").Output()
+	if err != nil {
+		return nil
+	}
+	var roots []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if root, ok := strings.CutPrefix(line, "worktree "); ok {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// walkCheckoutLinks calls fn for every symlink in the worktrees of the
+// current repo, skipping .git and node_modules
+func walkCheckoutLinks(fn func(path, target string)) {
+	for _, root := range worktreeRoots() {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() && (d.Name() == ".git" || d.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			if d.Type()&fs.ModeSymlink == 0 {
+				return nil
+			}
+			if target, err := os.Readlink(path); err == nil {
+				fn(path, target)
+			}
+			return nil
+		})
+	}
+}
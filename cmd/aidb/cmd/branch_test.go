@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+func TestBranchPromote(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { promoteTo, promoteOverwrite, promoteDryRun, flagAgent = "", false, false, "" }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+
+	feature := filepath.Join(env.DBDir, "myproject", "feature-x")
+	main := filepath.Join(env.DBDir, "myproject", "main")
+	env.CreateFile(filepath.Join(feature, "docs", "TASK.md"), "# Task")
+	env.CreateFile(filepath.Join(feature, "SAME.md"), "same")
+	env.CreateFile(filepath.Join(main, "SAME.md"), "same")
+	env.CreateFile(filepath.Join(feature, "CONFLICT.md"), "feature")
+	env.CreateFile(filepath.Join(main, "CONFLICT.md"), "main")
+
+	link := filepath.Join(repoDir, "docs", "TASK.md")
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(feature, "docs", "TASK.md"), link); err != nil {
+		t.Fatal(err)
+	}
+
+	rootCmd.SetArgs([]string{"seen", "myproject/feature-x/docs/TASK.md", "--agent", "claude"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("seen command failed: %v", err)
+	}
+
+	rootCmd.SetArgs([]string{"branch", "promote", "feature-x"})
+	if err := rootCmd.Execute(); err == nil {
+		t.Fatal("expected an error reporting the conflicting file")
+	}
+
+	if !env.FileExists(filepath.Join(main, "docs", "TASK.md")) || env.FileExists(filepath.Join(feature, "docs", "TASK.md")) {
+		t.Error("TASK.md should have moved to main")
+	}
+	if env.FileExists(filepath.Join(feature, "SAME.md")) {
+		t.Error("identical SAME.md should have been merged away")
+	}
+	if got := env.ReadFile(filepath.Join(feature, "CONFLICT.md")); got != "feature" {
+		t.Errorf("conflicting file should stay in feature-x, got %q", got)
+	}
+	if got := env.ReadFile(filepath.Join(main, "CONFLICT.md")); got != "main" {
+		t.Errorf("target copy should be untouched, got %q", got)
+	}
+
+	if target := env.SymlinkTarget(link); target != filepath.Join(main, "docs", "TASK.md") {
+		t.Errorf("symlink target = %s, want the main copy", target)
+	}
+
+	meta, err := metadata.New(env.DBDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := metadata.HashBytes([]byte("# Task"))
+	if !meta.IsSeen(filepath.Join("myproject", "main", "docs", "TASK.md"), "claude", hash, time.Time{}) {
+		t.Error("seen state should follow the file to main")
+	}
+
+	rootCmd.SetArgs([]string{"branch", "promote", "feature-x", "--overwrite"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("promote --overwrite failed: %v", err)
+	}
+	if got := env.ReadFile(filepath.Join(main, "CONFLICT.md")); got != "feature" {
+		t.Errorf("--overwrite should replace the target copy, got %q", got)
+	}
+	if env.FileExists(feature) {
+		t.Error("empty feature-x tree should be removed")
+	}
+}
+
+func TestBranchPromote_DryRun(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { promoteTo, promoteDryRun = "", false }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	src := filepath.Join(env.DBDir, "myproject", "feature-x", "TASK.md")
+	env.CreateFile(src, "# Task")
+
+	rootCmd.SetArgs([]string{"branch", "promote", "feature-x", "--to", "dev", "--dry-run"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("promote --dry-run failed: %v", err)
+	}
+	if !env.FileExists(src) || env.FileExists(filepath.Join(env.DBDir, "myproject", "dev", "TASK.md")) {
+		t.Error("--dry-run must not move files")
+	}
+}
+
+func TestBranchPromote_RejectsPathsOutsideProject(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { promoteTo, promoteDryRun = "", false }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	other := filepath.Join(env.DBDir, "other", "main", "TASK.md")
+	env.CreateFile(other, "# Other project")
+	env.CreateFile(filepath.Join(env.DBDir, "myproject", "feature-x", "TASK.md"), "# Task")
+
+	for _, args := range [][]string{
+		{"branch", "promote", ".."},
+		{"branch", "promote", "../other/main", "--to", "dev"},
+		{"branch", "promote", "feature-x", "--to", "../other/main"},
+		{"branch", "promote", "feature-x", "--to", "/tmp"},
+		{"branch", "promote", "feature-x", "--to", "."},
+	} {
+		promoteTo = ""
+		rootCmd.SetArgs(args)
+		if err := rootCmd.Execute(); err == nil {
+			t.Errorf("%v should fail", args)
+		}
+	}
+	if !env.FileExists(other) || !env.FileExists(filepath.Join(env.DBDir, "myproject", "feature-x", "TASK.md")) {
+		t.Error("no file should have moved")
+	}
+}
+
+func TestBranchPromote_RecordedLinksAndUndo(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { promoteTo, promoteOverwrite, promoteDryRun = "", false, false }()
+	t.Setenv("AIDB_HOST", "testhost")
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+
+	feature := filepath.Join(env.DBDir, "myproject", "feature-x")
+	main := filepath.Join(env.DBDir, "myproject", "main")
+	env.CreateFile(filepath.Join(feature, "TASK.md"), "# Task")
+	env.CreateFile(filepath.Join(feature, "SAME.md"), "same")
+	env.CreateFile(filepath.Join(main, "SAME.md"), "same")
+
+	// A checkout outside the current repo, known only from the link registry
+	other := filepath.Join(env.WorkDir, "other-clone", "TASK.md")
+	os.MkdirAll(filepath.Dir(other), 0755)
+	if err := os.Symlink(filepath.Join(feature, "TASK.md"), other); err != nil {
+		t.Fatal(err)
+	}
+	meta, _ := metadata.New(env.DBDir)
+	meta.AddLink("myproject/feature-x/TASK.md", "testhost", other)
+	meta.Save()
+
+	rootCmd.SetArgs([]string{"branch", "promote", "feature-x"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("promote failed: %v", err)
+	}
+	if target := env.SymlinkTarget(other); target != filepath.Join(main, "TASK.md") {
+		t.Errorf("symlink in the other checkout = %s, want the main copy", target)
+	}
+	meta, _ = metadata.New(env.DBDir)
+	if links := meta.Links("myproject/main/TASK.md"); len(links) != 1 || links[0].Path != other {
+		t.Errorf("links of the main copy = %v, want %s", links, other)
+	}
+
+	rootCmd.SetArgs([]string{"undo"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	for _, name := range []string{"TASK.md", "SAME.md"} {
+		if !env.FileExists(filepath.Join(feature, name)) {
+			t.Errorf("%s should be back in feature-x", name)
+		}
+	}
+	if got := env.ReadFile(filepath.Join(main, "SAME.md")); got != "same" {
+		t.Errorf("main SAME.md = %q, want it restored", got)
+	}
+	if env.FileExists(filepath.Join(main, "TASK.md")) {
+		t.Error("TASK.md should be gone from main")
+	}
+	if target := env.SymlinkTarget(other); target != filepath.Join(feature, "TASK.md") {
+		t.Errorf("symlink after undo = %s, want the feature-x copy", target)
+	}
+	meta, _ = metadata.New(env.DBDir)
+	if links := meta.Links("myproject/feature-x/TASK.md"); len(links) != 1 {
+		t.Errorf("links after undo = %v, want the recorded link back", links)
+	}
+}
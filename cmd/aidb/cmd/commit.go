@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -18,8 +19,9 @@ var commitCmd = &cobra.Command{
 Examples:
   aidb commit "Add project notes"
   aidb commit "Update TASK.md with new requirements"`,
-	Args: cobra.ExactArgs(1),
-	RunE: runCommit,
+	Args:        cobra.ExactArgs(1),
+	RunE:        runCommit,
+	Annotations: writesDB,
 }
 
 func init() {
@@ -42,21 +44,34 @@ func runCommit(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("aidb not initialized (run 'aidb add' first)")
 	}
 
-	// Check for staged changes
-	gitCmd := exec.Command("git", "-C", cfg.DBDir, "diff", "--cached", "--quiet")
-	if err := gitCmd.Run(); err == nil {
+	committed, err := commitStaged(cfg.DBDir, message, os.Stdout, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if !committed {
 		printInfo("Nothing staged to commit")
 		return nil
 	}
 
+	printSuccess("Committed")
+	return nil
+}
+
+// commitStaged commits the index with message, returning false if nothing is staged.
+// git's own output goes to stdout/stderr, which may be nil to discard it.
+func commitStaged(dir, message string, stdout, stderr io.Writer) (bool, error) {
+	// Check for staged changes
+	gitCmd := exec.Command("git", "-C", dir, "diff", "--cached", "--quiet")
+	if err := gitCmd.Run(); err == nil {
+		return false, nil
+	}
+
 	// Commit
-	gitCmd = exec.Command("git", "-C", cfg.DBDir, "commit", "-m", message)
-	gitCmd.Stdout = os.Stdout
-	gitCmd.Stderr = os.Stderr
+	gitCmd = exec.Command("git", "-C", dir, "commit", "-m", message)
+	gitCmd.Stdout = stdout
+	gitCmd.Stderr = stderr
 	if err := gitCmd.Run(); err != nil {
-		return fmt.Errorf("git commit failed: %w", err)
+		return false, fmt.Errorf("git commit failed: %w", err)
 	}
-
-	printSuccess("Committed")
-	return nil
+	return true, nil
 }
@@ -2,12 +2,9 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 
 	"github.com/KakkoiDev/aidb/internal/config"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
 var configCmd = &cobra.Command{
@@ -21,6 +18,10 @@ With two arguments, sets the key to the value.
 
 Config file: ~/.config/aidb/config.yaml
 
+Values are resolved from built-in defaults, the config file, AIDB_*
+environment variables (db.path -> AIDB_DB_PATH) and CLI flags, in that
+order of precedence.
+
 Examples:
   aidb config              # Show all config
   aidb config db.path      # Show db.path value
@@ -33,111 +34,70 @@ func init() {
 	rootCmd.AddCommand(configCmd)
 }
 
-type UserConfig struct {
-	DB struct {
-		Path string `yaml:"path,omitempty"`
-	} `yaml:"db,omitempty"`
-	Backup struct {
-		Enabled bool `yaml:"enabled,omitempty"`
-	} `yaml:"backup,omitempty"`
-	Git struct {
-		Remote string `yaml:"remote,omitempty"`
-	} `yaml:"git,omitempty"`
-}
-
-func getConfigPath() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".config", "aidb", "config.yaml")
-}
-
-func loadUserConfig() (*UserConfig, error) {
-	cfg := &UserConfig{}
-	data, err := os.ReadFile(getConfigPath())
-	if err != nil {
-		if os.IsNotExist(err) {
-			return cfg, nil
-		}
-		return nil, err
-	}
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return nil, err
-	}
-	return cfg, nil
-}
-
-func saveUserConfig(cfg *UserConfig) error {
-	path := getConfigPath()
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return err
-	}
-	data, err := yaml.Marshal(cfg)
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(path, data, 0644)
-}
-
 func runConfig(cmd *cobra.Command, args []string) error {
 	cfg, err := config.New()
 	if err != nil {
 		return err
 	}
 
-	userCfg, err := loadUserConfig()
-	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
-	}
-
 	// No args: show all config
 	if len(args) == 0 {
 		fmt.Println("# Current configuration")
 		fmt.Println()
-		fmt.Printf("db.path = %s\n", cfg.DBDir)
-		fmt.Printf("backup.enabled = %v\n", userCfg.Backup.Enabled)
-		fmt.Printf("git.remote = %s\n", GetRemoteURL(cfg.DBDir))
+		for _, name := range config.Keys() {
+			fmt.Printf("%s = %s\n", name, configValue(cfg, name))
+		}
 		fmt.Println()
-		fmt.Printf("# Config file: %s\n", getConfigPath())
+		fmt.Printf("# Config file: %s\n", config.ConfigPath())
 		return nil
 	}
 
 	key := args[0]
+	k, ok := config.LookupKey(key)
+	if !ok {
+		return fmt.Errorf("unknown config key: %s", key)
+	}
 
 	// One arg: show specific key
 	if len(args) == 1 {
-		switch key {
-		case "db.path":
-			fmt.Println(cfg.DBDir)
-		case "backup.enabled":
-			fmt.Println(userCfg.Backup.Enabled)
-		case "git.remote":
-			fmt.Println(GetRemoteURL(cfg.DBDir))
-		default:
-			return fmt.Errorf("unknown config key: %s", key)
-		}
+		fmt.Println(configValue(cfg, key))
 		return nil
 	}
 
 	// Two args: set key
 	value := args[1]
-	switch key {
-	case "db.path":
-		userCfg.DB.Path = value
-	case "backup.enabled":
-		userCfg.Backup.Enabled = value == "true"
-	case "git.remote":
+	if key == "git.remote" {
 		if err := configureRemote(cfg.DBDir, value); err != nil {
 			return fmt.Errorf("failed to configure remote: %w", err)
 		}
 		printSuccess(fmt.Sprintf("Set %s = %s", key, value))
 		return nil
-	default:
-		return fmt.Errorf("unknown config key: %s", key)
 	}
 
-	if err := saveUserConfig(userCfg); err != nil {
+	userCfg, err := config.LoadFile()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := k.Set(userCfg, value); err != nil {
+		return err
+	}
+	if err := config.SaveFile(userCfg); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 
 	printSuccess(fmt.Sprintf("Set %s = %s", key, value))
 	return nil
 }
+
+// configValue returns the effective value of a key. db.path is shown expanded
+// and git.remote is read from the database repository itself.
+func configValue(cfg *config.Config, key string) string {
+	switch key {
+	case "db.path":
+		return cfg.DBDir
+	case "git.remote":
+		return GetRemoteURL(cfg.DBDir)
+	}
+	k, _ := config.LookupKey(key)
+	return k.Get(cfg.Settings)
+}
@@ -0,0 +1,418 @@
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/frontmatter"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var (
+	contextBudget int
+	contextFormat string
+)
+
+var contextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "Print a token-budgeted context pack for the current project",
+	Long: `Gather the knowledge relevant to the current directory into one document
+an agent can read at the start of a task.
+
+Files come in priority order: the current project/branch, then the project's
+_aidb/ tier, then the global _aidb/ tier. Within a tier, unseen files come
+first, then the most recently modified. Files are included whole while the
+budget allows; past that, Markdown files shrink to their headings and the
+first line under each, and anything left is cut. Files that do not fit at
+all are listed by path at the end, while the list fits. The budget covers
+the whole output, headers included; tokens are estimated at four bytes each.
+
+Every file carries its database path, for citing and for aidb seen.
+
+Examples:
+  aidb context
+  aidb context --budget 2000
+  aidb context --format xml
+  aidb context --format json --agent ci`,
+	Args: cobra.NoArgs,
+	RunE: runContext,
+}
+
+func init() {
+	contextCmd.Flags().IntVar(&contextBudget, "budget", 8000, "Maximum tokens to emit")
+	contextCmd.Flags().StringVar(&contextFormat, "format", "md", "Output format: md, xml or json")
+	rootCmd.AddCommand(contextCmd)
+}
+
+// contextMinTokens is the least room worth giving a truncated file
+const contextMinTokens = 32
+
+// ContextPack is the document aidb context prints
+type ContextPack struct {
+	XMLName xml.Name      `json:"-" xml:"context"`
+	Project string        `json:"project" xml:"project,attr"`
+	Branch  string        `json:"branch,omitempty" xml:"branch,attr,omitempty"`
+	Budget  int           `json:"budget" xml:"budget,attr"`
+	Tokens  int           `json:"tokens" xml:"tokens,attr"` // estimated tokens of the whole pack, at most Budget
+	Files   []ContextFile `json:"files" xml:"file"`
+	Omitted []string      `json:"omitted,omitempty" xml:"omitted,omitempty"` // over budget, as far as the list fits
+}
+
+// ContextFile is one file of a context pack
+type ContextFile struct {
+	Path      string `json:"path" xml:"path,attr"`
+	Tier      string `json:"tier" xml:"tier,attr"` // branch, project or global
+	Seen      bool   `json:"seen" xml:"seen,attr"`
+	Stale     bool   `json:"stale,omitempty" xml:"stale,attr,omitempty"`
+	Modified  string `json:"modified" xml:"modified,attr"`
+	Truncated bool   `json:"truncated,omitempty" xml:"truncated,attr,omitempty"`
+	Content   string `json:"content" xml:",cdata"`
+
+	rank    int // tier order
+	modTime int64
+}
+
+// contextTiers ranks the tiers of a pack, most relevant first
+var contextTiers = []string{"branch", "project", "global"}
+
+func runContext(cmd *cobra.Command, args []string) error {
+	if flagJSON {
+		contextFormat = "json"
+	}
+	switch contextFormat {
+	case "md", "xml", "json":
+	default:
+		return fmt.Errorf("unknown format: %s (use md, xml or json)", contextFormat)
+	}
+	if contextBudget <= 0 {
+		return fmt.Errorf("--budget must be positive")
+	}
+
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	p, err := cfg.ResolveProject(cwd)
+	if err != nil {
+		return err
+	}
+
+	pack := &ContextPack{Project: p.Dir, Budget: contextBudget, Files: []ContextFile{}}
+	if p.Source != "path" {
+		pack.Branch = p.Branch
+	}
+	if _, err := os.Stat(cfg.DBDir); err == nil {
+		candidates, err := contextFiles(cfg, pack.Project, pack.Branch)
+		if err != nil {
+			return err
+		}
+		if err := fitContext(pack, candidates, contextFormat); err != nil {
+			return err
+		}
+	}
+	return writeContext(cmd.OutOrStdout(), pack, contextFormat)
+}
+
+// writeContext prints pack in format
+func writeContext(w io.Writer, pack *ContextPack, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(pack)
+	case "xml":
+		data, err := xml.MarshalIndent(pack, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+	writeContextMarkdown(w, pack)
+	return nil
+}
+
+// contextFiles returns the tracked files of the project's tiers with their
+// content, in priority order. Binary files are skipped.
+func contextFiles(cfg *config.Config, project, branch string) ([]ContextFile, error) {
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load metadata: %w", err)
+	}
+	idx, paths, err := syncIndex(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	branchDir := project
+	if branch != "" {
+		branchDir = project + "/" + branch
+	}
+	agent := currentAgent()
+	now := time.Now()
+
+	var files []ContextFile
+	for _, relPath := range paths {
+		tier := contextTier(filepath.ToSlash(relPath), project, branchDir)
+		e := idx.Get(relPath)
+		if tier < 0 || e == nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(cfg.DBDir, relPath))
+		if err != nil || !utf8.Valid(data) {
+			continue
+		}
+		due := meta.Due(relPath, e.Front.Due())
+		files = append(files, ContextFile{
+			Path:     filepath.ToSlash(relPath),
+			Tier:     contextTiers[tier],
+			Seen:     meta.IsSeen(relPath, agent, e.Hash, due),
+			Stale:    !due.IsZero() && !now.Before(due),
+			Modified: time.Unix(0, e.ModTime).Format(metadata.DateFormat),
+			Content:  string(data),
+			rank:     tier,
+			modTime:  e.ModTime,
+		})
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		if a.Seen != b.Seen {
+			return !a.Seen
+		}
+		if a.modTime != b.modTime {
+			return a.modTime > b.modTime
+		}
+		return a.Path < b.Path
+	})
+	return files, nil
+}
+
+// contextTier returns the index in contextTiers of a db-relative path, or -1
+// if it belongs to another project or branch
+func contextTier(relPath, project, branchDir string) int {
+	switch {
+	case strings.HasPrefix(relPath, "_aidb/"):
+		return 2
+	case strings.HasPrefix(relPath, project+"/_aidb/"):
+		return 1
+	case strings.HasPrefix(relPath, branchDir+"/"):
+		if isAidbPath(relPath) {
+			return 1
+		}
+		return 0
+	}
+	return -1
+}
+
+// fitContext adds files to pack in order until the budget is spent, shrinking
+// the first ones that do not fit whole. Everything printed counts: the
+// preamble or envelope of the format, each file's header and the omitted
+// list, whose paths are dropped once even they do not fit.
+func fitContext(pack *ContextPack, files []ContextFile, format string) error {
+	// Bytes, so rounding each part up to whole tokens does not add up
+	base, err := contextSize(emptyPack(pack), format)
+	if err != nil {
+		return err
+	}
+	left := pack.Budget*4 - base - contextSlack
+	if left < 0 {
+		return fmt.Errorf("--budget %d is too small: the pack alone takes ~%d tokens", pack.Budget, (base+contextSlack+3)/4)
+	}
+	for _, f := range files {
+		cost, err := contextFileSize(pack, f, format)
+		if err != nil {
+			return err
+		}
+		if cost <= left {
+			pack.Files = append(pack.Files, f)
+			left -= cost
+			continue
+		}
+
+		f.Truncated = true
+		content := f.Content
+		for room := left; room >= contextMinTokens*4; room -= cost - left {
+			f.Content = shrinkContent(f.Path, content, room/4)
+			if cost, err = contextFileSize(pack, f, format); err != nil {
+				return err
+			}
+			if cost <= left {
+				break
+			}
+		}
+		if cost <= left {
+			pack.Files = append(pack.Files, f)
+			left -= cost
+			continue
+		}
+
+		omitted := emptyPack(pack)
+		omitted.Omitted = []string{f.Path}
+		if cost, err = contextSize(omitted, format); err != nil {
+			return err
+		}
+		if cost -= base; cost <= left {
+			pack.Omitted = append(pack.Omitted, f.Path)
+			left -= cost
+		}
+	}
+	pack.Tokens = (pack.Budget*4 - left + 3) / 4
+	return nil
+}
+
+// contextSlack covers separators between entries and file counts growing by
+// a digit, which the per-entry sizes below do not see
+const contextSlack = 16
+
+// emptyPack is pack without files, with its token count at the widest
+func emptyPack(pack *ContextPack) *ContextPack {
+	return &ContextPack{Project: pack.Project, Branch: pack.Branch, Budget: pack.Budget, Tokens: pack.Budget}
+}
+
+// contextFileSize is the number of bytes f adds to pack when printed
+func contextFileSize(pack *ContextPack, f ContextFile, format string) (int, error) {
+	empty := emptyPack(pack)
+	base, err := contextSize(empty, format)
+	if err != nil {
+		return 0, err
+	}
+	empty.Files = []ContextFile{f}
+	size, err := contextSize(empty, format)
+	return size - base + 2, err // and the separator before it
+}
+
+// contextSize is the length of pack printed in format
+func contextSize(pack *ContextPack, format string) (int, error) {
+	var b strings.Builder
+	if err := writeContext(&b, pack, format); err != nil {
+		return 0, err
+	}
+	return b.Len(), nil
+}
+
+// estimateTokens approximates the token count of s at four bytes per token
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// shrinkContent cuts content to about budget tokens, outlining Markdown by
+// its headings first and pointing at the full file
+func shrinkContent(relPath, content string, budget int) string {
+	note := fmt.Sprintf("[truncated; full file: aidb show /%s]\n", relPath)
+	budget -= estimateTokens(note)
+	if frontmatter.IsMarkdown(relPath) {
+		content = outline(content)
+	}
+
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if estimateTokens(b.String())+estimateTokens(line) > budget {
+			break
+		}
+		b.WriteString(line)
+	}
+	if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+		b.WriteString("\n")
+	}
+	return b.String() + note
+}
+
+// outline keeps a Markdown document's frontmatter, its headings and the first
+// line under each, skipping fenced code
+func outline(content string) string {
+	var b strings.Builder
+	lines := strings.SplitAfter(content, "\n")
+	i := 0
+	if strings.TrimSpace(lines[0]) == "---" {
+		for i = 1; i < len(lines); i++ {
+			if t := strings.TrimSpace(lines[i]); t == "---" || t == "..." {
+				break
+			}
+		}
+		if i < len(lines) {
+			for _, l := range lines[:i+1] {
+				b.WriteString(l)
+			}
+			i++
+		} else {
+			i = 0
+		}
+	}
+
+	fenced, lead := false, true
+	for _, line := range lines[i:] {
+		t := strings.TrimSpace(line)
+		if strings.HasPrefix(t, "```") || strings.HasPrefix(t, "~~~") {
+			fenced = !fenced
+			continue
+		}
+		switch {
+		case fenced || t == "":
+		case strings.HasPrefix(t, "#"):
+			b.WriteString(line)
+			lead = true
+		case lead:
+			b.WriteString(line)
+			lead = false
+		}
+	}
+	return b.String()
+}
+
+// contextHeader is the Markdown heading of a file in a pack
+func contextHeader(f ContextFile) string {
+	state := "unseen"
+	if f.Seen {
+		state = "seen"
+	}
+	if f.Stale {
+		state += ", stale"
+	}
+	if f.Truncated {
+		state += ", truncated"
+	}
+	return fmt.Sprintf("## %s\n<!-- %s, %s, modified %s -->\n\n", f.Path, f.Tier, state, f.Modified)
+}
+
+func writeContextMarkdown(w io.Writer, pack *ContextPack) {
+	title := pack.Project
+	if pack.Branch != "" {
+		title += " (" + pack.Branch + ")"
+	}
+	fmt.Fprintf(w, "# aidb context: %s\n\n", title)
+	fmt.Fprintf(w, "<!-- %d files, ~%d of %d tokens. Mark what you used with: aidb seen <path> -->\n\n",
+		len(pack.Files), pack.Tokens, pack.Budget)
+	for _, f := range pack.Files {
+		fmt.Fprint(w, contextHeader(f))
+		fmt.Fprint(w, f.Content)
+		if f.Content != "" && !strings.HasSuffix(f.Content, "\n") {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w)
+	}
+	if len(pack.Omitted) > 0 {
+		fmt.Fprintln(w, "## Omitted (over budget)")
+		fmt.Fprintln(w)
+		for _, path := range pack.Omitted {
+			fmt.Fprintf(w, "- %s\n", path)
+		}
+	}
+}
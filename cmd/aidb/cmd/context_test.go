@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+func TestContextCommand(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { contextBudget, contextFormat = 8000, "md"; rootCmd.SetOut(nil) }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	branch := filepath.Join(env.DBDir, "myproject", "feature-x")
+	env.CreateFile(filepath.Join(branch, "TASK.md"), "# Task\nShip it.\n")
+	env.CreateFile(filepath.Join(branch, "NOTES.md"), "# Notes\nSeen already.\n")
+	env.CreateFile(filepath.Join(branch, "_aidb", "patterns.md"), "# Patterns\nRetry with backoff.\n")
+	env.CreateFile(filepath.Join(env.DBDir, "_aidb", "global.md"), "# Global\nPrefer small PRs.\n")
+	env.CreateFile(filepath.Join(env.DBDir, "other", "main", "OTHER.md"), "# Other project\n")
+
+	run := func(args ...string) []byte {
+		t.Helper()
+		var buf bytes.Buffer
+		rootCmd.SetOut(&buf)
+		rootCmd.SetArgs(args)
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("%v failed: %v", args, err)
+		}
+		return buf.Bytes()
+	}
+	run("seen", "myproject/feature-x/NOTES.md")
+
+	var pack ContextPack
+	if err := json.Unmarshal(run("context", "--format", "json"), &pack); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range pack.Files {
+		got = append(got, f.Tier+":"+f.Path)
+	}
+	want := []string{
+		"branch:myproject/feature-x/TASK.md",
+		"branch:myproject/feature-x/NOTES.md",
+		"project:myproject/feature-x/_aidb/patterns.md",
+		"global:_aidb/global.md",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+	if pack.Branch != "feature-x" || pack.Files[0].Seen || !pack.Files[1].Seen || pack.Files[0].Content != "# Task\nShip it.\n" {
+		t.Errorf("pack = %+v", pack)
+	}
+
+	var doc ContextPack
+	if err := xml.Unmarshal(run("context", "--format", "xml"), &doc); err != nil {
+		t.Fatalf("invalid xml: %v", err)
+	}
+	if len(doc.Files) != 4 || doc.Files[0].Path != "myproject/feature-x/TASK.md" || !strings.Contains(doc.Files[0].Content, "Ship it.") {
+		t.Errorf("xml files = %+v", doc.Files)
+	}
+
+	md := string(run("context", "--format", "md"))
+	if !strings.Contains(md, "## myproject/feature-x/TASK.md\n") || !strings.Contains(md, "Prefer small PRs.") {
+		t.Errorf("markdown pack:\n%s", md)
+	}
+}
+
+func TestContextCommand_Budget(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { contextBudget, contextFormat = 8000, "md"; rootCmd.SetOut(nil) }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	long := "# Design\nThe lead paragraph.\n" + strings.Repeat("Detail that does not fit.\n", 100) +
+		"## Storage\nFiles live in the database.\n```\n# not a heading\n```\n" + strings.Repeat("More detail.\n", 100)
+	env.CreateFile(filepath.Join(env.DBDir, "myproject", "feature-x", "DESIGN.md"), long)
+	env.CreateFile(filepath.Join(env.DBDir, "_aidb", "global.md"), strings.Repeat("Global knowledge.\n", 100))
+
+	// The whole output stays within the budget in every format
+	const budget = 150
+	run := func(format string) []byte {
+		t.Helper()
+		var buf bytes.Buffer
+		rootCmd.SetOut(&buf)
+		rootCmd.SetArgs([]string{"context", "--budget", fmt.Sprint(budget), "--format", format})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("context --format %s failed: %v", format, err)
+		}
+		if tokens := estimateTokens(buf.String()); tokens > budget {
+			t.Errorf("--format %s printed ~%d tokens, over the budget:\n%s", format, tokens, buf.String())
+		}
+		return buf.Bytes()
+	}
+	run("md")
+	run("xml")
+
+	var pack ContextPack
+	if err := json.Unmarshal(run("json"), &pack); err != nil {
+		t.Fatal(err)
+	}
+	if pack.Tokens > budget {
+		t.Errorf("tokens = %d, over the budget", pack.Tokens)
+	}
+	if len(pack.Files) != 1 || !pack.Files[0].Truncated {
+		t.Fatalf("files = %+v, want DESIGN.md truncated", pack.Files)
+	}
+	want := "# Design\nThe lead paragraph.\n## Storage\nFiles live in the database.\n[truncated; full file: aidb show /myproject/feature-x/DESIGN.md]\n"
+	if pack.Files[0].Content != want {
+		t.Errorf("content = %q, want the outline %q", pack.Files[0].Content, want)
+	}
+	if len(pack.Omitted) != 1 || pack.Omitted[0] != "_aidb/global.md" {
+		t.Errorf("omitted = %v, want [_aidb/global.md]", pack.Omitted)
+	}
+
+	rootCmd.SetArgs([]string{"context", "--budget", "5"})
+	if err := rootCmd.Execute(); err == nil {
+		t.Error("a budget smaller than the pack header should fail")
+	}
+}
@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/daemon"
+	"github.com/KakkoiDev/aidb/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon [run|status|stop]",
+	Short: "Watch the database and auto-commit changes",
+	Long: `Run a long-lived process that watches the database, commits changes
+after a quiet period and pushes on a cadence.
+
+Bursts of writes are debounced (daemon.debounce, default 2s) into a single
+commit whose message lists the changed paths; a steady stream of writes is
+still committed within 10 debounce periods of its first change. The daemon
+runs in the foreground and logs to stdout. Pushes happen every
+daemon.push-interval (default 15m); while the remote is unreachable the
+delay doubles up to 1h.
+
+Examples:
+  aidb daemon          # Run in the foreground
+  aidb daemon status   # Show state of the running daemon
+  aidb daemon stop     # Ask the running daemon to exit`,
+	Args:        cobra.MaximumNArgs(1),
+	ValidArgs:   []string{"run", "status", "stop"},
+	RunE:        runDaemon,
+	Annotations: writesDB,
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+	paths := daemon.NewPaths(filepath.Join(cfg.DBDir, ".daemon"))
+
+	action := "run"
+	if len(args) == 1 {
+		action = args[0]
+	}
+
+	switch action {
+	case "run":
+		return daemonRun(cfg, paths)
+	case "status":
+		return daemonStatus(cmd, paths)
+	case "stop":
+		return daemonStop(paths)
+	default:
+		return fmt.Errorf("unknown action: %s (use run, status, or stop)", action)
+	}
+}
+
+func daemonRun(cfg *config.Config, paths daemon.Paths) error {
+	debounce, err := time.ParseDuration(cfg.Settings.Daemon.Debounce)
+	if err != nil {
+		return fmt.Errorf("invalid daemon.debounce: %w", err)
+	}
+	pushInterval, err := time.ParseDuration(cfg.Settings.Daemon.PushInterval)
+	if err != nil {
+		return fmt.Errorf("invalid daemon.push-interval: %w", err)
+	}
+
+	if err := cfg.EnsureDBDir(); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	if err := paths.Acquire(); err != nil {
+		return err
+	}
+	defer paths.Release()
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+
+	w, err := watch.New(cfg.DBDir, config.IsInternalPath)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", cfg.DBDir, err)
+	}
+	defer w.Close()
+
+	d := daemon.New(daemon.Options{
+		Debounce:     debounce,
+		PushInterval: pushInterval,
+		Changed: func(paths []string) {
+			var rel []string
+			for _, p := range paths {
+				if r, err := filepath.Rel(cfg.DBDir, p); err == nil {
+					rel = append(rel, r)
+				}
+			}
+			updateIndex(cfg, rel...)
+		},
+		Commit: func() ([]string, error) { return autoCommit(cfg.DBDir) },
+		Push:   func() error { return autoPush(cfg.DBDir) },
+		Log:    func(msg string) { logger.Println(msg) },
+	}, os.Getpid())
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	go func() {
+		if err := d.Serve(ctx, paths.Socket, cancel); err != nil {
+			logger.Printf("Control socket failed: %v", err)
+		}
+	}()
+	go func() {
+		for err := range w.Errors {
+			logger.Printf("Watch error: %v", err)
+		}
+	}()
+
+	logger.Printf("Watching %s (pid %d)", cfg.DBDir, os.Getpid())
+	err = d.Run(ctx, w.Events)
+	logger.Println("Stopped")
+	return err
+}
+
+func daemonStatus(cmd *cobra.Command, paths daemon.Paths) error {
+	state, err := daemon.Query(paths.Socket, "status")
+	if err != nil {
+		if pid, alive := paths.ReadPID(); alive {
+			printWarning(fmt.Sprintf("Daemon pid %d is alive but not answering on %s", pid, paths.Socket))
+			return nil
+		}
+		if flagJSON {
+			fmt.Fprintln(cmd.OutOrStdout(), "null")
+			return nil
+		}
+		printInfo("Daemon is not running")
+		return nil
+	}
+
+	if flagJSON {
+		return writeJSON(cmd, state)
+	}
+
+	printSuccess(fmt.Sprintf("Daemon running (pid %d, since %s)", state.PID, state.StartedAt.Local().Format(time.RFC3339)))
+	printInfo(fmt.Sprintf("Pending changes: %d", state.Pending))
+	if !state.LastCommit.IsZero() {
+		printInfo(fmt.Sprintf("Last commit: %s", state.LastCommit.Local().Format(time.RFC3339)))
+	}
+	if !state.LastPush.IsZero() {
+		printInfo(fmt.Sprintf("Last push: %s", state.LastPush.Local().Format(time.RFC3339)))
+	}
+	if !state.NextPush.IsZero() {
+		printInfo(fmt.Sprintf("Next push: %s", state.NextPush.Local().Format(time.RFC3339)))
+	}
+	if state.LastError != "" {
+		printWarning(fmt.Sprintf("Last error: %s", state.LastError))
+	}
+	return nil
+}
+
+func daemonStop(paths daemon.Paths) error {
+	if _, err := daemon.Query(paths.Socket, "stop"); err == nil {
+		printSuccess("Daemon stopped")
+		return nil
+	}
+
+	// Socket unreachable: fall back to signalling the recorded pid
+	pid, alive := paths.ReadPID()
+	if !alive {
+		printInfo("Daemon is not running")
+		return nil
+	}
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		return fmt.Errorf("failed to stop daemon (pid %d): %w", pid, err)
+	}
+	printSuccess(fmt.Sprintf("Sent SIGTERM to daemon (pid %d)", pid))
+	return nil
+}
+
+// autoCommit stages every change and commits with a message listing the paths
+func autoCommit(dir string) ([]string, error) {
+	if err := exec.Command("git", "-C", dir, "add", "-A").Run(); err != nil {
+		return nil, fmt.Errorf("git add failed: %w", err)
+	}
+
+	out, err := exec.Command("git", "-C", dir, "diff", "--cached", "--name-status").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff failed: %w", err)
+	}
+	output := strings.TrimSpace(string(out))
+	if output == "" {
+		return nil, nil
+	}
+	changes := strings.Split(output, "\n")
+
+	msg := daemon.CommitMessage(changes)
+	if out, err := exec.Command("git", "-C", dir, "commit", "-q", "-m", msg).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("git commit failed: %s", strings.TrimSpace(string(out)))
+	}
+	return changes, nil
+}
+
+// autoPush pushes unpushed commits; it is a no-op without a remote
+func autoPush(dir string) error {
+	if !HasRemote(dir) {
+		return nil
+	}
+
+	pushArgs := []string{"-C", dir, "push", "-q"}
+	if !HasUpstream(dir) {
+		pushArgs = append(pushArgs, "-u", "origin", GetCurrentBranch(dir))
+	} else {
+		out, err := exec.Command("git", "-C", dir, "rev-list", "--count", "@{upstream}..HEAD").Output()
+		if err == nil && strings.TrimSpace(string(out)) == "0" {
+			return nil
+		}
+	}
+
+	if out, err := exec.Command("git", pushArgs...).CombinedOutput(); err != nil {
+		return fmt.Errorf("git push failed: %s", strings.TrimSpace(string(out)))
+	}
+	return pushSeenRefs(dir)
+}
@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+// historySearchDepth bounds the commits scanned for a seen version that
+// predates blob recording
+const historySearchDepth = 100
+
+var (
+	diffUnseen  bool
+	diffContext int
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [file|glob...]",
+	Short: "Show what changed in files since they were last seen",
+	Long: `Print a unified diff from the version an agent last marked seen to the
+current content. Paths resolve like aidb show.
+
+Examples:
+  aidb diff TASK.md            # Changes since TASK.md was seen
+  aidb diff --unseen           # Every file modified since seen
+  aidb diff --unseen --json    # Hunks as JSON
+  aidb diff TASK.md --agent ci # Since ci last saw it`,
+	RunE: runDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().BoolVar(&diffUnseen, "unseen", false, "Diff every file modified since it was seen")
+	diffCmd.Flags().IntVarP(&diffContext, "context", "U", 3, "Lines of context around changes")
+}
+
+// FileDiff is the change in one file since an agent saw it
+type FileDiff struct {
+	Path   string     `json:"path"`
+	Status string     `json:"status"` // modified, unchanged or never-seen
+	SeenAt string     `json:"seenAt,omitempty"`
+	From   string     `json:"from,omitempty"` // git blob of the seen version
+	Hunks  []DiffHunk `json:"hunks"`
+}
+
+// DiffHunk is one @@ section of a unified diff
+type DiffHunk struct {
+	OldStart int      `json:"oldStart"`
+	OldLines int      `json:"oldLines"`
+	NewStart int      `json:"newStart"`
+	NewLines int      `json:"newLines"`
+	Lines    []string `json:"lines"`
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	if diffUnseen == (len(args) > 0) {
+		return fmt.Errorf("specify files or --unseen")
+	}
+
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata: %w", err)
+	}
+	agent := currentAgent()
+
+	var relPaths []string
+	if diffUnseen {
+		idx, paths, err := syncIndex(cfg)
+		if err != nil {
+			return err
+		}
+		for _, relPath := range paths {
+			c := meta.Consumer(relPath, agent)
+			if e := idx.Get(relPath); c != nil && e != nil && c.Hash != e.Hash {
+				relPaths = append(relPaths, relPath)
+			}
+		}
+	} else {
+		for _, pattern := range args {
+			matches, err := resolveShowPattern(cfg, pattern)
+			if err != nil {
+				return err
+			}
+			relPaths = append(relPaths, matches...)
+		}
+	}
+
+	diffs := []FileDiff{}
+	for _, relPath := range relPaths {
+		d, err := diffSinceSeen(cfg.DBDir, relPath, meta.Consumer(relPath, agent), diffContext)
+		if err != nil {
+			return err
+		}
+		diffs = append(diffs, d)
+	}
+
+	if flagJSON {
+		return writeJSON(cmd, diffs)
+	}
+
+	if diffUnseen && len(diffs) == 0 {
+		printInfo("No files modified since seen")
+		return nil
+	}
+
+	out := cmd.OutOrStdout()
+	for _, d := range diffs {
+		switch d.Status {
+		case "never-seen":
+			printInfo(fmt.Sprintf("%s: not seen by %s yet", d.Path, agent))
+		case "unchanged":
+			printInfo(fmt.Sprintf("%s: unchanged since seen", d.Path))
+		default:
+			fmt.Fprintf(out, "--- a/%s\t(seen %s)\n", d.Path, d.SeenAt)
+			fmt.Fprintf(out, "+++ b/%s\n", d.Path)
+			for _, h := range d.Hunks {
+				fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+				for _, line := range h.Lines {
+					fmt.Fprintln(out, line)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// diffSinceSeen compares the seen version of relPath with its current content
+func diffSinceSeen(dbDir, relPath string, c *metadata.ConsumerState, context int) (FileDiff, error) {
+	d := FileDiff{Path: filepath.ToSlash(relPath), Hunks: []DiffHunk{}}
+	if c == nil {
+		d.Status = "never-seen"
+		return d, nil
+	}
+	d.SeenAt = c.SeenAt.Format("2006-01-02T15:04:05Z")
+
+	path := filepath.Join(dbDir, relPath)
+	current, err := os.ReadFile(path)
+	if err != nil {
+		return d, err
+	}
+	if metadata.HashBytes(current) == c.Hash {
+		d.Status = "unchanged"
+		return d, nil
+	}
+
+	blob, err := seenBlob(dbDir, relPath, c)
+	if err != nil {
+		return d, err
+	}
+	old, err := exec.Command("git", "-C", dbDir, "cat-file", "blob", blob).Output()
+	if err != nil {
+		return d, fmt.Errorf("failed to read seen version of %s: %w", relPath, err)
+	}
+
+	tmp, err := os.CreateTemp("", "aidb-seen-*")
+	if err != nil {
+		return d, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(old); err != nil {
+		tmp.Close()
+		return d, err
+	}
+	tmp.Close()
+
+	// --no-index exits 1 when the files differ
+	out, err := exec.Command("git", "diff", "--no-index", "--no-color", "--no-ext-diff",
+		"-U"+strconv.Itoa(context), tmp.Name(), path).Output()
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return d, fmt.Errorf("git diff failed: %w", err)
+	}
+
+	d.Status = "modified"
+	d.From = blob
+	d.Hunks = parseHunks(string(out))
+	return d, nil
+}
+
+// seenBlob locates the git blob holding the content c was recorded for:
+// the stored blob, the file at the stored commit, or a scan of its history
+func seenBlob(dbDir, relPath string, c *metadata.ConsumerState) (string, error) {
+	if c.Blob != "" && exec.Command("git", "-C", dbDir, "cat-file", "-e", c.Blob).Run() == nil {
+		return c.Blob, nil
+	}
+
+	gitPath := filepath.ToSlash(relPath)
+	var commits []string
+	if c.Commit != "" {
+		commits = append(commits, c.Commit)
+	}
+	out, _ := exec.Command("git", "-C", dbDir, "log", "--format=%H",
+		"-n", strconv.Itoa(historySearchDepth), "--", gitPath).Output()
+	commits = append(commits, strings.Fields(string(out))...)
+
+	for _, commit := range commits {
+		blob, err := exec.Command("git", "-C", dbDir, "rev-parse", "-q", "--verify", commit+":"+gitPath).Output()
+		if err != nil {
+			continue
+		}
+		id := strings.TrimSpace(string(blob))
+		data, err := exec.Command("git", "-C", dbDir, "cat-file", "blob", id).Output()
+		if err == nil && metadata.HashBytes(data) == c.Hash {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("seen version of %s is not in git history", relPath)
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parseHunks extracts hunks from unified diff output, ignoring file headers
+func parseHunks(diff string) []DiffHunk {
+	hunks := []DiffHunk{}
+	var cur *DiffHunk
+
+	scanner := bufio.NewScanner(strings.NewReader(diff))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := hunkHeader.FindStringSubmatch(line); m != nil {
+			hunks = append(hunks, DiffHunk{
+				OldStart: atoiOr(m[1], 0),
+				OldLines: atoiOr(m[2], 1),
+				NewStart: atoiOr(m[3], 0),
+				NewLines: atoiOr(m[4], 1),
+				Lines:    []string{},
+			})
+			cur = &hunks[len(hunks)-1]
+			continue
+		}
+		if cur == nil {
+			continue // diff --git, index, ---, +++ headers
+		}
+		if line == "" || strings.ContainsRune(" +-\\", rune(line[0])) {
+			cur.Lines = append(cur.Lines, line)
+		}
+	}
+	return hunks
+}
+
+func atoiOr(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
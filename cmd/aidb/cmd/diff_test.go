@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+func TestDiffCommand_SinceSeen(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { diffUnseen, flagJSON = false, false }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	taskPath := filepath.Join(env.DBDir, "myproject", "feature-x", "TASK.md")
+	env.CreateFile(taskPath, "one\ntwo\nthree\n")
+
+	rootCmd.SetArgs([]string{"seen", "myproject/feature-x/TASK.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("seen command failed: %v", err)
+	}
+	env.CreateFile(taskPath, "one\n2\nthree\nfour\n")
+
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"diff", "TASK.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("diff command failed: %v", err)
+	}
+	for _, want := range []string{"--- a/myproject/feature-x/TASK.md", "-two", "+2", "+four"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("diff missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	rootCmd.SetArgs([]string{"diff", "--unseen", "--json"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("diff command failed: %v", err)
+	}
+	var diffs []FileDiff
+	if err := json.Unmarshal(buf.Bytes(), &diffs); err != nil {
+		t.Fatalf("failed to parse JSON: %v\n%s", err, buf.String())
+	}
+	if len(diffs) != 1 || diffs[0].Status != "modified" || len(diffs[0].Hunks) != 1 {
+		t.Fatalf("diffs = %+v", diffs)
+	}
+	h := diffs[0].Hunks[0]
+	if h.OldStart != 1 || h.OldLines != 3 || h.NewLines != 4 {
+		t.Errorf("hunk = %+v", h)
+	}
+}
+
+func TestSeenBlob_FallsBackToHistory(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	env.InitDBRepo()
+
+	// Seen state recorded before blobs were stored: only the hash is known
+	env.CreateFile(filepath.Join(env.DBDir, "p", "main", "a.md"), "old\n")
+	run(t, env.DBDir, "git", "add", "-A")
+	run(t, env.DBDir, "git", "commit", "-qm", "old")
+	env.CreateFile(filepath.Join(env.DBDir, "p", "main", "a.md"), "new\n")
+	run(t, env.DBDir, "git", "commit", "-qam", "new")
+
+	c := &metadata.ConsumerState{Hash: metadata.HashBytes([]byte("old\n"))}
+	blob, err := seenBlob(env.DBDir, "p/main/a.md", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := gitWriteBlob(env.DBDir, []byte("old\n")); blob != got {
+		t.Errorf("blob = %s, want %s", blob, got)
+	}
+}
+
+func TestParseHunks(t *testing.T) {
+	diff := "diff --git a/x b/x\nindex 1..2 100644\n--- a/x\n+++ b/x\n@@ -1 +1,2 @@\n-a\n+b\n+c\n\\ No newline at end of file\n"
+	hunks := parseHunks(diff)
+	if len(hunks) != 1 {
+		t.Fatalf("hunks = %+v", hunks)
+	}
+	h := hunks[0]
+	if h.OldStart != 1 || h.OldLines != 1 || h.NewStart != 1 || h.NewLines != 2 || len(h.Lines) != 4 {
+		t.Errorf("hunk = %+v", h)
+	}
+}
+
+func TestSeenBlob_ReachableAndPushed(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	remoteDir := setupPullEnv(t, env)
+
+	// Seen before it was ever committed: only the seen blob holds it
+	env.CreateFile(filepath.Join(env.DBDir, "p", "main", "a.md"), "old\n")
+	rootCmd.SetArgs([]string{"seen", "p/main/a.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("seen command failed: %v", err)
+	}
+	meta, _ := metadata.New(env.DBDir)
+	c := meta.Consumer("p/main/a.md", metadata.DefaultAgent)
+	if c == nil || c.Blob == "" {
+		t.Fatalf("seen state = %+v, want a blob", c)
+	}
+
+	run(t, env.DBDir, "git", "gc", "-q", "--prune=now")
+	run(t, env.DBDir, "git", "cat-file", "-e", c.Blob)
+
+	rootCmd.SetArgs([]string{"push"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("push command failed: %v", err)
+	}
+	run(t, remoteDir, "git", "cat-file", "-e", c.Blob)
+	run(t, remoteDir, "git", "rev-parse", "--verify", "refs/aidb/seen/"+c.Blob)
+}
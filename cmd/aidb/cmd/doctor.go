@@ -0,0 +1,427 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var (
+	doctorFix    bool
+	doctorRelink bool
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the database and current checkout for inconsistencies",
+	Long: `Check consistency between the current checkout, the database, its git
+index and .metadata.json.
+
+Problems reported:
+  dangling-symlink     checkout symlink into the database whose file is gone
+  branch-mismatch      checkout symlink into another branch's tree
+  unlinked-file        database file no checkout or recorded link points at
+  orphan-metadata      seen state for a file that no longer exists
+  stale-link           recorded link location that no longer links here
+  uncommitted          changes in the database not yet committed
+  rebase-in-progress   an interrupted pull left a rebase behind
+  no-remote            the database has no remote to sync with
+
+--fix prunes orphan metadata and stale link records, stages uncommitted
+changes and repoints dangling symlinks whose file exists in the default
+branch. Unlinked files of the current branch are only relinked with
+--relink, and only where the checkout path is free.
+
+Examples:
+  aidb doctor
+  aidb doctor --fix
+  aidb doctor --fix --relink
+  aidb doctor --json`,
+	Args:        cobra.NoArgs,
+	RunE:        runDoctor,
+	Annotations: writesDB,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "Repair what can be repaired safely")
+	doctorCmd.Flags().BoolVar(&doctorRelink, "relink", false, "With --fix, symlink unlinked files into the checkout")
+}
+
+// Problem is one inconsistency found by doctor
+type Problem struct {
+	Kind    string `json:"kind"`
+	Path    string `json:"path,omitempty"`
+	Detail  string `json:"detail"`
+	Fixable bool   `json:"fixable"`
+	Fixed   bool   `json:"fixed,omitempty"`
+
+	fix   func() error
+	paths []string // db-relative files the fix changes, to reindex
+}
+
+// DoctorReport is the result of aidb doctor
+type DoctorReport struct {
+	DBDir    string    `json:"dbDir"`
+	Project  string    `json:"project,omitempty"`
+	Branch   string    `json:"branch,omitempty"`
+	Problems []Problem `json:"problems"`
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	if doctorRelink && !doctorFix {
+		return fmt.Errorf("--relink requires --fix")
+	}
+
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(cfg.DBDir, ".git")); err != nil {
+		return fmt.Errorf("aidb not initialized (run 'aidb init' first)")
+	}
+
+	report, err := diagnose(cfg)
+	if err != nil {
+		return err
+	}
+
+	if doctorFix {
+		fixProblems(cfg, report.Problems)
+	}
+
+	if flagJSON {
+		return writeJSON(cmd, report)
+	}
+
+	out := cmd.OutOrStdout()
+	if len(report.Problems) == 0 {
+		printSuccess("No problems found")
+		return nil
+	}
+	remaining, unlinked := 0, 0
+	for _, p := range report.Problems {
+		mark := colorYellow("!")
+		switch {
+		case p.Fixed:
+			mark = colorGreen("✓")
+		case !p.Fixable:
+			mark = colorRed("✗")
+		}
+		if !p.Fixed {
+			remaining++
+			if p.Kind == "unlinked-file" && p.Fixable {
+				unlinked++
+			}
+		}
+		line := fmt.Sprintf("  %s %-18s %s", mark, p.Kind, p.Detail)
+		if p.Path != "" {
+			line += " " + colorGray(p.Path)
+		}
+		fmt.Fprintln(out, line)
+	}
+
+	if remaining > 0 && !doctorFix {
+		printInfo("Run 'aidb doctor --fix' to repair fixable problems")
+	} else if unlinked > 0 && !doctorRelink {
+		printInfo("Run 'aidb doctor --fix --relink' to relink unlinked files")
+	}
+	if remaining > 0 {
+		return fmt.Errorf("%d problem(s) found", remaining)
+	}
+	return nil
+}
+
+// diagnose runs every check; problems carry their own fix
+func diagnose(cfg *config.Config) (*DoctorReport, error) {
+	report := &DoctorReport{DBDir: cfg.DBDir, Problems: []Problem{}}
+	add := func(p Problem) { report.Problems = append(report.Problems, p) }
+
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load metadata: %w", err)
+	}
+	host := hostID()
+	for _, relPath := range sortedMetadataPaths(meta) {
+		target := filepath.Join(cfg.DBDir, relPath)
+		if _, err := os.Stat(target); !os.IsNotExist(err) {
+			for _, l := range meta.Links(relPath) {
+				if l.Host != host {
+					continue
+				}
+				state := linkState(l.Path, target)
+				if state == "ok" {
+					continue
+				}
+				relPath, l := relPath, l
+				add(Problem{
+					Kind:    "stale-link",
+					Path:    l.Path,
+					Detail:  fmt.Sprintf("recorded link to %s is %s", filepath.ToSlash(relPath), state),
+					Fixable: true,
+					fix: func() error {
+						meta.RemoveLink(relPath, l.Host, l.Path)
+						return meta.Save()
+					},
+					paths: []string{relPath},
+				})
+			}
+			continue
+		}
+		relPath := relPath
+		add(Problem{
+			Kind:    "orphan-metadata",
+			Path:    filepath.ToSlash(relPath),
+			Detail:  "seen state for a missing file",
+			Fixable: true,
+			fix: func() error {
+				meta.Remove(relPath)
+				return meta.Save()
+			},
+			paths: []string{relPath},
+		})
+	}
+
+	changes, err := gitStatus(cfg.DBDir)
+	if err != nil {
+		return nil, err
+	}
+	// One git add -A stages every change
+	var staged bool
+	var stageErr error
+	stage := func() error {
+		if !staged {
+			staged = true
+			stageErr = exec.Command("git", "-C", cfg.DBDir, "add", "-A").Run()
+		}
+		return stageErr
+	}
+	for _, c := range changes {
+		add(Problem{
+			Kind:    "uncommitted",
+			Path:    c.Path,
+			Detail:  fmt.Sprintf("git status %q", strings.TrimSpace(c.Status)),
+			Fixable: true,
+			fix:     stage,
+			paths:   []string{c.Path},
+		})
+	}
+
+	if rebaseInProgress(cfg.DBDir) {
+		add(Problem{
+			Kind:   "rebase-in-progress",
+			Detail: "resolve conflicts in the database, then git rebase --continue (or --abort)",
+		})
+	}
+	if !HasRemote(cfg.DBDir) {
+		add(Problem{
+			Kind:   "no-remote",
+			Detail: "set one with aidb config git.remote <url>",
+		})
+	}
+
+	linked := map[string]bool{}
+	var relink func(path, dbRel string) (link string, ok bool)
+	if cfg.IsGitRepo() {
+		if relink, err = diagnoseCheckout(cfg, meta, report, add, linked); err != nil {
+			return nil, err
+		}
+	}
+	diagnoseUnlinked(cfg, meta, add, linked, relink)
+	return report, nil
+}
+
+// diagnoseCheckout checks the symlinks of the current repo's worktrees
+// against the project's branch trees, recording in linked the database
+// files they point at. It returns where a file of the current branch would
+// be linked in this checkout.
+func diagnoseCheckout(cfg *config.Config, meta *metadata.Metadata, report *DoctorReport, add func(Problem), linked map[string]bool) (func(path, dbRel string) (string, bool), error) {
+	proj, err := currentProject(cfg)
+	if err != nil {
+		return nil, err
+	}
+	report.Project, report.Branch = proj.ID, proj.Branch
+
+	cwd, _ := os.Getwd()
+	base := config.DefaultBranch(cwd)
+	projectDir := filepath.Join(cfg.DBDir, filepath.FromSlash(proj.Dir))
+	branchDir := filepath.Join(projectDir, filepath.FromSlash(proj.Branch))
+	baseDir := filepath.Join(projectDir, filepath.FromSlash(base))
+
+	walkCheckoutLinks(func(path, target string) {
+		if !strings.HasPrefix(target, cfg.DBDir+string(filepath.Separator)) {
+			return
+		}
+		linked[target] = true
+
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			p := Problem{Kind: "dangling-symlink", Path: path, Detail: "target is gone: " + target}
+			if rel, ok := relUnderBranch(projectDir, target); ok {
+				if candidate := filepath.Join(baseDir, rel); fileExists(candidate) {
+					dbRel, _ := filepath.Rel(cfg.DBDir, candidate)
+					p.Detail = "target is gone, found in " + base
+					p.Fixable = true
+					p.fix = func() error {
+						if err := replaceLink(path, candidate); err != nil {
+							return err
+						}
+						meta.AddLink(dbRel, hostID(), path)
+						return meta.Save()
+					}
+					p.paths = []string{dbRel}
+					linked[candidate] = true // once repointed
+				}
+			}
+			add(p)
+			return
+		}
+
+		if !strings.HasPrefix(target, projectDir+string(filepath.Separator)) {
+			return
+		}
+		if strings.HasPrefix(target, branchDir+string(filepath.Separator)) || strings.HasPrefix(target, baseDir+string(filepath.Separator)) {
+			return
+		}
+		add(Problem{Kind: "branch-mismatch", Path: path, Detail: "points into another branch: " + target})
+	})
+
+	roots := worktreeRoots()
+	if len(roots) == 0 {
+		return nil, nil
+	}
+	checkout := roots[0]
+	for _, root := range roots {
+		if strings.HasPrefix(cwd+string(filepath.Separator), root+string(filepath.Separator)) {
+			checkout = root
+		}
+	}
+	return func(path, dbRel string) (string, bool) {
+		rel, err := filepath.Rel(branchDir, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", false
+		}
+		return filepath.Join(checkout, rel), true
+	}, nil
+}
+
+// diagnoseUnlinked reports database files nothing links to: no symlink in
+// the current checkout and no recorded link, on this host one that still
+// resolves, on others any. relink, if set, places current branch files in
+// this checkout.
+func diagnoseUnlinked(cfg *config.Config, meta *metadata.Metadata, add func(Problem), linked map[string]bool, relink func(path, dbRel string) (string, bool)) {
+	host := hostID()
+	walkDB(cfg.DBDir, func(path, dbRel string, info os.FileInfo) error {
+		if linked[path] || isAidbPath(filepath.ToSlash(dbRel)) {
+			return nil
+		}
+		for _, l := range meta.Links(dbRel) {
+			if l.Host != host || linkState(l.Path, path) == "ok" {
+				return nil // linked from another checkout or machine
+			}
+		}
+
+		p := Problem{Kind: "unlinked-file", Path: filepath.ToSlash(dbRel), Detail: "no checkout links to it"}
+		link, ok := "", false
+		if relink != nil {
+			link, ok = relink(path, dbRel)
+		}
+		if !ok {
+			add(p)
+			return nil
+		}
+		p.Detail = "not linked at " + link
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			p.Fixable = true
+			if doctorRelink {
+				p.fix = func() error {
+					if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+						return err
+					}
+					if err := os.Symlink(path, link); err != nil {
+						return err
+					}
+					meta.AddLink(dbRel, host, link)
+					return meta.Save()
+				}
+				p.paths = []string{dbRel}
+			}
+		} else {
+			p.Detail = "checkout path is taken: " + link
+		}
+		add(p)
+		return nil
+	})
+}
+
+// fixProblems applies the fix of every fixable problem
+func fixProblems(cfg *config.Config, problems []Problem) {
+	// Written before staging so the runtime ignores are staged too
+	cfg.EnsureGitignore()
+	var touched []string
+	for i := range problems {
+		p := &problems[i]
+		if p.fix == nil {
+			continue
+		}
+		if err := p.fix(); err != nil {
+			printError(fmt.Sprintf("%s %s: %v", p.Kind, p.Path, err))
+			continue
+		}
+		p.Fixed = true
+		touched = append(touched, p.paths...)
+	}
+	updateIndex(cfg, touched...)
+}
+
+func sortedMetadataPaths(meta *metadata.Metadata) []string {
+	paths := make([]string, 0, len(meta.Files))
+	for relPath := range meta.Files {
+		paths = append(paths, relPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// relUnderBranch returns the path of target below its branch directory.
+// Branch names may contain slashes, so known git branches are tried first.
+func relUnderBranch(projectDir, target string) (string, bool) {
+	rel, err := filepath.Rel(projectDir, target)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+	branch := branchOf(rel, gitBranches())
+	rest := strings.TrimPrefix(rel, branch+"/")
+	if rest == rel {
+		return "", false
+	}
+	return filepath.FromSlash(rest), true
+}
+
+func rebaseInProgress(dir string) bool {
+	for _, state := range []string{"rebase-merge", "rebase-apply"} {
+		if _, err := os.Stat(filepath.Join(dir, ".git", state)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func replaceLink(path, target string) error {
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return os.Symlink(target, path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
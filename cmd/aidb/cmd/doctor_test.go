@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+func runDoctorJSON(t *testing.T, args ...string) DoctorReport {
+	t.Helper()
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs(append([]string{"doctor", "--json"}, args...))
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("doctor failed: %v", err)
+	}
+	var report DoctorReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("failed to parse JSON: %v\n%s", err, buf.String())
+	}
+	return report
+}
+
+func problemKinds(report DoctorReport) map[string][]Problem {
+	kinds := map[string][]Problem{}
+	for _, p := range report.Problems {
+		kinds[p.Kind] = append(kinds[p.Kind], p)
+	}
+	return kinds
+}
+
+func TestDoctor(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { doctorFix, doctorRelink, flagJSON = false, false, false }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+
+	feature := filepath.Join(env.DBDir, "myproject", "feature-x")
+	main := filepath.Join(env.DBDir, "myproject", "main")
+
+	// Linked and healthy
+	env.CreateFile(filepath.Join(feature, "TASK.md"), "# Task")
+	if err := os.Symlink(filepath.Join(feature, "TASK.md"), filepath.Join(repoDir, "TASK.md")); err != nil {
+		t.Fatal(err)
+	}
+	// Dangling, but promoted to main
+	env.CreateFile(filepath.Join(main, "NOTES.md"), "notes")
+	if err := os.Symlink(filepath.Join(feature, "NOTES.md"), filepath.Join(repoDir, "NOTES.md")); err != nil {
+		t.Fatal(err)
+	}
+	// Nobody links to it
+	env.CreateFile(filepath.Join(feature, "docs", "PLAN.md"), "plan")
+
+	// Seen state for a file that is gone
+	meta, _ := metadata.New(env.DBDir)
+	meta.MarkSeen(filepath.Join("myproject", "feature-x", "GONE.md"), "claude", "abc")
+	if err := meta.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := problemKinds(runDoctorJSON(t))
+	for _, kind := range []string{"dangling-symlink", "unlinked-file", "orphan-metadata", "uncommitted", "no-remote"} {
+		if len(kinds[kind]) == 0 {
+			t.Errorf("expected a %s problem, got %+v", kind, kinds)
+		}
+	}
+	if len(kinds["unlinked-file"]) != 1 || kinds["unlinked-file"][0].Path != "myproject/feature-x/docs/PLAN.md" {
+		t.Errorf("unlinked = %+v", kinds["unlinked-file"])
+	}
+
+	kinds = problemKinds(runDoctorJSON(t, "--fix", "--relink"))
+	for _, kind := range []string{"dangling-symlink", "unlinked-file", "orphan-metadata", "uncommitted"} {
+		for _, p := range kinds[kind] {
+			if !p.Fixed {
+				t.Errorf("%s %s was not fixed", kind, p.Path)
+			}
+		}
+	}
+
+	if target := env.SymlinkTarget(filepath.Join(repoDir, "NOTES.md")); target != filepath.Join(main, "NOTES.md") {
+		t.Errorf("dangling link repointed to %s", target)
+	}
+	if target := env.SymlinkTarget(filepath.Join(repoDir, "docs", "PLAN.md")); target != filepath.Join(feature, "docs", "PLAN.md") {
+		t.Errorf("unlinked file relinked to %s", target)
+	}
+	meta, _ = metadata.New(env.DBDir)
+	if meta.GetInfo(filepath.Join("myproject", "feature-x", "GONE.md")) != nil {
+		t.Error("orphan metadata should be pruned")
+	}
+	if out, _ := exec.Command("git", "-C", env.DBDir, "status", "--porcelain").Output(); bytes.Contains(out, []byte("??")) {
+		t.Errorf("changes should be staged:\n%s", out)
+	}
+
+	kinds = problemKinds(runDoctorJSON(t))
+	for kind := range kinds {
+		if kind != "uncommitted" && kind != "no-remote" {
+			t.Errorf("unexpected %s after fix: %+v", kind, kinds[kind])
+		}
+	}
+}
+
+func TestDoctor_UnlinkedAcrossDatabase(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { doctorFix, doctorRelink, flagJSON = false, false, false }()
+	t.Setenv("AIDB_HOST", "laptop")
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+
+	// Files of other projects: one linked only on another machine, one
+	// linked from nowhere
+	env.CreateFile(filepath.Join(env.DBDir, "other", "main", "SYNCED.md"), "synced")
+	env.CreateFile(filepath.Join(env.DBDir, "other", "main", "OLD.md"), "old")
+	meta, _ := metadata.New(env.DBDir)
+	meta.AddLink("other/main/SYNCED.md", "desktop", "/home/u/other/SYNCED.md")
+	if err := meta.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	unlinked := problemKinds(runDoctorJSON(t))["unlinked-file"]
+	if len(unlinked) != 1 || unlinked[0].Path != "other/main/OLD.md" || unlinked[0].Fixable {
+		t.Errorf("unlinked = %+v, want only other/main/OLD.md, not fixable", unlinked)
+	}
+}
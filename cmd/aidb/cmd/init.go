@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	"github.com/KakkoiDev/aidb/internal/config"
@@ -15,9 +16,10 @@ var initRemote string
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize aidb database",
-	Long: `Initialize the aidb database at ~/.aidb.
+	Long: `Initialize the aidb database at ~/.aidb (or the configured db.path).
 
 Creates the directory, initializes git, and optionally configures a remote.
+Without --remote, git.remote from the config file is used if set.
 
 Examples:
   aidb init                                    # Initialize ~/.aidb
@@ -45,14 +47,27 @@ func runInit(cmd *cobra.Command, args []string) error {
 	gitCmd := exec.Command("git", "-C", cfg.DBDir, "branch", "-M", "main")
 	gitCmd.Run() // Ignore error if branch doesn't exist yet
 
+	// Merge .metadata.json per file so pulls never conflict on it. The
+	// .gitattributes entry travels with the repo; the driver is per clone.
+	if err := ensureLine(filepath.Join(cfg.DBDir, ".gitattributes"), metadataAttributes); err != nil {
+		return fmt.Errorf("failed to write .gitattributes: %w", err)
+	}
+	if err := ensureMergeDriver(cfg.DBDir); err != nil {
+		return err
+	}
+
 	printSuccess(fmt.Sprintf("Initialized %s", cfg.DBDir))
 
 	// Configure remote if provided
-	if initRemote != "" {
-		if err := configureRemote(cfg.DBDir, initRemote); err != nil {
+	remote := initRemote
+	if remote == "" {
+		remote = cfg.Settings.Git.Remote
+	}
+	if remote != "" {
+		if err := configureRemote(cfg.DBDir, remote); err != nil {
 			return err
 		}
-		printSuccess(fmt.Sprintf("Remote configured: %s", initRemote))
+		printSuccess(fmt.Sprintf("Remote configured: %s", remote))
 	}
 
 	return nil
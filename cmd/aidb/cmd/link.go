@@ -0,0 +1,367 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var (
+	linkDryRun   bool
+	linkAll      bool
+	linkConflict string
+)
+
+var linkCmd = &cobra.Command{
+	Use:   "link",
+	Short: "Recreate symlinks for the current project/branch",
+	Long: `Symlink every file stored under the current project/branch into the
+checkout at the same relative path, e.g. after aidb pull on a new machine.
+
+An existing real file is a conflict. If its content matches the database it
+is replaced by the link; otherwise --conflict decides:
+  skip       leave the file and report it (default)
+  backup     rename it to <file>.aidb-backup, then link
+  overwrite  delete it, then link
+
+Files are linked where they were recorded on this host (see aidb where),
+else at their path below the checkout root. Checkouts are remembered when
+files are added or linked, so --all relinks every known checkout on this
+machine.
+
+Examples:
+  aidb link                      # Link the current checkout
+  aidb link --dry-run            # Show what would change
+  aidb link --conflict backup    # Keep local copies aside
+  aidb link --all                # Every known checkout`,
+	Args:        cobra.NoArgs,
+	RunE:        runLink,
+	Annotations: writesDB,
+}
+
+func init() {
+	rootCmd.AddCommand(linkCmd)
+	linkCmd.Flags().BoolVar(&linkDryRun, "dry-run", false, "Show what would be linked")
+	linkCmd.Flags().BoolVar(&linkAll, "all", false, "Link every known checkout")
+	linkCmd.Flags().StringVar(&linkConflict, "conflict", "skip", "When a different file exists: skip, backup or overwrite")
+}
+
+// LinkResult is the outcome for one file of one checkout
+type LinkResult struct {
+	Checkout string `json:"checkout"`
+	Path     string `json:"path"` // relative to the checkout
+	Target   string `json:"target"`
+	Action   string `json:"action"` // linked, exists, replaced, backed-up, overwritten, conflict
+	Detail   string `json:"detail,omitempty"`
+	Err      string `json:"error,omitempty"`
+}
+
+func runLink(cmd *cobra.Command, args []string) error {
+	switch linkConflict {
+	case "skip", "backup", "overwrite":
+	default:
+		return fmt.Errorf("invalid --conflict: %s (use skip, backup or overwrite)", linkConflict)
+	}
+
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(cfg.DBDir); os.IsNotExist(err) {
+		return fmt.Errorf("aidb not initialized (run 'aidb init' first)")
+	}
+
+	var checkouts []string
+	if linkAll {
+		checkouts, err = knownCheckouts(cfg)
+		if err != nil {
+			return err
+		}
+		if len(checkouts) == 0 {
+			printInfo("No known checkouts (run 'aidb link' inside one first)")
+			return nil
+		}
+	} else {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		root := checkoutRoot(cwd)
+		if root == "" {
+			return fmt.Errorf("not in a git repository")
+		}
+		checkouts = []string{root}
+	}
+
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata: %w", err)
+	}
+
+	results := []LinkResult{}
+	for _, root := range checkouts {
+		rs, err := linkCheckout(cfg, meta, root)
+		if err != nil {
+			printWarning(fmt.Sprintf("%s: %v", root, err))
+			continue
+		}
+		results = append(results, rs...)
+		if !linkDryRun {
+			recordCheckout(cfg, root)
+		}
+	}
+
+	if !linkDryRun {
+		if err := meta.Save(); err != nil {
+			printWarning(fmt.Sprintf("failed to record link locations: %v", err))
+		}
+	}
+
+	if flagJSON {
+		return writeJSON(cmd, results)
+	}
+
+	out := cmd.OutOrStdout()
+	changed, conflicts, failed := 0, 0, 0
+	for _, r := range results {
+		switch {
+		case r.Err != "":
+			failed++
+			printError(fmt.Sprintf("%s: %s", filepath.Join(r.Checkout, r.Path), r.Err))
+			continue
+		case r.Action == "exists":
+			continue
+		case r.Action == "conflict":
+			conflicts++
+		default:
+			changed++
+		}
+		line := fmt.Sprintf("  %-11s %s", r.Action, filepath.Join(r.Checkout, r.Path))
+		if r.Detail != "" {
+			line += " " + colorGray(r.Detail)
+		}
+		fmt.Fprintln(out, line)
+	}
+
+	verb := "Linked"
+	if linkDryRun {
+		verb = "Would link"
+	}
+	printSuccess(fmt.Sprintf("%s %d file(s) in %d checkout(s)", verb, changed, len(checkouts)))
+	if conflicts > 0 {
+		printWarning(fmt.Sprintf("%d file(s) differ from the database (use --conflict backup or overwrite)", conflicts))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d file(s) could not be linked", failed)
+	}
+	return nil
+}
+
+// linkCheckout links every stored file of root's project/branch into root
+// and records the links in meta
+func linkCheckout(cfg *config.Config, meta *metadata.Metadata, root string) ([]LinkResult, error) {
+	p, err := cfg.ResolveProject(root)
+	if err != nil {
+		return nil, err
+	}
+	if p.Source == "path" {
+		return nil, fmt.Errorf("not a git repository")
+	}
+	if !linkDryRun {
+		if err := cfg.SaveProject(p); err != nil {
+			return nil, err
+		}
+	}
+	branchDir := filepath.Join(cfg.DBDir, filepath.FromSlash(p.Dir), filepath.FromSlash(p.Branch))
+
+	host := hostID()
+	var results []LinkResult
+	err = walkDB(branchDir, func(target, rel string, info os.FileInfo) error {
+		// Knowledge files live only in the database
+		if isAidbPath(filepath.ToSlash(rel)) {
+			return nil
+		}
+		dbRel, _ := filepath.Rel(cfg.DBDir, target)
+
+		paths := recordedLinks(meta, dbRel, host, root)
+		if len(paths) == 0 {
+			paths = []string{filepath.Join(root, rel)}
+		}
+		for _, path := range paths {
+			r := linkFile(root, path, target)
+			if r.Err == "" && r.Action != "conflict" && !linkDryRun {
+				meta.AddLink(dbRel, host, path)
+			}
+			results = append(results, r)
+		}
+		return nil
+	})
+	return results, err
+}
+
+// recordedLinks returns the link locations of dbRel recorded on host inside root
+func recordedLinks(meta *metadata.Metadata, dbRel, host, root string) []string {
+	var paths []string
+	for _, l := range meta.Links(dbRel) {
+		if l.Host == host && strings.HasPrefix(l.Path, root+string(filepath.Separator)) {
+			paths = append(paths, l.Path)
+		}
+	}
+	return paths
+}
+
+// linkFile creates the symlink path -> target, resolving conflicts per --conflict
+func linkFile(root, path, target string) LinkResult {
+	rel, _ := filepath.Rel(root, path)
+	r := LinkResult{Checkout: root, Path: filepath.ToSlash(rel), Target: target, Action: "linked"}
+
+	info, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		r.Err = err.Error()
+		return r
+	case info.Mode()&os.ModeSymlink != 0:
+		existing, _ := os.Readlink(path)
+		if existing == target {
+			r.Action = "exists"
+			return r
+		}
+		r.Detail = "symlink to " + existing
+		if !resolveLinkConflict(&r, path) {
+			return r
+		}
+	case info.IsDir():
+		r.Action = "conflict"
+		r.Detail = "a directory is in the way"
+		return r
+	default:
+		local, err1 := os.ReadFile(path)
+		stored, err2 := os.ReadFile(target)
+		if err1 == nil && err2 == nil && bytes.Equal(local, stored) {
+			r.Action = "replaced"
+			r.Detail = "identical local copy"
+			if !linkDryRun {
+				if err := os.Remove(path); err != nil {
+					r.Err = err.Error()
+					return r
+				}
+			}
+		} else if !resolveLinkConflict(&r, path) {
+			return r
+		}
+	}
+
+	if linkDryRun {
+		return r
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		r.Err = err.Error()
+		return r
+	}
+	if err := os.Symlink(target, path); err != nil {
+		r.Err = err.Error()
+	}
+	return r
+}
+
+// resolveLinkConflict clears path according to --conflict, reporting
+// whether linking can go ahead
+func resolveLinkConflict(r *LinkResult, path string) bool {
+	switch linkConflict {
+	case "backup":
+		r.Action = "backed-up"
+		backup := path + ".aidb-backup"
+		r.Detail = "local copy kept as " + filepath.Base(backup)
+		if linkDryRun {
+			return true
+		}
+		if _, err := os.Lstat(backup); err == nil {
+			r.Err = backup + " already exists"
+			return false
+		}
+		if err := os.Rename(path, backup); err != nil {
+			r.Err = err.Error()
+			return false
+		}
+		return true
+	case "overwrite":
+		r.Action = "overwritten"
+		if linkDryRun {
+			return true
+		}
+		if err := os.Remove(path); err != nil {
+			r.Err = err.Error()
+			return false
+		}
+		return true
+	default:
+		r.Action = "conflict"
+		if r.Detail == "" {
+			r.Detail = "differs from the database"
+		}
+		return false
+	}
+}
+
+// checkoutRoot returns the working tree root of dir, or "" outside git
+func checkoutRoot(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// knownCheckouts returns the recorded checkouts that still exist
+func knownCheckouts(cfg *config.Config) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(cfg.DBDir, config.CheckoutsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var roots []string
+	if err := json.Unmarshal(data, &roots); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", config.CheckoutsFile, err)
+	}
+
+	var existing []string
+	for _, root := range roots {
+		if info, err := os.Stat(root); err == nil && info.IsDir() {
+			existing = append(existing, root)
+		}
+	}
+	return existing, nil
+}
+
+// recordCheckout remembers root for link --all; failures are not fatal
+func recordCheckout(cfg *config.Config, root string) {
+	roots, err := knownCheckouts(cfg)
+	if err != nil {
+		printDebug(fmt.Sprintf("checkouts: %v", err))
+		return
+	}
+	for _, r := range roots {
+		if r == root {
+			return
+		}
+	}
+	roots = append(roots, root)
+	sort.Strings(roots)
+
+	data, _ := json.MarshalIndent(roots, "", "  ")
+	if err := os.WriteFile(filepath.Join(cfg.DBDir, config.CheckoutsFile), append(data, '\n'), 0644); err != nil {
+		printDebug(fmt.Sprintf("checkouts: %v", err))
+	}
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+func TestLinkCommand(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { linkDryRun, linkAll, linkConflict = false, false, "skip" }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+
+	branchDir := filepath.Join(env.DBDir, "myproject", "feature-x")
+	env.CreateFile(filepath.Join(branchDir, "TASK.md"), "# Task")
+	env.CreateFile(filepath.Join(branchDir, "docs", "PLAN.md"), "plan")
+	env.CreateFile(filepath.Join(branchDir, "SAME.md"), "same")
+	env.CreateFile(filepath.Join(branchDir, "LOCAL.md"), "stored")
+	env.CreateFile(filepath.Join(branchDir, "_aidb", "insight.md"), "knowledge")
+	env.CreateFile(filepath.Join(repoDir, "SAME.md"), "same")
+	env.CreateFile(filepath.Join(repoDir, "LOCAL.md"), "local edits")
+
+	rootCmd.SetArgs([]string{"link", "--dry-run"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("link --dry-run failed: %v", err)
+	}
+	if env.IsSymlink(filepath.Join(repoDir, "TASK.md")) {
+		t.Fatal("--dry-run must not create links")
+	}
+	linkDryRun = false // flag globals persist across Execute calls
+
+	rootCmd.SetArgs([]string{"link"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("link failed: %v", err)
+	}
+	for _, rel := range []string{"TASK.md", filepath.Join("docs", "PLAN.md"), "SAME.md"} {
+		if target := env.SymlinkTarget(filepath.Join(repoDir, rel)); target != filepath.Join(branchDir, rel) {
+			t.Errorf("%s -> %s", rel, target)
+		}
+	}
+	if env.IsSymlink(filepath.Join(repoDir, "LOCAL.md")) {
+		t.Error("a differing local file must be kept by default")
+	}
+	if env.FileExists(filepath.Join(repoDir, "_aidb")) {
+		t.Error("knowledge files should not be linked")
+	}
+
+	rootCmd.SetArgs([]string{"link", "--conflict", "backup"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("link --conflict backup failed: %v", err)
+	}
+	if !env.IsSymlink(filepath.Join(repoDir, "LOCAL.md")) {
+		t.Error("LOCAL.md should be linked after backup")
+	}
+	if got := env.ReadFile(filepath.Join(repoDir, "LOCAL.md.aidb-backup")); got != "local edits" {
+		t.Errorf("backup = %q", got)
+	}
+}
+
+func TestLinkCommand_All(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { linkAll = false }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	env.CreateFile(filepath.Join(repoDir, "TASK.md"), "# Task")
+
+	rootCmd.SetArgs([]string{"add", "TASK.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+
+	// Simulate a fresh machine: the checkout has lost its links
+	if err := os.Remove(filepath.Join(repoDir, "TASK.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(env.WorkDir); err != nil {
+		t.Fatal(err)
+	}
+
+	rootCmd.SetArgs([]string{"link", "--all"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("link --all failed: %v", err)
+	}
+	if !env.IsSymlink(filepath.Join(repoDir, "TASK.md")) {
+		t.Error("add should have recorded the checkout for --all")
+	}
+}
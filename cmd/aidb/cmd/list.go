@@ -5,17 +5,23 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/frontmatter"
+	"github.com/KakkoiDev/aidb/internal/index"
 	"github.com/KakkoiDev/aidb/internal/metadata"
 	"github.com/spf13/cobra"
 )
 
 var (
-	listUnseen bool
-	listJSON   bool
-	listAidb   bool
+	listUnseen  bool
+	listJSON    bool
+	listAidb    bool
+	listInherit bool
+	listTags    []string
 )
 
 var listCmd = &cobra.Command{
@@ -28,7 +34,18 @@ Examples:
   aidb list --unseen        # List only unseen files
   aidb list --aidb          # List only _aidb/ knowledge files
   aidb list --unseen --aidb # Unseen knowledge files only
-  aidb list --json          # Output as JSON`,
+  aidb list --json          # Output as JSON
+  aidb list --inherit       # Current branch over the default branch
+  aidb list --tag auth --tag db # Files tagged both auth and db
+
+--inherit lists only the current project: the current branch's files plus
+the default branch's files it does not shadow, marked with their branch.
+
+Seen state is per agent (--agent or AIDB_AGENT); --json reports every
+agent that has seen the current content in seenBy.
+
+Markdown files report their YAML frontmatter (title, tags, summary, expires,
+confidence, source) in --json; other files carry tags set with aidb tag.`,
 	RunE: runList,
 }
 
@@ -37,14 +54,34 @@ func init() {
 	listCmd.Flags().BoolVar(&listUnseen, "unseen", false, "Show only unseen files")
 	listCmd.Flags().BoolVar(&listJSON, "json", false, "Output as JSON")
 	listCmd.Flags().BoolVar(&listAidb, "aidb", false, "Show only _aidb/ knowledge files")
+	listCmd.Flags().BoolVar(&listInherit, "inherit", false, "Overlay the current branch on the default branch")
+	listCmd.Flags().StringSliceVar(&listTags, "tag", nil, "Show only files with this tag (repeatable; all must match)")
 }
 
 type FileEntry struct {
-	Path     string `json:"path"`
-	Seen     bool   `json:"seen"`
-	Hash     string `json:"hash,omitempty"`
-	SeenAt   string `json:"seenAt,omitempty"`
-	Modified bool   `json:"modified,omitempty"`
+	Path     string   `json:"path"`
+	Seen     bool     `json:"seen"`
+	Hash     string   `json:"hash,omitempty"`
+	SeenAt   string   `json:"seenAt,omitempty"`
+	Modified bool     `json:"modified,omitempty"`
+	SeenBy   []string `json:"seenBy,omitempty"`
+
+	InheritedFrom string `json:"inheritedFrom,omitempty"` // branch the file shows through from
+
+	Due   string `json:"due,omitempty"`   // when the file needs review (expires, review-after)
+	Stale bool   `json:"stale,omitempty"` // due has passed
+
+	frontmatter.Fields // Markdown frontmatter; tags from metadata for other files
+}
+
+// listOptions selects which tracked files listEntries returns
+type listOptions struct {
+	Unseen bool   `json:"unseen"` // only unseen files
+	Aidb   bool   `json:"aidb"`   // only _aidb/ files (otherwise _aidb/ files are excluded)
+	Agent  string `json:"agent"`  // whose seen state to report (default: current agent)
+
+	Inherit bool     `json:"inherit"` // current project only, default branch beneath the current one
+	Tags    []string `json:"tags"`    // only files with all of these tags
 }
 
 func runList(cmd *cobra.Command, args []string) error {
@@ -62,104 +99,274 @@ func runList(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	meta, err := metadata.New(cfg.DBDir)
+	entries, err := listEntries(cfg, listOptions{Unseen: listUnseen, Aidb: listAidb, Inherit: listInherit, Tags: listTags})
 	if err != nil {
-		return fmt.Errorf("failed to load metadata: %w", err)
+		return err
 	}
 
-	var entries []FileEntry
+	if listJSON {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
 
-	err = filepath.Walk(cfg.DBDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
+	if len(entries) == 0 {
+		if listUnseen {
+			printInfo("No unseen files")
+		} else {
+			printInfo("No tracked files")
 		}
-		if info.IsDir() {
-			// Skip .git directory only (allow .aidb root and other dirs)
-			if info.Name() == ".git" {
-				return filepath.SkipDir
-			}
-			return nil
+		return nil
+	}
+
+	for _, e := range entries {
+		status := colorGray("○")
+		if e.Seen {
+			status = colorGreen("●")
 		}
-		// Skip metadata file
-		if info.Name() == ".metadata.json" {
-			return nil
+		if e.Modified {
+			status = colorYellow("◐")
+		}
+		if e.Stale {
+			status = colorRed("◌")
+		}
+
+		line := fmt.Sprintf("  %s %s", status, e.Path)
+		if len(e.Tags) > 0 {
+			line += " " + colorGray("["+strings.Join(e.Tags, ", ")+"]")
+		}
+		if e.InheritedFrom != "" {
+			line += " " + colorGray("(from "+e.InheritedFrom+")")
 		}
+		fmt.Println(line)
+	}
+
+	return nil
+}
+
+// listEntries returns the tracked files matching opts with their seen state
+func listEntries(cfg *config.Config, opts listOptions) ([]FileEntry, error) {
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load metadata: %w", err)
+	}
+
+	idx, paths, err := syncIndex(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	agent := agentOr(opts.Agent)
+	var entries []FileEntry
 
-		relPath, _ := filepath.Rel(cfg.DBDir, path)
+	var inherited map[string]string
+	if opts.Inherit {
+		if paths, inherited, err = inheritPaths(cfg, paths); err != nil {
+			return nil, err
+		}
+	}
 
-		// Check if file is in _aidb/ directory
-		isAidbFile := strings.Contains(relPath, "/_aidb/") || strings.HasPrefix(relPath, "_aidb/")
+	for _, relPath := range paths {
+		isAidbFile := isAidbPath(relPath)
 
 		// Filter based on --aidb flag
-		if listAidb && !isAidbFile {
-			return nil // --aidb flag: skip non-aidb files
+		if opts.Aidb && !isAidbFile {
+			continue // --aidb flag: skip non-aidb files
 		}
-		if !listAidb && isAidbFile {
-			return nil // no --aidb flag: skip aidb files
+		if !opts.Aidb && isAidbFile {
+			continue // no --aidb flag: skip aidb files
 		}
 
-		// Get current hash
-		currentHash, _ := metadata.HashFile(path)
+		// Get current hash and frontmatter (cached unless the file changed)
+		var currentHash string
+		var front frontmatter.Fields
+		if e := idx.Get(relPath); e != nil {
+			currentHash = e.Hash
+			if e.Front != nil {
+				front = *e.Front
+			}
+		}
+		if !frontmatter.IsMarkdown(relPath) {
+			front.Tags = meta.Tags(relPath)
+		}
+		if !hasTags(front.Tags, opts.Tags) {
+			continue
+		}
 
-		// Check seen status
-		seen := meta.IsSeen(relPath, currentHash)
-		fileInfo := meta.GetInfo(relPath)
+		// Check seen status for this agent; overdue files are re-queued
+		due := meta.Due(relPath, front.Due())
+		seen := meta.IsSeen(relPath, agent, currentHash, due)
 
 		entry := FileEntry{
-			Path: relPath,
-			Seen: seen,
+			Path:          relPath,
+			Seen:          seen,
+			SeenBy:        meta.SeenBy(relPath, currentHash, due),
+			InheritedFrom: inherited[relPath],
+			Fields:        front,
+		}
+		if !due.IsZero() {
+			entry.Due = due.Format(metadata.DateFormat)
+			entry.Stale = !time.Now().Before(due)
 		}
 
-		if fileInfo != nil {
-			entry.Hash = fileInfo.Hash
-			if !fileInfo.SeenAt.IsZero() {
-				entry.SeenAt = fileInfo.SeenAt.Format("2006-01-02T15:04:05Z")
+		if c := meta.Consumer(relPath, agent); c != nil {
+			entry.Hash = c.Hash
+			if !c.SeenAt.IsZero() {
+				entry.SeenAt = c.SeenAt.Format("2006-01-02T15:04:05Z")
 			}
 			// Check if modified since last seen
-			if fileInfo.Hash != "" && fileInfo.Hash != currentHash {
+			if c.Hash != "" && c.Hash != currentHash {
 				entry.Modified = true
 			}
 		}
 
 		// Filter unseen if requested
-		if listUnseen && seen {
-			return nil
+		if opts.Unseen && seen {
+			continue
 		}
 
 		entries = append(entries, entry)
-		return nil
-	})
+	}
+
+	return entries, nil
+}
+
+// hasTags reports whether tags include every wanted tag
+func hasTags(tags, wanted []string) bool {
+	for _, w := range frontmatter.Normalize(wanted) {
+		if !slices.Contains(tags, w) {
+			return false
+		}
+	}
+	return true
+}
 
+// inheritPaths narrows paths to the current project's branch, overlaid on the
+// default branch: default-branch files not shadowed by a file of the same name
+// are kept and reported in the returned map with their branch
+func inheritPaths(cfg *config.Config, paths []string) ([]string, map[string]string, error) {
+	p, err := currentProject(cfg)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	cwd, _ := os.Getwd()
+	base := config.DefaultBranch(cwd)
 
-	if listJSON {
-		enc := json.NewEncoder(cmd.OutOrStdout())
-		enc.SetIndent("", "  ")
-		return enc.Encode(entries)
+	branchPrefix := p.Dir + "/" + p.Branch + "/"
+	basePrefix := p.Dir + "/" + base + "/"
+	own := map[string]bool{}
+	for _, relPath := range paths {
+		if rest, ok := strings.CutPrefix(filepath.ToSlash(relPath), branchPrefix); ok {
+			own[rest] = true
+		}
 	}
 
-	if len(entries) == 0 {
-		if listUnseen {
-			printInfo("No unseen files")
-		} else {
-			printInfo("No tracked files")
+	var kept []string
+	inherited := map[string]string{}
+	for _, relPath := range paths {
+		slash := filepath.ToSlash(relPath)
+		if strings.HasPrefix(slash, branchPrefix) {
+			kept = append(kept, relPath)
+			continue
+		}
+		if rest, ok := strings.CutPrefix(slash, basePrefix); ok && p.Branch != base && !own[rest] {
+			kept = append(kept, relPath)
+			inherited[relPath] = base
 		}
-		return nil
 	}
+	return kept, inherited, nil
+}
 
-	for _, e := range entries {
-		status := colorGray("○")
-		if e.Seen {
-			status = colorGreen("●")
+// walkDB calls fn for every tracked file in the database,
+// skipping .git, metadata and runtime files
+func walkDB(dbDir string, fn func(path, relPath string, info os.FileInfo) error) error {
+	return filepath.Walk(dbDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
 		}
-		if e.Modified {
-			status = colorYellow("◐")
+		relPath, _ := filepath.Rel(dbDir, path)
+
+		// Allow .aidb root and other dirs
+		if config.IsInternalPath(relPath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
 		}
+		return fn(path, relPath, info)
+	})
+}
+
+// syncIndex brings the on-disk index up to date with the database, rereading
+// only files whose size or mtime changed. Paths are returned in walk order.
+func syncIndex(cfg *config.Config) (*index.Index, []string, error) {
+	idx, err := index.Open(cfg.DBDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load index: %w", err)
+	}
 
-		fmt.Printf("  %s %s\n", status, e.Path)
+	var paths []string
+	present := make(map[string]bool)
+	err = walkDB(cfg.DBDir, func(path, relPath string, info os.FileInfo) error {
+		if _, err := idx.Update(relPath); err != nil {
+			printDebug(fmt.Sprintf("index %s: %v", relPath, err))
+			return nil
+		}
+		paths = append(paths, relPath)
+		present[relPath] = true
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
+	idx.Prune(present)
 
-	return nil
+	saveIndex(cfg, idx)
+	return idx, paths, nil
+}
+
+// updateIndex refreshes the index entries of db-relative paths a command just changed
+func updateIndex(cfg *config.Config, relPaths ...string) {
+	idx, err := index.Open(cfg.DBDir)
+	if err != nil {
+		printDebug(fmt.Sprintf("index: %v", err))
+		return
+	}
+	for _, relPath := range relPaths {
+		if _, err := idx.Update(relPath); err != nil {
+			printDebug(fmt.Sprintf("index %s: %v", relPath, err))
+		}
+	}
+	saveIndex(cfg, idx)
+}
+
+// saveIndex persists the index; it is only a cache, so failures are not fatal
+func saveIndex(cfg *config.Config, idx *index.Index) {
+	if _, err := os.Stat(filepath.Join(cfg.DBDir, ".git")); err == nil {
+		cfg.EnsureGitignore()
+	}
+	if err := idx.Save(); err != nil {
+		printDebug(fmt.Sprintf("index save: %v", err))
+	}
+}
+
+// dbFilePath resolves a db-relative path to an absolute path, rejecting
+// paths that escape the database or point at internal files
+func dbFilePath(dbDir, relPath string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(relPath))
+	if filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path: %s", relPath)
+	}
+	if config.IsInternalPath(clean) {
+		return "", fmt.Errorf("not a tracked file: %s", relPath)
+	}
+	return filepath.Join(dbDir, clean), nil
+}
+
+// isAidbPath returns true if the file is in a project or global _aidb/ knowledge tier
+func isAidbPath(relPath string) bool {
+	return strings.Contains(relPath, "/_aidb/") || strings.HasPrefix(relPath, "_aidb/")
 }
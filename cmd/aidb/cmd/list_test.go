@@ -196,3 +196,143 @@ func TestListCommand_AidbFlag_WithUnseen(t *testing.T) {
 		}
 	}
 }
+
+func TestListCommand_PerAgentSeen(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	// Flag globals persist across Execute calls
+	listAidb = false
+	defer func() { flagAgent, listUnseen, listAidb, listJSON = "", false, false, false }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "main")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	env.CreateFile(filepath.Join(env.DBDir, "myproject", "main", "TASK.md"), "# Task")
+
+	rootCmd.SetArgs([]string{"seen", "myproject/main/TASK.md", "--agent", "claude"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("seen command failed: %v", err)
+	}
+
+	list := func(agent string) []FileEntry {
+		t.Helper()
+		var buf bytes.Buffer
+		rootCmd.SetOut(&buf)
+		rootCmd.SetArgs([]string{"list", "--unseen", "--json", "--agent", agent})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("list command failed: %v", err)
+		}
+		var entries []FileEntry
+		if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+			t.Fatalf("failed to parse JSON: %v", err)
+		}
+		return entries
+	}
+
+	if entries := list("claude"); len(entries) != 0 {
+		t.Errorf("claude has seen everything, got %+v", entries)
+	}
+
+	entries := list("cursor")
+	if len(entries) != 1 {
+		t.Fatalf("cursor should still see TASK.md as unseen, got %+v", entries)
+	}
+	if len(entries[0].SeenBy) != 1 || entries[0].SeenBy[0] != "claude" {
+		t.Errorf("seenBy = %v, want [claude]", entries[0].SeenBy)
+	}
+}
+
+func TestListCommand_Inherit(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	listAidb = false
+	defer func() { listInherit, listJSON = false, false }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	env.CreateFile(filepath.Join(env.DBDir, "myproject", "main", "TASK.md"), "main task")
+	env.CreateFile(filepath.Join(env.DBDir, "myproject", "main", "NOTES.md"), "main notes")
+	env.CreateFile(filepath.Join(env.DBDir, "myproject", "feature-x", "TASK.md"), "feature task")
+	env.CreateFile(filepath.Join(env.DBDir, "other", "main", "TASK.md"), "other project")
+
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"list", "--inherit", "--json"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("list command failed: %v", err)
+	}
+
+	var entries []FileEntry
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatalf("failed to parse JSON: %v", err)
+	}
+
+	got := map[string]string{}
+	for _, e := range entries {
+		got[filepath.ToSlash(e.Path)] = e.InheritedFrom
+	}
+	want := map[string]string{
+		"myproject/feature-x/TASK.md": "",
+		"myproject/main/NOTES.md":     "main",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("entries = %v, want %v", got, want)
+	}
+	for path, from := range want {
+		if f, ok := got[path]; !ok || f != from {
+			t.Errorf("%s: inheritedFrom = %q (present %v), want %q", path, f, ok, from)
+		}
+	}
+}
+
+func TestListCommand_Tags(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	listAidb = false
+	defer func() { listTags, listJSON = nil, false; rootCmd.SetOut(nil) }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	dir := filepath.Join(env.DBDir, "myproject", "feature-x")
+	env.CreateFile(filepath.Join(dir, "AUTH.md"), "---\ntitle: Login\ntags: [auth, db]\nsummary: How login works\n---\n# Auth\n")
+	env.CreateFile(filepath.Join(dir, "API.md"), "---\ntags: [auth]\n---\n# API\n")
+	env.CreateFile(filepath.Join(dir, "schema.sql"), "create table users;")
+
+	rootCmd.SetArgs([]string{"tag", "/myproject/feature-x/schema.sql", "DB", "auth"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("tag failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"list", "--tag", "auth", "--tag", "db", "--json"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("list command failed: %v", err)
+	}
+	var entries []FileEntry
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatalf("failed to parse JSON: %v", err)
+	}
+
+	got := map[string]FileEntry{}
+	for _, e := range entries {
+		got[filepath.Base(e.Path)] = e
+	}
+	if len(got) != 2 {
+		t.Fatalf("entries = %v, want AUTH.md and schema.sql", got)
+	}
+	if e := got["AUTH.md"]; e.Title != "Login" || e.Summary != "How login works" {
+		t.Errorf("AUTH.md frontmatter = %+v", e.Fields)
+	}
+	if tags := got["schema.sql"].Tags; len(tags) != 2 || tags[0] != "auth" || tags[1] != "db" {
+		t.Errorf("schema.sql tags = %v, want [auth db]", tags)
+	}
+}
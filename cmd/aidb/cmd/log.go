@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var logLimit int
+
+var logCmd = &cobra.Command{
+	Use:   "log [file]",
+	Short: "Show the commit history of tracked files",
+	Long: `List the commits that touched a tracked file, newest first, following
+renames. The file is a symlink in the current directory or a tracked file
+as aidb show resolves it. Without a file, commits touching the current
+project/branch are listed.
+
+Examples:
+  aidb log TASK.md
+  aidb log TASK.md --json
+  aidb log -n 5`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runLog,
+}
+
+func init() {
+	logCmd.Flags().IntVarP(&logLimit, "max-count", "n", 20, "Show at most this many commits (0 for all)")
+	rootCmd.AddCommand(logCmd)
+}
+
+// LogEntry is one commit touching tracked files
+type LogEntry struct {
+	Commit  string   `json:"commit"`
+	Date    string   `json:"date"`
+	Author  string   `json:"author"`
+	Subject string   `json:"subject"`
+	Paths   []string `json:"paths"` // db-relative, as named in that commit
+}
+
+func runLog(cmd *cobra.Command, args []string) error {
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+
+	var entries []LogEntry
+	if len(args) == 1 {
+		relPath, err := resolveHistoryPath(cfg, args[0])
+		if err != nil {
+			return err
+		}
+		entries, err = fileHistory(cfg.DBDir, relPath, "", logLimit)
+		if err != nil {
+			return err
+		}
+	} else {
+		scope := "."
+		if path, err := cfg.GetStoragePath("."); err == nil {
+			if _, err := os.Stat(path); err == nil {
+				scope, _ = filepath.Rel(cfg.DBDir, path)
+			}
+		}
+		entries, err = gitLog(cfg.DBDir, []string{"--", filepath.ToSlash(scope)}, logLimit)
+		if err != nil {
+			return err
+		}
+	}
+
+	if flagJSON {
+		return writeJSON(cmd, entries)
+	}
+	if len(entries) == 0 {
+		printInfo("No history yet (commit with aidb commit)")
+		return nil
+	}
+
+	out := cmd.OutOrStdout()
+	for _, e := range entries {
+		fmt.Fprintf(out, "%s %s %s", colorYellow(e.Commit[:7]), e.Date[:10], e.Subject)
+		if len(args) == 1 && len(e.Paths) == 1 {
+			fmt.Fprintf(out, "  %s", colorGray(e.Paths[0]))
+		}
+		fmt.Fprintln(out)
+	}
+	return nil
+}
+
+// resolveHistoryPath resolves a file argument to a db-relative path: a
+// symlink into the database, a tracked file, or a stored path that may no
+// longer exist but has history
+func resolveHistoryPath(cfg *config.Config, arg string) (string, error) {
+	if relPath, ok := linkedDBPath(cfg, arg); ok {
+		return relPath, nil
+	}
+	if matches, err := resolveShowPattern(cfg, arg); err == nil {
+		if len(matches) > 1 {
+			return "", fmt.Errorf("%s matches %d files; name one", arg, len(matches))
+		}
+		return matches[0], nil
+	}
+
+	var candidates []string
+	if strings.HasPrefix(arg, "/") {
+		candidates = append(candidates, strings.TrimPrefix(arg, "/"))
+	} else {
+		if path, err := cfg.GetStoragePath(arg); err == nil {
+			if relPath, err := filepath.Rel(cfg.DBDir, path); err == nil {
+				candidates = append(candidates, relPath)
+			}
+		}
+		candidates = append(candidates, arg)
+	}
+	for _, relPath := range candidates {
+		if _, err := dbFilePath(cfg.DBDir, relPath); err != nil {
+			continue
+		}
+		if entries, err := fileHistory(cfg.DBDir, relPath, "", 1); err == nil && len(entries) > 0 {
+			return relPath, nil
+		}
+	}
+	return "", fmt.Errorf("no tracked file or history matches: %s", arg)
+}
+
+// fileHistory lists commits touching relPath, following renames. before
+// limits it to commits at or before a date git understands.
+func fileHistory(dbDir, relPath, before string, limit int) ([]LogEntry, error) {
+	args := []string{"--follow"}
+	if before != "" {
+		args = append(args, "--before="+before)
+	}
+	args = append(args, "--", filepath.ToSlash(relPath))
+	return gitLog(dbDir, args, limit)
+}
+
+// gitLog runs git log in the database and parses commits with the paths
+// they touched
+func gitLog(dbDir string, args []string, limit int) ([]LogEntry, error) {
+	entries := []LogEntry{}
+	if gitHead(dbDir) == "" {
+		return entries, nil
+	}
+
+	gitArgs := []string{"-C", dbDir, "log", "--format=%x1e%H%x1f%aI%x1f%an%x1f%s", "--name-only"}
+	if limit > 0 {
+		gitArgs = append(gitArgs, "-n", strconv.Itoa(limit))
+	}
+	out, err := exec.Command("git", append(gitArgs, args...)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log failed: %w", err)
+	}
+
+	for _, record := range strings.Split(string(out), "\x1e") {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		fields := strings.Split(lines[0], "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		e := LogEntry{Commit: fields[0], Date: fields[1], Author: fields[2], Subject: fields[3], Paths: []string{}}
+		for _, line := range lines[1:] {
+			if line = strings.TrimSpace(line); line != "" {
+				e.Paths = append(e.Paths, line)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
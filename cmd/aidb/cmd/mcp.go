@@ -0,0 +1,429 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/journal"
+	"github.com/KakkoiDev/aidb/internal/mcp"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/KakkoiDev/aidb/internal/search"
+	"github.com/spf13/cobra"
+)
+
+// resourceScheme prefixes db-relative paths in resource URIs (aidb://project/branch/file.md)
+const resourceScheme = "aidb://"
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve the knowledge base over MCP (stdio)",
+	Long: `Run a Model Context Protocol server on stdin/stdout.
+
+Tools: list, search, read, seen, unseen, add_knowledge, commit.
+Resources: every tracked file as aidb://{project}/{branch}/{path}.
+
+Example client configuration:
+  {"mcpServers": {"aidb": {"command": "aidb", "args": ["mcp"]}}}`,
+	Args: cobra.NoArgs,
+	RunE: runMCP,
+}
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+}
+
+func runMCP(cmd *cobra.Command, args []string) error {
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+	// stdout carries the protocol, so handlers must never print to it
+	return newMCPServer(cfg).Serve(context.Background(), cmd.InOrStdin(), cmd.OutOrStdout())
+}
+
+// newMCPServer wires the knowledge base into an MCP server
+func newMCPServer(cfg *config.Config) *mcp.Server {
+	s := &mcp.Server{
+		Name:    "aidb",
+		Version: version,
+		ListResources: func() ([]mcp.Resource, error) {
+			return mcpResources(cfg)
+		},
+		ReadResource: func(uri string) (string, string, error) {
+			if !strings.HasPrefix(uri, resourceScheme) {
+				return "", "", fmt.Errorf("unsupported uri: %s", uri)
+			}
+			relPath := strings.TrimPrefix(uri, resourceScheme)
+			text, err := readDBFile(cfg, relPath)
+			return text, mimeType(relPath), err
+		},
+	}
+
+	s.AddTool(mcp.Tool{
+		Name:        "list",
+		Description: "List tracked files with seen state and frontmatter (title, tags, summary...). By default _aidb/ knowledge files are excluded; set aidb to list only them.",
+		InputSchema: schema(map[string]interface{}{
+			"unseen": prop("boolean", "Only files not yet seen or modified since seen"),
+			"aidb":   prop("boolean", "Only _aidb/ knowledge files"),
+			"agent":  prop("string", "Whose seen state to report (default: the server's agent)"),
+			"tags":   arrayProp("Only files with all of these tags"),
+		}),
+		Handler: func(raw json.RawMessage) (interface{}, error) {
+			var args listOptions
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return nil, err
+			}
+			if !dbExists(cfg) {
+				return []FileEntry{}, nil
+			}
+			return listEntries(cfg, args)
+		},
+	})
+
+	s.AddTool(mcp.Tool{
+		Name:        "search",
+		Description: "Full-text search over all tracked files, ranked by BM25, with snippets.",
+		InputSchema: schema(map[string]interface{}{
+			"query":   prop("string", "Search terms"),
+			"project": prop("string", "Only files of this project"),
+			"aidb":    prop("boolean", "Only _aidb/ knowledge files"),
+			"unseen":  prop("boolean", "Only unseen files"),
+			"limit":   prop("integer", "Maximum results (default 20)"),
+			"agent":   prop("string", "Whose seen state to use (default: the server's agent)"),
+		}, "query"),
+		Handler: func(raw json.RawMessage) (interface{}, error) {
+			var args struct {
+				Query string `json:"query"`
+				searchOptions
+			}
+			args.Limit = 20
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return nil, err
+			}
+			query := search.Tokenize(args.Query)
+			if len(query) == 0 {
+				return nil, fmt.Errorf("query is required")
+			}
+			if !dbExists(cfg) {
+				return []SearchHit{}, nil
+			}
+			return searchDB(cfg, query, args.searchOptions)
+		},
+	})
+
+	s.AddTool(mcp.Tool{
+		Name:        "read",
+		Description: "Read a tracked file by its database-relative path (as returned by list/search).",
+		InputSchema: schema(map[string]interface{}{
+			"path": prop("string", "Database-relative path, e.g. myproject/main/TASK.md"),
+		}, "path"),
+		Handler: func(raw json.RawMessage) (interface{}, error) {
+			var args struct {
+				Path string `json:"path"`
+			}
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return nil, err
+			}
+			return readDBFile(cfg, args.Path)
+		},
+	})
+
+	s.AddTool(mcp.Tool{
+		Name:        "seen",
+		Description: "Mark files (paths or globs) as processed at their current content.",
+		InputSchema: schema(map[string]interface{}{
+			"paths": arrayProp("Database-relative paths or globs"),
+			"agent": prop("string", "Agent to record (default: the server's agent)"),
+		}, "paths"),
+		Handler: func(raw json.RawMessage) (interface{}, error) {
+			return mcpMark(cfg, raw, true)
+		},
+	})
+
+	s.AddTool(mcp.Tool{
+		Name:        "unseen",
+		Description: "Re-queue files (paths or globs) for processing.",
+		InputSchema: schema(map[string]interface{}{
+			"paths": arrayProp("Database-relative paths or globs"),
+			"agent": prop("string", "Agent to clear (default: the server's agent)"),
+		}, "paths"),
+		Handler: func(raw json.RawMessage) (interface{}, error) {
+			return mcpMark(cfg, raw, false)
+		},
+	})
+
+	s.AddTool(mcp.Tool{
+		Name:        "add_knowledge",
+		Description: "Write a knowledge file into the project _aidb/ tier (for the server's working directory) or the global _aidb/ tier, and stage it.",
+		InputSchema: schema(map[string]interface{}{
+			"name":      prop("string", "File name relative to the _aidb/ directory, e.g. patterns.md"),
+			"content":   prop("string", "File content"),
+			"scope":     prop("string", "project (default) or global"),
+			"overwrite": prop("boolean", "Replace the file if it exists"),
+		}, "name", "content"),
+		Handler: func(raw json.RawMessage) (interface{}, error) {
+			return mcpAddKnowledge(cfg, raw)
+		},
+	})
+
+	s.AddTool(mcp.Tool{
+		Name:        "commit",
+		Description: "Commit staged changes in the knowledge base.",
+		InputSchema: schema(map[string]interface{}{
+			"message": prop("string", "Commit message"),
+		}, "message"),
+		Handler: func(raw json.RawMessage) (interface{}, error) {
+			var args struct {
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(args.Message) == "" {
+				return nil, fmt.Errorf("commit message cannot be empty")
+			}
+			if !dbExists(cfg) {
+				return nil, fmt.Errorf("aidb not initialized (run 'aidb init' first)")
+			}
+			committed, err := commitStaged(cfg.DBDir, args.Message, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			if !committed {
+				return "Nothing staged to commit", nil
+			}
+			return "Committed", nil
+		},
+	})
+
+	return s
+}
+
+// mcpResources lists every tracked file as a resource
+func mcpResources(cfg *config.Config) ([]mcp.Resource, error) {
+	if !dbExists(cfg) {
+		return nil, nil
+	}
+	_, paths, err := syncIndex(cfg)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]mcp.Resource, 0, len(paths))
+	for _, relPath := range paths {
+		resources = append(resources, mcp.Resource{
+			URI:      resourceScheme + filepath.ToSlash(relPath),
+			Name:     filepath.ToSlash(relPath),
+			MimeType: mimeType(relPath),
+		})
+	}
+	return resources, nil
+}
+
+// mcpMark handles the seen/unseen tools
+func mcpMark(cfg *config.Config, raw json.RawMessage, seen bool) (interface{}, error) {
+	var args struct {
+		Paths []string `json:"paths"`
+		Agent string   `json:"agent"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	return markPaths(cfg, args.Paths, agentOr(args.Agent), seen)
+}
+
+// markResult reports which files markPaths changed
+type markResult struct {
+	Marked []string `json:"marked"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// markPaths marks files matching db-relative paths or globs as seen by agent
+// at their current content, or unseen
+func markPaths(cfg *config.Config, patterns []string, agent string, seen bool) (*markResult, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("paths is required")
+	}
+
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load metadata: %w", err)
+	}
+
+	kind := "unseen"
+	if seen {
+		kind = "seen"
+	}
+	j, err := journal.Begin(cfg.DBDir, kind, patterns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start journal: %w", err)
+	}
+
+	result := &markResult{Marked: []string{}}
+	for _, pattern := range patterns {
+		matches, err := expandDBPattern(cfg.DBDir, pattern)
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		for _, path := range matches {
+			relPath, err := filepath.Rel(cfg.DBDir, path)
+			if err != nil {
+				continue
+			}
+			if err := j.Meta(relPath, meta.GetInfo(relPath)); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: failed to journal: %v", relPath, err))
+				continue
+			}
+			if seen {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					result.Errors = append(result.Errors, fmt.Sprintf("%s: file not found", relPath))
+					continue
+				}
+				markSeen(meta, cfg.DBDir, relPath, agent, data)
+			} else {
+				meta.MarkUnseen(relPath, agent)
+			}
+			result.Marked = append(result.Marked, relPath)
+		}
+	}
+
+	if len(result.Marked) > 0 {
+		if err := meta.Save(); err != nil {
+			j.Rollback()
+			return nil, fmt.Errorf("failed to save metadata: %w", err)
+		}
+		updateIndex(cfg, result.Marked...)
+	}
+	j.Commit()
+	return result, nil
+}
+
+// mcpAddKnowledge writes and stages a file in a _aidb/ tier
+func mcpAddKnowledge(cfg *config.Config, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		Name      string `json:"name"`
+		Content   string `json:"content"`
+		Scope     string `json:"scope"`
+		Overwrite bool   `json:"overwrite"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+
+	name := filepath.Clean(filepath.FromSlash(args.Name))
+	if args.Name == "" || filepath.IsAbs(name) || name == "." || strings.HasPrefix(name, "..") {
+		return nil, fmt.Errorf("invalid name: %s", args.Name)
+	}
+
+	if err := cfg.EnsureDBDir(); err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	var tierDir string
+	switch args.Scope {
+	case "", "project":
+		storageDir, err := cfg.EnsureStorageDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create storage dir: %w", err)
+		}
+		tierDir = filepath.Join(storageDir, "_aidb")
+	case "global":
+		tierDir = filepath.Join(cfg.DBDir, "_aidb")
+	default:
+		return nil, fmt.Errorf("invalid scope: %s (use project or global)", args.Scope)
+	}
+
+	dstPath := filepath.Join(tierDir, name)
+	if _, err := os.Stat(dstPath); err == nil && !args.Overwrite {
+		return nil, fmt.Errorf("already exists: %s (set overwrite to replace)", name)
+	}
+	relPath, _ := filepath.Rel(cfg.DBDir, dstPath)
+	if err := writeDBFile(cfg, relPath, []byte(args.Content)); err != nil {
+		return nil, err
+	}
+	return map[string]string{"path": filepath.ToSlash(relPath)}, nil
+}
+
+// writeDBFile writes a file by db-relative path and stages it
+func writeDBFile(cfg *config.Config, relPath string, data []byte) error {
+	path, err := dbFilePath(cfg.DBDir, relPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	if err := exec.Command("git", "-C", cfg.DBDir, "add", path).Run(); err != nil {
+		return fmt.Errorf("%s: git add failed", relPath)
+	}
+	updateIndex(cfg, relPath)
+	return nil
+}
+
+// readDBFile returns the content of a tracked file by db-relative path
+func readDBFile(cfg *config.Config, relPath string) (string, error) {
+	path, err := dbFilePath(cfg.DBDir, relPath)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("file not found: %s", relPath)
+		}
+		return "", err
+	}
+	return string(data), nil
+}
+
+func dbExists(cfg *config.Config) bool {
+	_, err := os.Stat(cfg.DBDir)
+	return err == nil
+}
+
+func mimeType(relPath string) string {
+	switch strings.ToLower(filepath.Ext(relPath)) {
+	case ".md", ".markdown":
+		return "text/markdown"
+	case ".json":
+		return "application/json"
+	case ".yaml", ".yml":
+		return "application/yaml"
+	default:
+		return "text/plain"
+	}
+}
+
+// schema builds a JSON Schema object with the given properties
+func schema(props map[string]interface{}, required ...string) map[string]interface{} {
+	s := map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func prop(typ, description string) map[string]interface{} {
+	return map[string]interface{}{"type": typ, "description": description}
+}
+
+func arrayProp(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "array",
+		"items":       map[string]string{"type": "string"},
+		"description": description,
+	}
+}
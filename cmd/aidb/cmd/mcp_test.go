@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+func TestMCPServer_ReadSeenAndAddKnowledge(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "main")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	env.CreateFile(filepath.Join(env.DBDir, "myproject", "main", "TASK.md"), "# Task")
+
+	cfg, err := config.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newMCPServer(cfg)
+
+	responses := mcpRoundTrip(t, s,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read","arguments":{"path":"myproject/main/TASK.md"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"read","arguments":{"path":"../etc/passwd"}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"seen","arguments":{"paths":["myproject/main/TASK.md"]}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"add_knowledge","arguments":{"name":"patterns.md","content":"# Patterns","scope":"global"}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"resources/read","params":{"uri":"aidb://_aidb/patterns.md"}}`,
+	)
+
+	if text, isErr := toolText(responses[0]); isErr || text != "# Task" {
+		t.Errorf("read = %q (error %v)", text, isErr)
+	}
+	if _, isErr := toolText(responses[1]); !isErr {
+		t.Error("reading outside the database should fail")
+	}
+
+	meta, _ := metadata.New(env.DBDir)
+	hash, _ := metadata.HashFile(filepath.Join(env.DBDir, "myproject", "main", "TASK.md"))
+	if !meta.IsSeen("myproject/main/TASK.md", metadata.DefaultAgent, hash, time.Time{}) {
+		t.Error("seen tool should mark the file seen")
+	}
+
+	if _, isErr := toolText(responses[3]); isErr {
+		t.Errorf("add_knowledge failed: %v", responses[3])
+	}
+	if got := env.ReadFile(filepath.Join(env.DBDir, "_aidb", "patterns.md")); got != "# Patterns" {
+		t.Errorf("knowledge file = %q", got)
+	}
+	contents := responses[4]["result"].(map[string]interface{})["contents"].([]interface{})
+	if contents[0].(map[string]interface{})["text"] != "# Patterns" {
+		t.Errorf("resource contents = %v", contents)
+	}
+}
+
+func mcpRoundTrip(t *testing.T, s interface {
+	Serve(ctx context.Context, r io.Reader, w io.Writer) error
+}, requests ...string) []map[string]interface{} {
+	t.Helper()
+	var out bytes.Buffer
+	if err := s.Serve(context.Background(), strings.NewReader(strings.Join(requests, "\n")+"\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	var responses []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var resp map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("bad response %q: %v", line, err)
+		}
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+// toolText extracts the text content and error flag of a tools/call response
+func toolText(resp map[string]interface{}) (string, bool) {
+	result, ok := resp["result"].(map[string]interface{})
+	if !ok {
+		return "", true
+	}
+	content := result["content"].([]interface{})[0].(map[string]interface{})
+	return content["text"].(string), result["isError"] == true
+}
+
+func TestMCPServer_SeenStaysInDatabase(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	env.CreateFile(filepath.Join(env.DBDir, "TOP.md"), "# Top")
+	env.CreateFile(filepath.Join(filepath.Dir(env.DBDir), "outside.txt"), "secret")
+
+	cfg, err := config.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses := mcpRoundTrip(t, newMCPServer(cfg),
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"seen","arguments":{"paths":["../outside.txt"]}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"seen","arguments":{"paths":["*"]}}}`,
+	)
+
+	var result markResult
+	text, _ := toolText(responses[0])
+	if err := json.Unmarshal([]byte(text), &result); err != nil || len(result.Marked) != 0 || len(result.Errors) != 1 {
+		t.Errorf("seen ../outside.txt = %s", text)
+	}
+	text, _ = toolText(responses[1])
+	if err := json.Unmarshal([]byte(text), &result); err != nil || strings.Join(result.Marked, " ") != "TOP.md" {
+		t.Errorf("seen * = %s, want only TOP.md", text)
+	}
+
+	meta, _ := metadata.New(env.DBDir)
+	for relPath := range meta.Files {
+		if relPath != "TOP.md" {
+			t.Errorf("metadata has %q", relPath)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// mergeDriverName is the git merge driver registered for .metadata.json
+	mergeDriverName = "aidb-meta"
+	// metadataAttributes routes .metadata.json through the merge driver
+	metadataAttributes = ".metadata.json merge=" + mergeDriverName
+)
+
+var mergeMetadataCmd = &cobra.Command{
+	Use:   "merge-metadata <base> <ours> <theirs>",
+	Short: "Git merge driver for .metadata.json",
+	Long: `Three-way merge of .metadata.json, invoked by git as the aidb-meta merge driver.
+
+Seen state is merged per file and agent; when both sides changed the same
+agent's state the later seenAt wins. The result is written to <ours>.`,
+	Args:   cobra.ExactArgs(3),
+	Hidden: true,
+	RunE:   runMergeMetadata,
+}
+
+func init() {
+	rootCmd.AddCommand(mergeMetadataCmd)
+}
+
+func runMergeMetadata(cmd *cobra.Command, args []string) error {
+	var versions [3]*metadata.Metadata
+	for i, path := range args {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		m, err := metadata.Parse(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		versions[i] = m
+	}
+
+	merged := metadata.Merge(versions[0], versions[1], versions[2])
+	return merged.SaveAs(args[1])
+}
+
+// mergeDriverCmd is the command git runs with the base, ours and theirs
+// versions. It names this binary by absolute path, since whatever runs git
+// (an IDE, cron, the daemon) may not have aidb on its PATH.
+func mergeDriverCmd() string {
+	aidbPath, err := os.Executable()
+	if err != nil {
+		aidbPath = "aidb"
+	}
+	return shellQuote(aidbPath) + " merge-metadata %O %A %B"
+}
+
+// shellQuote quotes s for the shell git runs merge drivers with
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ensureMergeDriver registers the .metadata.json merge driver in the repo
+// config and .git/info/attributes so pulls never conflict on metadata. It
+// runs on init and pull, so a moved or reinstalled binary is picked up.
+// The local attributes file is used because creating an untracked
+// .gitattributes right before a pull would block checking out the remote's.
+func ensureMergeDriver(dir string) error {
+	key := "merge." + mergeDriverName + ".driver"
+	driver := mergeDriverCmd()
+	out, err := exec.Command("git", "-C", dir, "config", "--local", key).Output()
+	if err != nil || strings.TrimSpace(string(out)) != driver {
+		if err := exec.Command("git", "-C", dir, "config", "--local", key, driver).Run(); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+		exec.Command("git", "-C", dir, "config", "--local", "merge."+mergeDriverName+".name", "aidb metadata merge").Run()
+	}
+	return ensureLine(filepath.Join(dir, ".git", "info", "attributes"), metadataAttributes)
+}
+
+// ensureLine appends line to the file at path unless it is already present
+func ensureLine(path, line string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, existing := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(existing) == line {
+			return nil
+		}
+	}
+	content := string(data)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content+line+"\n"), 0644)
+}
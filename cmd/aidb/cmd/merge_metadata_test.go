@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+func TestMergeMetadataCommand(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	base := write("base", `{"version": 1, "files": {}}`)
+	ours := write("ours", `{"version": 2, "files": {
+  "a.md": {"consumers": {"claude": {"hash": "sha256:a", "seenAt": "2025-01-01T00:00:00Z"}}}}}`)
+	theirs := write("theirs", `{"version": 2, "files": {
+  "b.md": {"consumers": {"cursor": {"hash": "sha256:b", "seenAt": "2025-01-02T00:00:00Z"}}}}}`)
+
+	rootCmd.SetArgs([]string{"merge-metadata", base, ours, theirs})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("merge-metadata failed: %v", err)
+	}
+
+	data, err := os.ReadFile(ours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := metadata.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.IsSeen("a.md", "claude", "sha256:a", time.Time{}) || !m.IsSeen("b.md", "cursor", "sha256:b", time.Time{}) {
+		t.Errorf("merged result missing entries:\n%s", data)
+	}
+}
+
+func TestPullCommand_RegistersMergeDriver(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	setupPullEnv(t, env)
+
+	// A driver registered by an older binary relying on PATH is replaced
+	exec.Command("git", "-C", env.DBDir, "config", "--local", "merge.aidb-meta.driver", "aidb merge-metadata %O %A %B").Run()
+
+	rootCmd.SetArgs([]string{"pull"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("pull command failed: %v", err)
+	}
+
+	out, err := exec.Command("git", "-C", env.DBDir, "config", "--local", "merge.aidb-meta.driver").Output()
+	driver := strings.TrimSpace(string(out))
+	if err != nil || driver != mergeDriverCmd() {
+		t.Errorf("merge driver = %q, %v", driver, err)
+	}
+	if exe, _ := os.Executable(); !strings.HasPrefix(driver, "'"+exe+"' ") {
+		t.Errorf("merge driver %q should run %s by absolute path", driver, exe)
+	}
+
+	out, err = exec.Command("git", "-C", env.DBDir, "check-attr", "merge", ".metadata.json").Output()
+	if err != nil || !strings.Contains(string(out), "merge: aidb-meta") {
+		t.Errorf("check-attr = %q, %v", out, err)
+	}
+	if env.FileExists(filepath.Join(env.DBDir, ".gitattributes")) {
+		t.Error("pull should not create an untracked .gitattributes")
+	}
+}
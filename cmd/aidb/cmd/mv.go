@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/fsutil"
+	"github.com/KakkoiDev/aidb/internal/journal"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var mvCmd = &cobra.Command{
+	Use:   "mv <old>... <new>",
+	Short: "Rename or relocate tracked files",
+	Long: `Move tracked files within the database, keeping their history, seen state
+and links.
+
+<old> is a symlink in the checkout or a tracked file as aidb show resolves
+it. <new> is a checkout path, stored like aidb add stores it, and the
+symlink moves along; a leading / makes it a database path instead, e.g.
+another project, branch, or the global _aidb/ tier. Other symlinks to the
+file are repointed in place. Files moved into an _aidb/ tier are not linked.
+
+The stored file is moved with git mv, so git log --follow sees a rename.
+
+Examples:
+  aidb mv TASK.md PLAN.md
+  aidb mv notes.md docs/
+  aidb mv notes.md /_aidb/notes.md          # Promote to the global tier
+  aidb mv TASK.md /github.com/o/r/main/     # Into another branch tree`,
+	Args:        cobra.MinimumNArgs(2),
+	RunE:        runMv,
+	Annotations: writesDB,
+}
+
+func init() {
+	rootCmd.AddCommand(mvCmd)
+}
+
+// MoveResult is one moved file
+type MoveResult struct {
+	From  string   `json:"from"`
+	To    string   `json:"to"`
+	Links []string `json:"links"` // symlinks now pointing at the new location
+}
+
+// mvSource is a file to move and, if named by its symlink, that symlink
+type mvSource struct {
+	target string
+	link   string
+}
+
+func runMv(cmd *cobra.Command, args []string) error {
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	var sources []mvSource
+	for _, arg := range args[:len(args)-1] {
+		if relPath, ok := linkedDBPath(cfg, arg); ok {
+			link, _ := filepath.Abs(arg)
+			sources = append(sources, mvSource{target: filepath.Join(cfg.DBDir, relPath), link: link})
+			continue
+		}
+		matches, err := resolveShowPattern(cfg, arg)
+		if err != nil {
+			return err
+		}
+		for _, relPath := range matches {
+			sources = append(sources, mvSource{target: filepath.Join(cfg.DBDir, relPath)})
+		}
+	}
+
+	dest := args[len(args)-1]
+	inDBDest := strings.HasPrefix(dest, "/")
+	var dstPath, dstLink string
+	if inDBDest {
+		dstPath = filepath.Join(cfg.DBDir, strings.TrimPrefix(dest, "/"))
+	} else {
+		storageDir, err := cfg.EnsureStorageDir()
+		if err != nil {
+			return fmt.Errorf("failed to resolve storage dir: %w", err)
+		}
+		dstLink = filepath.Join(cwd, dest)
+		rel, err := filepath.Rel(cwd, dstLink)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("destination must be inside the current directory: %s", dest)
+		}
+		dstPath = filepath.Join(storageDir, rel)
+	}
+	if _, err := dbFilePath(cfg.DBDir, mustRel(cfg.DBDir, dstPath)); err != nil {
+		return err
+	}
+
+	// A trailing slash or an existing directory keeps the file names
+	intoDir := strings.HasSuffix(dest, "/") || isDir(dstPath) || (dstLink != "" && isDir(dstLink))
+	if len(sources) > 1 && !intoDir {
+		return fmt.Errorf("moving several files needs a directory destination (end it with /)")
+	}
+
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata: %w", err)
+	}
+	j, err := journal.Begin(cfg.DBDir, "mv", args)
+	if err != nil {
+		return fmt.Errorf("failed to start journal: %w", err)
+	}
+
+	results := []MoveResult{}
+	var changed []string
+	for _, src := range sources {
+		to, link := dstPath, dstLink
+		if intoDir {
+			to = filepath.Join(dstPath, filepath.Base(src.target))
+			if link != "" {
+				link = filepath.Join(dstLink, filepath.Base(src.target))
+			}
+		}
+		// Only a named symlink follows a checkout destination
+		if src.link == "" {
+			link = ""
+		}
+
+		res, err := moveTracked(cfg, meta, j, src, to, link)
+		if err != nil && j.Len() == 0 {
+			j.Commit()
+			return fmt.Errorf("%s: %w", mustRel(cfg.DBDir, src.target), err)
+		}
+		if err != nil {
+			rollbackJournal(cfg, j)
+			return fmt.Errorf("%s: %w (all files of this mv were restored)", mustRel(cfg.DBDir, src.target), err)
+		}
+		results = append(results, res)
+		changed = append(changed, res.From, res.To)
+	}
+
+	if err := meta.Save(); err != nil {
+		rollbackJournal(cfg, j)
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+	updateIndex(cfg, changed...)
+	if err := j.Commit(); err != nil {
+		printWarning(fmt.Sprintf("failed to finish journal: %v", err))
+	}
+
+	if flagJSON {
+		return writeJSON(cmd, results)
+	}
+	for _, r := range results {
+		printSuccess(fmt.Sprintf("Moved %s -> %s", r.From, r.To))
+	}
+	return nil
+}
+
+// moveTracked moves one database file to dst, re-keying its metadata and
+// moving or repointing its symlinks on this host. newLink, when set, is
+// where the symlink named by src.link moves to.
+func moveTracked(cfg *config.Config, meta *metadata.Metadata, j *journal.Entry, src mvSource, dst, newLink string) (MoveResult, error) {
+	fromRel := mustRel(cfg.DBDir, src.target)
+	toRel := mustRel(cfg.DBDir, dst)
+	res := MoveResult{From: filepath.ToSlash(fromRel), To: filepath.ToSlash(toRel), Links: []string{}}
+
+	if src.target == dst {
+		return res, fmt.Errorf("source and destination are the same")
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return res, fmt.Errorf("%s already exists", res.To)
+	}
+	if newLink != "" && newLink != src.link {
+		if _, err := os.Lstat(newLink); err == nil {
+			return res, fmt.Errorf("%s already exists", newLink)
+		}
+	}
+
+	// Symlinks to the file on this host: recorded ones, the one named, and
+	// any in the current repository's worktrees
+	host := hostID()
+	links := map[string]bool{}
+	for _, l := range meta.Links(fromRel) {
+		if l.Host == host && linkState(l.Path, src.target) == "ok" {
+			links[l.Path] = true
+		}
+	}
+	if src.link != "" {
+		links[src.link] = true
+	}
+	walkCheckoutLinks(func(path, target string) {
+		if target == src.target {
+			links[path] = true
+		}
+	})
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return res, err
+	}
+	if err := j.Meta(fromRel, meta.GetInfo(fromRel)); err != nil {
+		return res, fmt.Errorf("failed to journal: %w", err)
+	}
+	if err := j.Meta(toRel, meta.GetInfo(toRel)); err != nil {
+		return res, fmt.Errorf("failed to journal: %w", err)
+	}
+	if err := j.Move(src.target, dst); err != nil {
+		return res, fmt.Errorf("failed to journal: %w", err)
+	}
+	// git mv keeps the rename visible to git log --follow; untracked files
+	// are moved directly and staged
+	if err := exec.Command("git", "-C", cfg.DBDir, "mv", src.target, dst).Run(); err != nil {
+		if err := fsutil.Move(src.target, dst); err != nil {
+			return res, fmt.Errorf("failed to move: %w", err)
+		}
+		exec.Command("git", "-C", cfg.DBDir, "add", "-A", "--", src.target, dst).Run()
+	}
+	meta.Move(fromRel, toRel)
+
+	knowledge := isAidbPath(filepath.ToSlash(toRel))
+	for path := range links {
+		if err := j.Unlink(path, src.target); err != nil {
+			return res, fmt.Errorf("failed to journal: %w", err)
+		}
+		if err := os.Remove(path); err != nil {
+			return res, fmt.Errorf("failed to remove symlink: %w", err)
+		}
+		meta.RemoveLink(toRel, host, path)
+		if knowledge {
+			continue
+		}
+
+		linkPath := path
+		if path == src.link && newLink != "" {
+			linkPath = newLink
+			if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
+				return res, err
+			}
+		}
+		if err := j.Symlink(linkPath, dst); err != nil {
+			return res, fmt.Errorf("failed to journal: %w", err)
+		}
+		if err := os.Symlink(dst, linkPath); err != nil {
+			return res, fmt.Errorf("failed to create symlink: %w", err)
+		}
+		meta.AddLink(toRel, host, linkPath)
+		res.Links = append(res.Links, linkPath)
+	}
+
+	pruneEmptyParents(filepath.Dir(src.target), cfg.DBDir)
+	return res, nil
+}
+
+// mustRel returns path relative to base, or path itself if it is not below it
+func mustRel(base, path string) string {
+	if rel, err := filepath.Rel(base, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+func TestMvCommand_Rename(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	env.CreateFile(filepath.Join(repoDir, "TASK.md"), "task")
+
+	rootCmd.SetArgs([]string{"add", "TASK.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	rootCmd.SetArgs([]string{"seen", "myproject/feature-x/TASK.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("seen failed: %v", err)
+	}
+
+	rootCmd.SetArgs([]string{"mv", "TASK.md", "docs/PLAN.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("mv failed: %v", err)
+	}
+
+	oldLink := filepath.Join(repoDir, "TASK.md")
+	newLink := filepath.Join(repoDir, "docs", "PLAN.md")
+	newFile := filepath.Join(env.DBDir, "myproject", "feature-x", "docs", "PLAN.md")
+	if _, err := os.Lstat(oldLink); !os.IsNotExist(err) {
+		t.Error("old symlink should be gone")
+	}
+	if got := env.SymlinkTarget(newLink); got != newFile {
+		t.Errorf("new symlink -> %q, want %q", got, newFile)
+	}
+	if got := env.ReadFile(newLink); got != "task" {
+		t.Errorf("content = %q, want %q", got, "task")
+	}
+
+	meta, _ := metadata.New(env.DBDir)
+	newRel := "myproject/feature-x/docs/PLAN.md"
+	if meta.GetInfo("myproject/feature-x/TASK.md") != nil {
+		t.Error("old metadata entry should be re-keyed")
+	}
+	if !meta.IsSeen(newRel, metadata.DefaultAgent, metadata.HashBytes([]byte("task")), time.Time{}) {
+		t.Error("seen state should follow the file")
+	}
+	if links := meta.Links(newRel); len(links) != 1 || links[0].Path != newLink {
+		t.Errorf("links = %v, want [%s]", links, newLink)
+	}
+
+	// Staged as a rename
+	out, _ := exec.Command("git", "-C", env.DBDir, "status", "--porcelain").Output()
+	if !strings.Contains(string(out), "PLAN.md") {
+		t.Errorf("git status = %q, want the move staged", out)
+	}
+}
+
+func TestMvCommand_GlobalTier(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	env.CreateFile(filepath.Join(repoDir, "notes.md"), "notes")
+
+	rootCmd.SetArgs([]string{"add", "notes.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+
+	rootCmd.SetArgs([]string{"mv", "notes.md", "/_aidb/"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("mv failed: %v", err)
+	}
+
+	if got := env.ReadFile(filepath.Join(env.DBDir, "_aidb", "notes.md")); got != "notes" {
+		t.Errorf("global copy = %q, want %q", got, "notes")
+	}
+	if _, err := os.Lstat(filepath.Join(repoDir, "notes.md")); !os.IsNotExist(err) {
+		t.Error("knowledge files are not linked; the symlink should be removed")
+	}
+
+	// And back again via undo
+	rootCmd.SetArgs([]string{"undo"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if !env.IsSymlink(filepath.Join(repoDir, "notes.md")) {
+		t.Error("undo should restore the symlink")
+	}
+	if got := env.ReadFile(filepath.Join(repoDir, "notes.md")); got != "notes" {
+		t.Errorf("content after undo = %q, want %q", got, "notes")
+	}
+}
+
+func TestMvCommand_Exists(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	env.CreateFile(filepath.Join(repoDir, "A.md"), "a")
+	env.CreateFile(filepath.Join(repoDir, "B.md"), "b")
+
+	rootCmd.SetArgs([]string{"add", "A.md", "B.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+
+	rootCmd.SetArgs([]string{"mv", "A.md", "B.md"})
+	if err := rootCmd.Execute(); err == nil {
+		t.Fatal("mv onto a tracked file should fail")
+	}
+	if got := env.ReadFile(filepath.Join(repoDir, "A.md")); got != "a" {
+		t.Errorf("A.md = %q, want it untouched", got)
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var projectList bool
+
+var projectCmd = &cobra.Command{
+	Use:   "project",
+	Short: "Show the project identity of the current directory",
+	Long: `Print how the current directory maps into the database.
+
+Git repos with an origin remote are identified by the normalized URL
+(host/owner/repo), so two checkouts named api/ no longer collide and renaming
+a checkout keeps its knowledge. Without a remote the repo directory name is
+used. Identities are recorded in .projects.json by commands that store files;
+a tree created before this is adopted under its old directory name when its
+recorded links point into this repo.
+
+Examples:
+  aidb project          # Identity, branch and storage directory
+  aidb project --list   # All recorded identity mappings
+  aidb project --json`,
+	Args: cobra.NoArgs,
+	RunE: runProject,
+}
+
+func init() {
+	rootCmd.AddCommand(projectCmd)
+	projectCmd.Flags().BoolVar(&projectList, "list", false, "List recorded identity mappings")
+}
+
+func runProject(cmd *cobra.Command, args []string) error {
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+
+	if projectList {
+		mappings, err := cfg.ProjectMappings()
+		if err != nil {
+			return fmt.Errorf("failed to read project mappings: %w", err)
+		}
+		if flagJSON {
+			return writeJSON(cmd, mappings)
+		}
+		if len(mappings) == 0 {
+			printInfo("No recorded projects")
+			return nil
+		}
+		for _, id := range config.SortedProjectIDs(mappings) {
+			fmt.Fprintf(cmd.OutOrStdout(), "  %s -> %s\n", id, mappings[id])
+		}
+		return nil
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	p, err := cfg.ResolveProject(cwd)
+	if err != nil {
+		return err
+	}
+	storage := filepath.Join(cfg.DBDir, filepath.FromSlash(p.Dir), p.Branch)
+
+	if flagJSON {
+		return writeJSON(cmd, struct {
+			*config.Project
+			Storage string `json:"storage"`
+		}{p, storage})
+	}
+
+	out := cmd.OutOrStdout()
+	switch p.Source {
+	case "origin":
+		fmt.Fprintf(out, "Project: %s (from origin %s)\n", p.ID, p.Remote)
+	case "directory":
+		fmt.Fprintf(out, "Project: %s (directory name, no origin remote)\n", p.ID)
+	default:
+		fmt.Fprintf(out, "Project: %s (path from home, not a git repo)\n", p.ID)
+	}
+	if p.Branch != "" {
+		fmt.Fprintf(out, "Branch:  %s\n", p.Branch)
+	}
+	fmt.Fprintf(out, "Storage: %s\n", storage)
+	return nil
+}
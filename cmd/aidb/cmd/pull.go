@@ -11,10 +11,11 @@ import (
 )
 
 var pullCmd = &cobra.Command{
-	Use:   "pull",
-	Short: "Pull changes from remote",
-	Long:  `Pull changes from the remote repository.`,
-	RunE:  runPull,
+	Use:         "pull",
+	Short:       "Pull changes from remote",
+	Long:        `Pull changes from the remote repository.`,
+	RunE:        runPull,
+	Annotations: writesDB,
 }
 
 func init() {
@@ -37,6 +38,9 @@ func runPull(cmd *cobra.Command, args []string) error {
 
 	// Ensure pull.rebase is set so even raw `git pull` from ~/.aidb works
 	ensureRebaseConfig(cfg.DBDir)
+	if err := ensureMergeDriver(cfg.DBDir); err != nil {
+		printWarning(fmt.Sprintf("Metadata merge driver not registered: %v", err))
+	}
 
 	gitArgs := []string{"-C", cfg.DBDir}
 
@@ -54,11 +58,20 @@ func runPull(cmd *cobra.Command, args []string) error {
 			abort.Stdout = os.Stdout
 			abort.Stderr = os.Stderr
 			_ = abort.Run()
-			return fmt.Errorf("pull failed: rebase conflict. Resolve manually or force with: cd ~/.aidb && git pull --rebase")
+			return fmt.Errorf("pull failed: rebase conflict. Resolve manually or force with: cd %s && git pull --rebase", cfg.DBDir)
 		}
 		return fmt.Errorf("git pull failed: %w", pullErr)
 	}
 
+	if err := fetchSeenRefs(cfg.DBDir); err != nil {
+		printWarning(fmt.Sprintf("Seen versions not fetched, diff may miss them: %v", err))
+	}
+
+	// Reindex whatever the pull brought in
+	if _, _, err := syncIndex(cfg); err != nil {
+		printDebug(fmt.Sprintf("index: %v", err))
+	}
+
 	printSuccess("Pulled")
 	return nil
 }
@@ -10,10 +10,11 @@ import (
 )
 
 var pushCmd = &cobra.Command{
-	Use:   "push",
-	Short: "Push commits to remote",
-	Long:  `Push all local commits to the remote repository.`,
-	RunE:  runPush,
+	Use:         "push",
+	Short:       "Push commits to remote",
+	Long:        `Push all local commits to the remote repository.`,
+	RunE:        runPush,
+	Annotations: writesDB,
 }
 
 func init() {
@@ -50,6 +51,9 @@ func runPush(cmd *cobra.Command, args []string) error {
 	if err := gitCmd.Run(); err != nil {
 		return fmt.Errorf("git push failed: %w", err)
 	}
+	if err := pushSeenRefs(cfg.DBDir); err != nil {
+		printWarning(fmt.Sprintf("Seen versions not pushed, diff on other machines may miss them: %v", err))
+	}
 
 	printSuccess("Pushed")
 	return nil
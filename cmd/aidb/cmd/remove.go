@@ -2,36 +2,62 @@ package cmd
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/fsutil"
+	"github.com/KakkoiDev/aidb/internal/journal"
 	"github.com/KakkoiDev/aidb/internal/metadata"
 	"github.com/spf13/cobra"
 )
 
+var (
+	removeKeepInDB bool
+	removePurge    bool
+)
+
 var removeCmd = &cobra.Command{
-	Use:   "remove <file>",
+	Use:   "remove <file|dir|glob>...",
 	Short: "Untrack file, restore to original location",
-	Long: `Remove a file from aidb tracking and restore it to its original location.
+	Long: `Remove files from aidb tracking and restore them to their original location.
 
-The file is moved back from the database and the symlink is replaced with the actual file.
-The file remains in git history for recovery.
+Each file is moved back from the database and its symlink is replaced with
+the actual file. A directory restores every tracked file inside it. The file
+remains in git history for recovery.
+
+  --keep-in-db   only delete the symlink; the file stays tracked
+  --purge        delete the symlink and the database copy; nothing is restored
 
 Examples:
-  aidb remove TASK.md`,
-	Args: cobra.ExactArgs(1),
-	RunE: runRemove,
+  aidb remove TASK.md
+  aidb remove *.md
+  aidb remove docs/
+  aidb remove --purge notes/scratch.md`,
+	Args:        cobra.MinimumNArgs(1),
+	RunE:        runRemove,
+	Annotations: writesDB,
 }
 
 func init() {
+	removeCmd.Flags().BoolVar(&removeKeepInDB, "keep-in-db", false, "Only remove the symlink, keep the file in the database")
+	removeCmd.Flags().BoolVar(&removePurge, "purge", false, "Delete the file from the database instead of restoring it")
 	rootCmd.AddCommand(removeCmd)
 }
 
+// removeItem is a checkout symlink and the database file it points to
+type removeItem struct {
+	link   string
+	target string
+}
+
 func runRemove(cmd *cobra.Command, args []string) error {
-	filename := args[0]
+	if removeKeepInDB && removePurge {
+		return fmt.Errorf("--keep-in-db and --purge are mutually exclusive")
+	}
 
 	cfg, err := config.New()
 	if err != nil {
@@ -43,57 +69,227 @@ func runRemove(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	linkPath := filepath.Join(cwd, filename)
+	// Expand globs, then directories, into tracked symlinks
+	var items []removeItem
+	seen := map[string]bool{}
+	skipped := 0
+	for _, arg := range args {
+		paths, err := filepath.Glob(filepath.Join(cwd, arg))
+		if err != nil {
+			return fmt.Errorf("invalid glob pattern: %s", arg)
+		}
+		if len(paths) == 0 {
+			paths = []string{filepath.Join(cwd, arg)}
+		}
+		for _, path := range paths {
+			found, err := removeTargets(cfg, path)
+			if err != nil {
+				printError(fmt.Sprintf("%s: %v", displayPath(cwd, path), err))
+				skipped++
+				continue
+			}
+			for _, item := range found {
+				if !seen[item.link] {
+					seen[item.link] = true
+					items = append(items, item)
+				}
+			}
+		}
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("no tracked files to remove")
+	}
 
-	// Check if it's a symlink
-	info, err := os.Lstat(linkPath)
+	meta, err := metadata.New(cfg.DBDir)
 	if err != nil {
-		return fmt.Errorf("file not found: %s", filename)
+		return fmt.Errorf("failed to load metadata: %w", err)
 	}
-	if info.Mode()&os.ModeSymlink == 0 {
-		return fmt.Errorf("not a tracked file (not a symlink): %s", filename)
+
+	j, err := journal.Begin(cfg.DBDir, "remove", args)
+	if err != nil {
+		return fmt.Errorf("failed to start journal: %w", err)
+	}
+
+	// A failure part way rolls back every file of this remove
+	var relPaths []string
+	for _, item := range items {
+		name := displayPath(cwd, item.link)
+		relPath, _ := filepath.Rel(cfg.DBDir, item.target)
+		if err := removeItemFrom(cfg, meta, j, item, relPath); err != nil {
+			rollbackJournal(cfg, j)
+			return fmt.Errorf("%s: %w (all files of this remove were restored)", name, err)
+		}
+		relPaths = append(relPaths, relPath)
+
+		switch {
+		case removeKeepInDB:
+			printSuccess(fmt.Sprintf("Unlinked %s (kept in database)", name))
+		case removePurge:
+			printSuccess(fmt.Sprintf("Purged %s", name))
+		default:
+			printSuccess(fmt.Sprintf("Removed %s from tracking", name))
+		}
 	}
 
-	// Get symlink target
-	target, err := os.Readlink(linkPath)
+	if err := meta.Save(); err != nil {
+		printWarning(fmt.Sprintf("failed to save metadata: %v", err))
+	}
+	updateIndex(cfg, relPaths...)
+	if err := j.Commit(); err != nil {
+		printWarning(fmt.Sprintf("failed to finish journal: %v", err))
+	}
+
+	if len(items) > 1 || skipped > 0 {
+		summary := fmt.Sprintf("%d removed", len(items))
+		if skipped > 0 {
+			summary += fmt.Sprintf(", %d skipped", skipped)
+		}
+		printInfo(summary)
+	}
+	return nil
+}
+
+// removeTargets resolves path to the tracked symlinks it names: itself, or
+// every tracked symlink inside a directory
+func removeTargets(cfg *config.Config, path string) ([]removeItem, error) {
+	info, err := os.Lstat(path)
 	if err != nil {
-		return fmt.Errorf("failed to read symlink: %w", err)
+		return nil, fmt.Errorf("file not found")
+	}
+
+	if info.IsDir() {
+		var items []removeItem
+		filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() && (d.Name() == ".git" || d.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			if d.Type()&fs.ModeSymlink == 0 {
+				return nil
+			}
+			if target, err := os.Readlink(p); err == nil && inDB(cfg, target) {
+				items = append(items, removeItem{link: p, target: target})
+			}
+			return nil
+		})
+		if len(items) == 0 {
+			return nil, fmt.Errorf("no tracked files in directory")
+		}
+		return items, nil
 	}
 
-	// Verify target is in aidb
-	if !strings.HasPrefix(target, cfg.DBDir) {
-		return fmt.Errorf("file is not tracked by aidb: %s", filename)
+	if info.Mode()&os.ModeSymlink == 0 {
+		return nil, fmt.Errorf("not a tracked file (not a symlink)")
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read symlink: %w", err)
+	}
+	if !inDB(cfg, target) {
+		return nil, fmt.Errorf("file is not tracked by aidb")
 	}
+	return []removeItem{{link: path, target: target}}, nil
+}
 
-	// Check if source file exists
-	if _, err := os.Stat(target); err != nil {
-		return fmt.Errorf("database file missing: %s", target)
+// removeItemFrom untracks one file according to the mode flags, journaling
+// each step
+func removeItemFrom(cfg *config.Config, meta *metadata.Metadata, j *journal.Entry, item removeItem, relPath string) error {
+	if removeKeepInDB {
+		if err := j.Unlink(item.link, item.target); err != nil {
+			return fmt.Errorf("failed to journal: %w", err)
+		}
+		if err := os.Remove(item.link); err != nil {
+			return fmt.Errorf("failed to remove symlink: %w", err)
+		}
+		if err := j.Meta(relPath, meta.GetInfo(relPath)); err != nil {
+			return fmt.Errorf("failed to journal: %w", err)
+		}
+		meta.RemoveLink(relPath, hostID(), item.link)
+		return nil
 	}
 
-	// Remove symlink
-	if err := os.Remove(linkPath); err != nil {
-		return fmt.Errorf("failed to remove symlink: %w", err)
+	if _, err := os.Stat(item.target); err != nil && !removePurge {
+		return fmt.Errorf("database file missing: %s", item.target)
 	}
 
-	// Move file back
-	if err := os.Rename(target, linkPath); err != nil {
-		// Try to restore symlink on failure
-		os.Symlink(target, linkPath)
-		return fmt.Errorf("failed to restore file: %w", err)
+	if removePurge {
+		if err := j.Unlink(item.link, item.target); err != nil {
+			return fmt.Errorf("failed to journal: %w", err)
+		}
+		if err := os.Remove(item.link); err != nil {
+			return fmt.Errorf("failed to remove symlink: %w", err)
+		}
+		// Kept in the journal until pruned, so the purge can be undone
+		if _, err := os.Lstat(item.target); err == nil {
+			trash := j.Trash(item.target)
+			if err := os.MkdirAll(filepath.Dir(trash), 0755); err != nil {
+				return err
+			}
+			if err := j.Move(item.target, trash); err != nil {
+				return fmt.Errorf("failed to journal: %w", err)
+			}
+			if err := fsutil.Move(item.target, trash); err != nil {
+				return fmt.Errorf("failed to delete: %w", err)
+			}
+		}
+	} else if err := removeLink(j, item.link, item.target); err != nil {
+		return err
 	}
 
 	// Remove from git (--cached keeps history)
-	gitCmd := exec.Command("git", "-C", cfg.DBDir, "rm", "--cached", target)
+	gitCmd := exec.Command("git", "-C", cfg.DBDir, "rm", "-q", "--cached", item.target)
 	gitCmd.Run() // Ignore error, file might not be staged
 
 	// Clean up metadata
-	meta, err := metadata.New(cfg.DBDir)
-	if err == nil {
-		relPath, _ := filepath.Rel(cfg.DBDir, target)
-		meta.Remove(relPath)
-		meta.Save()
+	if err := j.Meta(relPath, meta.GetInfo(relPath)); err != nil {
+		return fmt.Errorf("failed to journal: %w", err)
+	}
+	meta.Remove(relPath)
+	pruneEmptyParents(filepath.Dir(item.target), cfg.DBDir)
+	return nil
+}
+
+// removeLink replaces the symlink at linkPath with the database file it
+// points to, journaling each step
+func removeLink(j *journal.Entry, linkPath, target string) error {
+	if err := j.Unlink(linkPath, target); err != nil {
+		return fmt.Errorf("failed to journal: %w", err)
+	}
+	if err := os.Remove(linkPath); err != nil {
+		return fmt.Errorf("failed to remove symlink: %w", err)
 	}
 
-	printSuccess(fmt.Sprintf("Removed %s from tracking", filename))
+	// Move file back (copies across filesystems)
+	if err := j.Move(target, linkPath); err != nil {
+		return fmt.Errorf("failed to journal: %w", err)
+	}
+	if err := fsutil.Move(target, linkPath); err != nil {
+		return fmt.Errorf("failed to restore file: %w", err)
+	}
 	return nil
 }
+
+// pruneEmptyParents removes dir and its parents below stop while empty
+func pruneEmptyParents(dir, stop string) {
+	for strings.HasPrefix(dir, stop+string(filepath.Separator)) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// inDB reports whether a symlink target lies inside the database
+func inDB(cfg *config.Config, target string) bool {
+	return strings.HasPrefix(target, cfg.DBDir+string(filepath.Separator))
+}
+
+// displayPath shows path relative to cwd when it is below it
+func displayPath(cwd, path string) string {
+	if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/KakkoiDev/aidb/internal/metadata"
 	"github.com/KakkoiDev/aidb/internal/testutil"
 )
 
@@ -83,3 +84,121 @@ func TestRemoveCommand_NotTracked(t *testing.T) {
 		t.Error("expected error for non-tracked file")
 	}
 }
+
+func TestRemoveCommand_DirectoryAndGlob(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+
+	env.CreateFile(filepath.Join(repoDir, "docs", "a.md"), "a")
+	env.CreateFile(filepath.Join(repoDir, "docs", "sub", "b.md"), "b")
+	env.CreateFile(filepath.Join(repoDir, "x.md"), "x")
+	env.CreateFile(filepath.Join(repoDir, "y.md"), "y")
+
+	rootCmd.SetArgs([]string{"add", "docs", "x.md", "y.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+
+	rootCmd.SetArgs([]string{"remove", "docs/", "*.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+
+	for _, name := range []string{"docs/a.md", "docs/sub/b.md", "x.md", "y.md"} {
+		path := filepath.Join(repoDir, name)
+		if env.IsSymlink(path) {
+			t.Errorf("%s should be a regular file after remove", name)
+		}
+		if !env.FileExists(path) {
+			t.Errorf("%s should be restored", name)
+		}
+	}
+	if env.FileExists(filepath.Join(env.DBDir, "myproject", "feature-x", "docs")) {
+		t.Error("emptied database directories should be removed")
+	}
+}
+
+func TestRemoveCommand_KeepInDB(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { removeKeepInDB = false }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	env.CreateFile(filepath.Join(repoDir, "TASK.md"), "task")
+
+	rootCmd.SetArgs([]string{"add", "TASK.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+
+	rootCmd.SetArgs([]string{"remove", "--keep-in-db", "TASK.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(repoDir, "TASK.md")); !os.IsNotExist(err) {
+		t.Error("symlink should be deleted")
+	}
+	dbFile := filepath.Join(env.DBDir, "myproject", "feature-x", "TASK.md")
+	if got := env.ReadFile(dbFile); got != "task" {
+		t.Errorf("database copy = %q, want it kept", got)
+	}
+	meta, _ := metadata.New(env.DBDir)
+	if links := meta.Links("myproject/feature-x/TASK.md"); len(links) != 0 {
+		t.Errorf("recorded links = %v, want none", links)
+	}
+}
+
+func TestRemoveCommand_PurgeAndUndo(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { removePurge = false }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	env.CreateFile(filepath.Join(repoDir, "TASK.md"), "task")
+
+	rootCmd.SetArgs([]string{"add", "TASK.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+
+	rootCmd.SetArgs([]string{"remove", "--purge", "TASK.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	removePurge = false
+
+	linkPath := filepath.Join(repoDir, "TASK.md")
+	dbFile := filepath.Join(env.DBDir, "myproject", "feature-x", "TASK.md")
+	if _, err := os.Lstat(linkPath); !os.IsNotExist(err) {
+		t.Error("symlink should be deleted")
+	}
+	if env.FileExists(dbFile) {
+		t.Error("database copy should be deleted")
+	}
+
+	rootCmd.SetArgs([]string{"undo"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if got := env.ReadFile(linkPath); got != "task" {
+		t.Errorf("TASK.md = %q after undo, want %q", got, "task")
+	}
+	if !env.IsSymlink(linkPath) {
+		t.Error("TASK.md should be linked again after undo")
+	}
+}
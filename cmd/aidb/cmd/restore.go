@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/fsutil"
+	"github.com/KakkoiDev/aidb/internal/journal"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var restoreAt string
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <file> --at <rev|date>",
+	Short: "Bring back an older version of a tracked file",
+	Long: `Replace a tracked file with its content at a commit or date, following
+renames. Files that were deleted can be restored too. The result is staged
+and marked unseen for every agent; aidb undo puts the replaced content back.
+
+--at takes any git revision (a1b2c3d, HEAD~2) or a date git understands
+(2026-01-31, "2 weeks ago"); a date picks the last version committed by then.
+
+Examples:
+  aidb restore TASK.md --at HEAD~1
+  aidb restore TASK.md --at 2026-01-31
+  aidb restore TASK.md --at "3 days ago"`,
+	Args:        cobra.ExactArgs(1),
+	RunE:        runRestore,
+	Annotations: writesDB,
+}
+
+func init() {
+	restoreCmd.Flags().StringVar(&restoreAt, "at", "", "Revision or date to restore")
+	restoreCmd.MarkFlagRequired("at")
+	rootCmd.AddCommand(restoreCmd)
+}
+
+// RestoreResult is the version a file was restored to
+type RestoreResult struct {
+	Path   string `json:"path"`
+	Commit string `json:"commit"`
+	Date   string `json:"date"`
+	From   string `json:"from"` // path in that commit, if renamed since
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+
+	relPath, err := resolveHistoryPath(cfg, args[0])
+	if err != nil {
+		return err
+	}
+	version, err := versionAt(cfg.DBDir, relPath, restoreAt)
+	if err != nil {
+		return err
+	}
+	from := version.Paths[0]
+	data, err := exec.Command("git", "-C", cfg.DBDir, "cat-file", "blob", version.Commit+":"+from).Output()
+	if err != nil {
+		return fmt.Errorf("%s does not exist at %s", from, version.Commit[:7])
+	}
+
+	result := RestoreResult{Path: filepath.ToSlash(relPath), Commit: version.Commit, Date: version.Date, From: from}
+	target := filepath.Join(cfg.DBDir, relPath)
+	if current, err := os.ReadFile(target); err == nil && bytes.Equal(current, data) {
+		if flagJSON {
+			return writeJSON(cmd, result)
+		}
+		printInfo(fmt.Sprintf("%s is already at %s", result.Path, version.Commit[:7]))
+		return nil
+	}
+
+	if err := restoreContent(cfg, relPath, data, args); err != nil {
+		return err
+	}
+
+	if flagJSON {
+		return writeJSON(cmd, result)
+	}
+	printSuccess(fmt.Sprintf("Restored %s from %s (%s)", result.Path, version.Commit[:7], version.Date[:10]))
+	return nil
+}
+
+// versionAt finds the commit holding relPath's content at a revision or date
+func versionAt(dbDir, relPath, at string) (LogEntry, error) {
+	history, err := fileHistory(dbDir, relPath, "", 0)
+	if err != nil {
+		return LogEntry{}, err
+	}
+	if len(history) == 0 {
+		return LogEntry{}, fmt.Errorf("%s has no committed history", relPath)
+	}
+
+	out, err := exec.Command("git", "-C", dbDir, "rev-parse", "--verify", "-q", at+"^{commit}").Output()
+	if err == nil {
+		rev := strings.TrimSpace(string(out))
+		// The newest change to the file that rev contains
+		for _, e := range history {
+			if exec.Command("git", "-C", dbDir, "merge-base", "--is-ancestor", e.Commit, rev).Run() == nil {
+				return e, nil
+			}
+		}
+		return LogEntry{}, fmt.Errorf("%s did not exist at %s", relPath, at)
+	}
+
+	before, err := fileHistory(dbDir, relPath, at, 1)
+	if err != nil {
+		return LogEntry{}, err
+	}
+	if len(before) == 0 {
+		return LogEntry{}, fmt.Errorf("no version of %s at %s (not a revision, or before its first commit)", relPath, at)
+	}
+	return before[0], nil
+}
+
+// restoreContent replaces relPath with data, journaled so it can be undone,
+// stages it and marks it unseen for every agent
+func restoreContent(cfg *config.Config, relPath string, data []byte, args []string) error {
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata: %w", err)
+	}
+	j, err := journal.Begin(cfg.DBDir, "restore", args)
+	if err != nil {
+		return fmt.Errorf("failed to start journal: %w", err)
+	}
+
+	target := filepath.Join(cfg.DBDir, relPath)
+	err = func() error {
+		if err := j.Meta(relPath, meta.GetInfo(relPath)); err != nil {
+			return err
+		}
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(target); err == nil {
+			mode = info.Mode().Perm()
+			// The replaced content stays in the journal until pruned
+			trash := j.Trash(target)
+			if err := os.MkdirAll(filepath.Dir(trash), 0755); err != nil {
+				return err
+			}
+			if err := j.Move(target, trash); err != nil {
+				return err
+			}
+			if err := fsutil.Move(target, trash); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := j.Create(target, metadata.HashBytes(data)); err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, mode)
+	}()
+	if err != nil {
+		rollbackJournal(cfg, j)
+		return fmt.Errorf("failed to restore %s: %w", relPath, err)
+	}
+
+	if err := exec.Command("git", "-C", cfg.DBDir, "add", "--", target).Run(); err != nil {
+		printWarning(fmt.Sprintf("%s: git add failed", relPath))
+	}
+	if info := meta.GetInfo(relPath); info != nil {
+		for agent := range info.Consumers {
+			meta.MarkUnseen(relPath, agent)
+		}
+	}
+	if err := meta.Save(); err != nil {
+		printWarning(fmt.Sprintf("failed to save metadata: %v", err))
+	}
+	updateIndex(cfg, relPath)
+	if err := j.Commit(); err != nil {
+		printWarning(fmt.Sprintf("failed to finish journal: %v", err))
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+// setupHistory tracks TASK.md and commits two versions of it
+func setupHistory(t *testing.T, env *testutil.TestEnv) string {
+	t.Helper()
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	link := filepath.Join(repoDir, "TASK.md")
+	env.CreateFile(link, "v1")
+
+	for _, args := range [][]string{
+		{"add", "TASK.md"},
+		{"commit", "first"},
+	} {
+		rootCmd.SetArgs(args)
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("%v failed: %v", args, err)
+		}
+	}
+	if err := os.WriteFile(link, []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := exec.Command("git", "-C", env.DBDir, "add", "-A").Run(); err != nil {
+		t.Fatal(err)
+	}
+	rootCmd.SetArgs([]string{"commit", "second"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	return link
+}
+
+func TestLogCommand(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { flagJSON = false; rootCmd.SetOut(nil) }()
+	setupHistory(t, env)
+
+	// Renames are followed
+	rootCmd.SetArgs([]string{"mv", "TASK.md", "PLAN.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("mv failed: %v", err)
+	}
+	rootCmd.SetArgs([]string{"commit", "rename"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"log", "PLAN.md", "--json"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("log failed: %v", err)
+	}
+	var entries []LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatalf("failed to parse JSON: %v", err)
+	}
+
+	var subjects []string
+	for _, e := range entries {
+		subjects = append(subjects, e.Subject)
+	}
+	if len(entries) != 3 || subjects[0] != "rename" || subjects[2] != "first" {
+		t.Fatalf("subjects = %v, want [rename second first]", subjects)
+	}
+	if p := entries[2].Paths; len(p) != 1 || p[0] != "myproject/feature-x/TASK.md" {
+		t.Errorf("first commit paths = %v, want the old name", p)
+	}
+}
+
+func TestRestoreCommand(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { restoreAt = "" }()
+	link := setupHistory(t, env)
+
+	rootCmd.SetArgs([]string{"seen", "myproject/feature-x/TASK.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("seen failed: %v", err)
+	}
+
+	rootCmd.SetArgs([]string{"restore", "TASK.md", "--at", "HEAD~1"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if got := env.ReadFile(link); got != "v1" {
+		t.Errorf("TASK.md = %q, want %q", got, "v1")
+	}
+	if !env.IsSymlink(link) {
+		t.Error("the checkout symlink should be untouched")
+	}
+	meta, _ := metadata.New(env.DBDir)
+	if meta.Consumer("myproject/feature-x/TASK.md", metadata.DefaultAgent) != nil {
+		t.Error("restored file should be unseen")
+	}
+
+	rootCmd.SetArgs([]string{"undo"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if got := env.ReadFile(link); got != "v2" {
+		t.Errorf("TASK.md after undo = %q, want %q", got, "v2")
+	}
+	meta, _ = metadata.New(env.DBDir)
+	if meta.Consumer("myproject/feature-x/TASK.md", metadata.DefaultAgent) == nil {
+		t.Error("undo should restore the seen state")
+	}
+}
+
+func TestRestoreCommand_Deleted(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { restoreAt = "" }()
+	setupHistory(t, env)
+
+	dbFile := filepath.Join(env.DBDir, "myproject", "feature-x", "TASK.md")
+	rootCmd.SetArgs([]string{"remove", "TASK.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	rootCmd.SetArgs([]string{"commit", "drop"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+
+	rootCmd.SetArgs([]string{"restore", "/myproject/feature-x/TASK.md", "--at", "HEAD~1"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if got := env.ReadFile(dbFile); got != "v2" {
+		t.Errorf("restored = %q, want %q", got, "v2")
+	}
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/journal"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reviewExtend string
+	reviewUntil  string
+)
+
+var reviewCmd = &cobra.Command{
+	Use:   "review <file|glob>...",
+	Short: "Mark files reviewed and set their next review date",
+	Long: `Record that files were reviewed, postponing their next review. This clears
+their stale flag: agents that had seen the current content see it as seen
+again. The date is kept in .metadata.json, so the content is not changed.
+
+Files resolve like aidb where. Periods are days (90d), weeks (2w), months
+(6m) or years (1y), counted from today. Without --extend or --until, only
+files that already have a due date (expires or review-after in their front
+matter, or an earlier review) are postponed; the others are left alone.
+
+Examples:
+  aidb review TASK.md                  # Next review in 90 days
+  aidb review TASK.md --extend 30d
+  aidb review "_aidb/*.md" --until 2027-01-31`,
+	Args:        cobra.MinimumNArgs(1),
+	RunE:        runReview,
+	Annotations: writesDB,
+}
+
+func init() {
+	reviewCmd.Flags().StringVar(&reviewExtend, "extend", "90d", "Next review after this period")
+	reviewCmd.Flags().StringVar(&reviewUntil, "until", "", "Next review on this date (YYYY-MM-DD)")
+	rootCmd.AddCommand(reviewCmd)
+}
+
+func runReview(cmd *cobra.Command, args []string) error {
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+
+	var next time.Time
+	if reviewUntil != "" {
+		if next, err = time.ParseInLocation(metadata.DateFormat, reviewUntil, time.Local); err != nil {
+			return fmt.Errorf("invalid date: %s (use YYYY-MM-DD)", reviewUntil)
+		}
+	} else if next, err = addPeriod(time.Now(), reviewExtend); err != nil {
+		return err
+	}
+
+	explicit := cmd.Flags().Changed("extend") || cmd.Flags().Changed("until")
+
+	var relPaths []string
+	for _, arg := range args {
+		if relPath, ok := linkedDBPath(cfg, arg); ok {
+			relPaths = append(relPaths, relPath)
+			continue
+		}
+		matches, err := resolveShowPattern(cfg, arg)
+		if err != nil {
+			return err
+		}
+		relPaths = append(relPaths, matches...)
+	}
+
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata: %w", err)
+	}
+	var due, undated []string
+	for _, relPath := range relPaths {
+		if explicit || hasDueDate(cfg, meta, relPath) {
+			due = append(due, relPath)
+		} else {
+			undated = append(undated, relPath)
+		}
+	}
+
+	j, err := journal.Begin(cfg.DBDir, "review", args)
+	if err != nil {
+		return fmt.Errorf("failed to start journal: %w", err)
+	}
+	for _, relPath := range due {
+		if err := j.Meta(relPath, meta.GetInfo(relPath)); err != nil {
+			rollbackJournal(cfg, j)
+			return fmt.Errorf("failed to journal: %w", err)
+		}
+		meta.SetReviewAfter(relPath, next)
+	}
+	if err := meta.Save(); err != nil {
+		rollbackJournal(cfg, j)
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+	if err := j.Commit(); err != nil {
+		printWarning(fmt.Sprintf("failed to finish journal: %v", err))
+	}
+
+	reviewed := []StaleFile{}
+	for _, relPath := range due {
+		reviewed = append(reviewed, StaleFile{Path: filepath.ToSlash(relPath), Due: next.Format(metadata.DateFormat)})
+	}
+	if flagJSON {
+		return writeJSON(cmd, reviewed)
+	}
+	for _, relPath := range undated {
+		printInfo(fmt.Sprintf("Skipped %s: no due date (use --extend or --until to set one)", filepath.ToSlash(relPath)))
+	}
+	for _, f := range reviewed {
+		printSuccess(fmt.Sprintf("Reviewed %s, next review %s", f.Path, f.Due))
+	}
+	return nil
+}
+
+// hasDueDate reports whether a file declares a due date or was reviewed before
+func hasDueDate(cfg *config.Config, meta *metadata.Metadata, relPath string) bool {
+	data, _ := os.ReadFile(filepath.Join(cfg.DBDir, relPath))
+	return !meta.Due(relPath, contentDue(relPath, data)).IsZero()
+}
+
+// addPeriod adds a period like 90d, 2w, 6m or 1y to t
+func addPeriod(t time.Time, period string) (time.Time, error) {
+	period = strings.TrimSpace(period)
+	if len(period) < 2 {
+		return t, fmt.Errorf("invalid period: %q (e.g. 90d, 2w, 6m, 1y)", period)
+	}
+	n, err := strconv.Atoi(period[:len(period)-1])
+	if err != nil || n < 0 {
+		return t, fmt.Errorf("invalid period: %q (e.g. 90d, 2w, 6m, 1y)", period)
+	}
+	switch period[len(period)-1] {
+	case 'd':
+		return t.AddDate(0, 0, n), nil
+	case 'w':
+		return t.AddDate(0, 0, 7*n), nil
+	case 'm':
+		return t.AddDate(0, n, 0), nil
+	case 'y':
+		return t.AddDate(n, 0, 0), nil
+	}
+	return t, fmt.Errorf("invalid period: %q (e.g. 90d, 2w, 6m, 1y)", period)
+}
@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"runtime/debug"
+	"strings"
 
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/metadata"
 	"github.com/spf13/cobra"
 )
 
@@ -15,6 +19,8 @@ var (
 	flagQuiet   bool
 	flagNoColor bool
 	flagDebug   bool
+	flagDBPath  string
+	flagAgent   string
 )
 
 var rootCmd = &cobra.Command{
@@ -25,15 +31,57 @@ var rootCmd = &cobra.Command{
 Commands:
   aidb init [--remote <url>]   Initialize database
   aidb add <file>              Track file
-  aidb remove <file>           Untrack file
+  aidb remove <file|dir>       Untrack files
+  aidb mv <old> <new>          Rename tracked file
   aidb list [--unseen]         List tracked files
+  aidb tag <file> [tags...]    Edit tags
+  aidb search <query>          Search tracked files
+  aidb show <file>             Print tracked files
+  aidb context [--budget N]    Context pack for agents
   aidb seen/unseen <file>      Mark file status
+  aidb diff [--unseen]         Changes since seen
+  aidb log [file]              File history
+  aidb restore <f> --at <rev>  Restore older version
+  aidb stale [--within 14d]    Files due for review
+  aidb review <f> [--extend]   Postpone next review
   aidb status                  Show changes
+  aidb project                 Show project identity
+  aidb branch [promote <b>]    Per-branch knowledge
+  aidb where <file>            Show recorded links
+  aidb link [--all]            Recreate symlinks
+  aidb doctor [--fix]          Check consistency
+  aidb undo [--list]           Revert last add/remove/seen
   aidb commit <msg>            Commit changes
-  aidb push/pull               Sync with remote`,
+  aidb push/pull               Sync with remote
+  aidb daemon [status|stop]    Watch and auto-commit
+  aidb mcp                     Serve over MCP (stdio)
+  aidb serve [--addr]          Serve a local HTTP API`,
 	Version: version,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// CLI flags are the highest precedence configuration layer
+		config.SetOverrides(map[string]string{
+			"db.path": flagDBPath,
+		})
+
+		// Roll back anything a crashed run left half done before changing the
+		// database again; servers and read-only commands leave it alone
+		if cmd.Annotations[writesDBKey] == "" {
+			return
+		}
+		if cfg, err := config.New(); err == nil {
+			if _, err := os.Stat(cfg.DBDir); err == nil {
+				recoverJournal(cfg)
+			}
+		}
+	},
 }
 
+// writesDBKey annotates commands that change the database
+const writesDBKey = "aidb.writes"
+
+// writesDB is the annotation of commands that change the database
+var writesDB = map[string]string{writesDBKey: "true"}
+
 func Execute() error {
 	return rootCmd.Execute()
 }
@@ -52,6 +100,35 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&flagQuiet, "quiet", "q", false, "Suppress non-essential output")
 	rootCmd.PersistentFlags().BoolVar(&flagNoColor, "no-color", false, "Disable colored output")
 	rootCmd.PersistentFlags().BoolVarP(&flagDebug, "debug", "d", false, "Show debug output")
+	rootCmd.PersistentFlags().StringVar(&flagDBPath, "db-path", "", "Database directory (overrides db.path)")
+	rootCmd.PersistentFlags().StringVar(&flagAgent, "agent", "", "Agent whose seen state to use (default $AIDB_AGENT)")
+}
+
+// currentAgent returns the consumer identity for seen state:
+// --agent, then AIDB_AGENT, then the default agent
+func currentAgent() string {
+	if flagAgent != "" {
+		return flagAgent
+	}
+	if agent := strings.TrimSpace(os.Getenv("AIDB_AGENT")); agent != "" {
+		return agent
+	}
+	return metadata.DefaultAgent
+}
+
+// agentOr returns agent, or the current agent if it is empty
+func agentOr(agent string) string {
+	if agent = strings.TrimSpace(agent); agent != "" {
+		return agent
+	}
+	return currentAgent()
+}
+
+// writeJSON encodes v as indented JSON to the command's output
+func writeJSON(cmd *cobra.Command, v interface{}) error {
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
 }
 
 // Helper functions for colored output
@@ -96,6 +173,19 @@ func printWarning(msg string) {
 	}
 }
 
+// printNotice is printWarning on stderr, for messages that must not mix
+// with a command's output
+func printNotice(msg string) {
+	if flagQuiet {
+		return
+	}
+	if flagNoColor {
+		fmt.Fprintf(os.Stderr, "! %s\n", msg)
+	} else {
+		fmt.Fprintf(os.Stderr, "\033[1;33m!\033[0m %s\n", msg)
+	}
+}
+
 func printDebug(msg string) {
 	if !flagDebug {
 		return
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/KakkoiDev/aidb/internal/search"
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchProject string
+	searchAidb    bool
+	searchUnseen  bool
+	searchLimit   int
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Full-text search tracked files",
+	Long: `Search every tracked file (including _aidb/ tiers) and rank matches with BM25.
+
+Examples:
+  aidb search "rate limit"              # Search everything
+  aidb search auth --project myproject  # Only one project
+  aidb search retry --aidb              # Only _aidb/ knowledge files
+  aidb search deploy --unseen --json    # Unseen hits as JSON`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runSearch,
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().StringVar(&searchProject, "project", "", "Only search files of this project")
+	searchCmd.Flags().BoolVar(&searchAidb, "aidb", false, "Only search _aidb/ knowledge files")
+	searchCmd.Flags().BoolVar(&searchUnseen, "unseen", false, "Only search unseen files")
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 20, "Maximum number of results")
+}
+
+// searchOptions filters and limits searchDB results
+type searchOptions struct {
+	Project string `json:"project"`
+	Aidb    bool   `json:"aidb"`
+	Unseen  bool   `json:"unseen"`
+	Limit   int    `json:"limit"`
+	Agent   string `json:"agent"` // whose seen state to use (default: current agent)
+}
+
+// SearchHit is one ranked search result
+type SearchHit struct {
+	Path    string  `json:"path"`
+	Score   float64 `json:"score"`
+	Seen    bool    `json:"seen"`
+	Line    int     `json:"line,omitempty"`
+	Snippet string  `json:"snippet,omitempty"`
+}
+
+func runSearch(cmd *cobra.Command, args []string) error {
+	query := search.Tokenize(strings.Join(args, " "))
+	if len(query) == 0 {
+		return fmt.Errorf("search query cannot be empty")
+	}
+
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+
+	hits := []SearchHit{}
+	if _, err := os.Stat(cfg.DBDir); err == nil {
+		hits, err = searchDB(cfg, query, searchOptions{
+			Project: searchProject,
+			Aidb:    searchAidb,
+			Unseen:  searchUnseen,
+			Limit:   searchLimit,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if flagJSON {
+		return writeJSON(cmd, hits)
+	}
+
+	if len(hits) == 0 {
+		printInfo("No matches")
+		return nil
+	}
+
+	for _, h := range hits {
+		status := colorGray("○")
+		if h.Seen {
+			status = colorGreen("●")
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "  %s %s %s\n", status, h.Path, colorGray(fmt.Sprintf("(%.2f)", h.Score)))
+		if h.Snippet != "" {
+			snippet := search.Highlight(h.Snippet, query, colorYellow)
+			fmt.Fprintf(cmd.OutOrStdout(), "      %s %s\n", colorGray(fmt.Sprintf("%d:", h.Line)), snippet)
+		}
+	}
+	return nil
+}
+
+// searchDB ranks tracked files from the index, rereading only changed files
+// and, for snippets, the files that made the result list
+func searchDB(cfg *config.Config, query []string, opts searchOptions) ([]SearchHit, error) {
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load metadata: %w", err)
+	}
+
+	idx, paths, err := syncIndex(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	agent := agentOr(opts.Agent)
+	var docs []search.Doc
+	seen := make(map[string]bool)
+	for _, relPath := range paths {
+		if !opts.matches(relPath) {
+			continue
+		}
+		e := idx.Get(relPath)
+		if e == nil {
+			continue
+		}
+		isSeen := meta.IsSeen(relPath, agent, e.Hash, meta.Due(relPath, e.Front.Due()))
+		if opts.Unseen && isSeen {
+			continue
+		}
+		docs = append(docs, idx.Doc(relPath, query))
+		seen[relPath] = isSeen
+	}
+
+	hits := []SearchHit{}
+	for _, r := range search.Rank(docs, query) {
+		if opts.Limit > 0 && len(hits) >= opts.Limit {
+			break
+		}
+		hit := SearchHit{Path: r.Path, Score: r.Score, Seen: seen[r.Path]}
+		if data, err := os.ReadFile(filepath.Join(cfg.DBDir, r.Path)); err == nil {
+			hit.Snippet, hit.Line = search.Snippet(string(data), query, 100)
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
+
+// matches applies the project and _aidb/ filters to a db-relative path
+func (o searchOptions) matches(relPath string) bool {
+	if o.Aidb && !isAidbPath(relPath) {
+		return false
+	}
+	if o.Project != "" && !strings.HasPrefix(relPath, o.Project+"/") {
+		return false
+	}
+	return true
+}
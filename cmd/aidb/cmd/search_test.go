@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+func TestSearchCommand_JSONWithFilters(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { flagJSON, searchAidb = false, false }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "main")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+
+	env.CreateFile(filepath.Join(env.DBDir, "myproject", "main", "TASK.md"), "Fix the login redirect loop")
+	env.CreateFile(filepath.Join(env.DBDir, "myproject", "main", "_aidb", "auth.md"), "Login uses OAuth; the redirect must be absolute")
+	env.CreateFile(filepath.Join(env.DBDir, "other", "main", "NOTES.md"), "Unrelated deployment notes")
+
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"search", "login", "redirect", "--json"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("search command failed: %v", err)
+	}
+
+	var hits []SearchHit
+	if err := json.Unmarshal(buf.Bytes(), &hits); err != nil {
+		t.Fatalf("failed to parse JSON: %v\n%s", err, buf.String())
+	}
+	if len(hits) != 2 {
+		t.Fatalf("got %d hits, want 2: %+v", len(hits), hits)
+	}
+	for _, h := range hits {
+		if h.Snippet == "" || h.Line == 0 {
+			t.Errorf("hit %s missing snippet", h.Path)
+		}
+	}
+
+	// --aidb restricts to knowledge tiers
+	buf.Reset()
+	rootCmd.SetArgs([]string{"search", "login", "--json", "--aidb"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("search command failed: %v", err)
+	}
+	hits = nil
+	if err := json.Unmarshal(buf.Bytes(), &hits); err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Path != "myproject/main/_aidb/auth.md" {
+		t.Errorf("--aidb hits = %+v", hits)
+	}
+}
@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/index"
+	"github.com/KakkoiDev/aidb/internal/journal"
 	"github.com/KakkoiDev/aidb/internal/metadata"
 	"github.com/spf13/cobra"
 )
@@ -15,12 +21,15 @@ var seenCmd = &cobra.Command{
 	Long: `Mark one or more files as seen/processed by AI agents.
 
 The current file hash is stored so changes can be detected later.
+Seen state is recorded for the agent named by --agent or AIDB_AGENT.
 
 Examples:
   aidb seen TASK.md
-  aidb seen "project/main/*.md"`,
-	Args: cobra.MinimumNArgs(1),
-	RunE: runSeen,
+  aidb seen "project/main/*.md"
+  aidb seen TASK.md --agent cursor`,
+	Args:        cobra.MinimumNArgs(1),
+	RunE:        runSeen,
+	Annotations: writesDB,
 }
 
 func init() {
@@ -38,32 +47,48 @@ func runSeen(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load metadata: %w", err)
 	}
 
+	idx, err := index.Open(cfg.DBDir)
+	if err != nil {
+		return fmt.Errorf("failed to load index: %w", err)
+	}
+
+	j, err := journal.Begin(cfg.DBDir, "seen", args)
+	if err != nil {
+		return fmt.Errorf("failed to start journal: %w", err)
+	}
+
+	agent := currentAgent()
 	count := 0
 	for _, pattern := range args {
-		matches, err := filepath.Glob(filepath.Join(cfg.DBDir, pattern))
+		matches, err := expandDBPattern(cfg.DBDir, pattern)
 		if err != nil {
-			printError(fmt.Sprintf("invalid pattern: %s", pattern))
+			printError(err.Error())
 			continue
 		}
 
-		if len(matches) == 0 {
-			// Try as literal path
-			matches = []string{filepath.Join(cfg.DBDir, pattern)}
-		}
-
 		for _, path := range matches {
 			relPath, err := filepath.Rel(cfg.DBDir, path)
 			if err != nil {
 				continue
 			}
 
-			hash, err := metadata.HashFile(path)
+			data, err := os.ReadFile(path)
+			if err == nil {
+				_, err = idx.Update(relPath)
+			}
 			if err != nil {
+				if os.IsNotExist(err) {
+					err = fmt.Errorf("file not found")
+				}
 				printError(fmt.Sprintf("%s: %v", relPath, err))
 				continue
 			}
 
-			meta.MarkSeen(relPath, hash)
+			if err := j.Meta(relPath, meta.GetInfo(relPath)); err != nil {
+				printError(fmt.Sprintf("%s: failed to journal: %v", relPath, err))
+				continue
+			}
+			markSeen(meta, cfg.DBDir, relPath, agent, data)
 			printSuccess(fmt.Sprintf("Marked seen: %s", relPath))
 			count++
 		}
@@ -71,9 +96,99 @@ func runSeen(cmd *cobra.Command, args []string) error {
 
 	if count > 0 {
 		if err := meta.Save(); err != nil {
+			j.Rollback()
 			return fmt.Errorf("failed to save metadata: %w", err)
 		}
 	}
+	saveIndex(cfg, idx)
+	if err := j.Commit(); err != nil {
+		printWarning(fmt.Sprintf("failed to finish journal: %v", err))
+	}
 
 	return nil
 }
+
+// markSeen records that agent has seen data as the content of relPath and
+// returns its hash. The content is stored as a git blob, along with HEAD,
+// so diff can later show what changed since.
+func markSeen(meta *metadata.Metadata, dbDir, relPath, agent string, data []byte) string {
+	hash := metadata.HashBytes(data)
+	c := meta.MarkSeen(relPath, agent, hash)
+	c.Blob = gitWriteBlob(dbDir, data)
+	c.Commit = gitHead(dbDir)
+	return hash
+}
+
+// seenRefs holds a ref per seen blob. An object only hash-object wrote is
+// unreachable: gc prunes it and push never sends it. The refs keep seen
+// content alive and travel with push and pull (seenRefspec).
+const seenRefs = "refs/aidb/seen/"
+
+// seenRefspec maps the seen refs onto the same names on the remote
+const seenRefspec = seenRefs + "*:" + seenRefs + "*"
+
+// gitWriteBlob stores data in the object database under a seen ref and
+// returns its id, or "" on failure
+func gitWriteBlob(dir string, data []byte) string {
+	gitCmd := exec.Command("git", "-C", dir, "hash-object", "-w", "--stdin")
+	gitCmd.Stdin = bytes.NewReader(data)
+	out, err := gitCmd.Output()
+	if err != nil {
+		return ""
+	}
+	id := strings.TrimSpace(string(out))
+	if err := exec.Command("git", "-C", dir, "update-ref", seenRefs+id, id).Run(); err != nil {
+		return ""
+	}
+	return id
+}
+
+// pushSeenRefs sends the seen refs to origin; blob refs never change, so
+// existing ones are skipped
+func pushSeenRefs(dir string) error {
+	if out, err := exec.Command("git", "-C", dir, "push", "-q", "origin", seenRefspec).CombinedOutput(); err != nil {
+		return fmt.Errorf("git push %s failed: %s", seenRefs, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// fetchSeenRefs brings in the seen refs other machines pushed
+func fetchSeenRefs(dir string) error {
+	if out, err := exec.Command("git", "-C", dir, "fetch", "-q", "origin", seenRefspec).CombinedOutput(); err != nil {
+		return fmt.Errorf("git fetch %s failed: %s", seenRefs, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// gitHead returns the current commit id, or "" before the first commit
+func gitHead(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--verify", "-q", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// expandDBPattern resolves a db-relative glob to absolute paths of tracked
+// files, falling back to the literal path when nothing matches. Paths that
+// leave the database or name its internal files are rejected.
+func expandDBPattern(dbDir, pattern string) ([]string, error) {
+	pattern = strings.TrimPrefix(pattern, "/")
+	path, err := dbFilePath(dbDir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	relPaths, err := globTracked(dbDir, path, pattern)
+	if err != nil {
+		if strings.ContainsAny(pattern, "*?[") {
+			return nil, err
+		}
+		// Try as literal path
+		return []string{path}, nil
+	}
+	matches := make([]string, len(relPaths))
+	for i, relPath := range relPaths {
+		matches[i] = filepath.Join(dbDir, relPath)
+	}
+	return matches, nil
+}
@@ -0,0 +1,437 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/search"
+	"github.com/KakkoiDev/aidb/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+// maxWriteSize caps request bodies for file writes
+const maxWriteSize = 16 << 20
+
+// sseKeepAlive is how often an idle event stream receives a comment line
+const sseKeepAlive = 30 * time.Second
+
+var serveAddr string
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the knowledge base over a local HTTP API",
+	Long: `Run an HTTP/JSON API for editor plugins and dashboards.
+
+Every request must carry the token from serve.token as
+"Authorization: Bearer <token>". GET /api/events also accepts ?token=<token>,
+since EventSource cannot set headers. A token is generated and saved on
+first run.
+
+Seen state is per agent: send "X-Aidb-Agent: <name>" or ?agent=<name>,
+otherwise the server's --agent / AIDB_AGENT is used.
+
+Endpoints:
+  GET  /api/files              List tracked files (?unseen=1, ?aidb=1)
+  GET  /api/files/{path}       Read a file
+  PUT  /api/files/{path}       Write and stage a file
+  POST /api/seen               Mark files seen   {"paths": [...]}
+  POST /api/unseen             Mark files unseen {"paths": [...]}
+  GET  /api/search?q=...       Ranked search (&project=, &aidb=1, &unseen=1, &limit=)
+  GET  /api/status             Uncommitted changes
+  POST /api/commit             Commit staged changes {"message": "..."}
+  GET  /api/events             Server-Sent Events stream of changed paths
+
+Examples:
+  aidb serve                         # Listen on serve.addr (127.0.0.1:7420)
+  aidb serve --addr 127.0.0.1:9000`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "", "Listen address (default serve.addr)")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+	if err := cfg.EnsureDBDir(); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	addr := serveAddr
+	if addr == "" {
+		addr = cfg.Settings.Serve.Addr
+	}
+
+	token := cfg.Settings.Serve.Token
+	if token == "" {
+		if token, err = generateToken(); err != nil {
+			return err
+		}
+		printSuccess(fmt.Sprintf("Generated API token (saved to %s as serve.token)", config.ConfigPath()))
+	}
+
+	api := newAPIServer(cfg, token)
+
+	w, err := watch.New(cfg.DBDir, config.IsInternalPath)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", cfg.DBDir, err)
+	}
+	defer w.Close()
+	go func() {
+		for path := range w.Events {
+			if rel, err := filepath.Rel(cfg.DBDir, path); err == nil {
+				api.events.publish(filepath.ToSlash(rel))
+			}
+		}
+	}()
+	go func() {
+		for err := range w.Errors {
+			printDebug(fmt.Sprintf("watch: %v", err))
+		}
+	}()
+
+	srv := &http.Server{Addr: addr, Handler: api.Handler()}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		shutdown, done := context.WithTimeout(context.Background(), 5*time.Second)
+		defer done()
+		srv.Shutdown(shutdown)
+	}()
+
+	printInfo(fmt.Sprintf("Serving %s on http://%s", cfg.DBDir, addr))
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// generateToken creates a random API token and stores it in the config file
+func generateToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	u, err := config.LoadFile()
+	if err != nil {
+		return "", err
+	}
+	u.Serve.Token = token
+	if err := config.SaveFile(u); err != nil {
+		return "", fmt.Errorf("failed to save token: %w", err)
+	}
+	return token, nil
+}
+
+// apiServer exposes the knowledge base over HTTP
+type apiServer struct {
+	cfg    *config.Config
+	token  string
+	events *eventHub
+
+	// mu serializes handlers that save metadata, the index or git state,
+	// since net/http runs them concurrently
+	mu sync.Mutex
+}
+
+func newAPIServer(cfg *config.Config, token string) *apiServer {
+	return &apiServer{cfg: cfg, token: token, events: newEventHub()}
+}
+
+// Handler returns the authenticated API routes
+func (s *apiServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/files", s.locked(s.handleList))
+	mux.HandleFunc("GET /api/files/{path...}", s.handleRead)
+	mux.HandleFunc("PUT /api/files/{path...}", s.locked(s.handleWrite))
+	mux.HandleFunc("POST /api/seen", s.locked(s.handleMark(true)))
+	mux.HandleFunc("POST /api/unseen", s.locked(s.handleMark(false)))
+	mux.HandleFunc("GET /api/search", s.locked(s.handleSearch))
+	mux.HandleFunc("GET /api/status", s.handleStatus)
+	mux.HandleFunc("POST /api/commit", s.locked(s.handleCommit))
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+	return s.authenticate(mux)
+}
+
+// locked runs h holding mu. List and search count too: they save the index.
+func (s *apiServer) locked(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		h(w, r)
+	}
+}
+
+func (s *apiServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		// EventSource cannot set headers; nothing else may put the token in
+		// a URL, where logs and history would keep it
+		if given == "" && r.Method == http.MethodGet && r.URL.Path == "/api/events" {
+			given = r.URL.Query().Get("token")
+		}
+		if s.token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) != 1 {
+			httpError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *apiServer) handleList(w http.ResponseWriter, r *http.Request) {
+	entries, err := listEntries(s.cfg, listOptions{
+		Unseen: queryBool(r, "unseen"),
+		Aidb:   queryBool(r, "aidb"),
+		Agent:  requestAgent(r),
+	})
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if entries == nil {
+		entries = []FileEntry{}
+	}
+	writeHTTPJSON(w, http.StatusOK, entries)
+}
+
+func (s *apiServer) handleRead(w http.ResponseWriter, r *http.Request) {
+	relPath := r.PathValue("path")
+	path, err := dbFilePath(s.cfg.DBDir, relPath)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			httpError(w, http.StatusNotFound, fmt.Errorf("file not found: %s", relPath))
+			return
+		}
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", mimeType(relPath)+"; charset=utf-8")
+	w.Write(data)
+}
+
+func (s *apiServer) handleWrite(w http.ResponseWriter, r *http.Request) {
+	relPath := r.PathValue("path")
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWriteSize))
+	if err != nil {
+		httpError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	if _, err := dbFilePath(s.cfg.DBDir, relPath); err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := writeDBFile(s.cfg, relPath, data); err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeHTTPJSON(w, http.StatusOK, map[string]string{"path": relPath})
+}
+
+func (s *apiServer) handleMark(seen bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Paths []string `json:"paths"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(body.Paths) == 0 {
+			httpError(w, http.StatusBadRequest, errors.New("paths is required"))
+			return
+		}
+		result, err := markPaths(s.cfg, body.Paths, requestAgent(r), seen)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeHTTPJSON(w, http.StatusOK, result)
+	}
+}
+
+func (s *apiServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := search.Tokenize(r.URL.Query().Get("q"))
+	if len(query) == 0 {
+		httpError(w, http.StatusBadRequest, errors.New("q is required"))
+		return
+	}
+	opts := searchOptions{
+		Project: r.URL.Query().Get("project"),
+		Aidb:    queryBool(r, "aidb"),
+		Unseen:  queryBool(r, "unseen"),
+		Limit:   20,
+		Agent:   requestAgent(r),
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid limit: %s", v))
+			return
+		}
+		opts.Limit = n
+	}
+	hits, err := searchDB(s.cfg, query, opts)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeHTTPJSON(w, http.StatusOK, hits)
+}
+
+func (s *apiServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	changes, err := gitStatus(s.cfg.DBDir)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeHTTPJSON(w, http.StatusOK, changes)
+}
+
+func (s *apiServer) handleCommit(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		httpError(w, http.StatusBadRequest, err)
+		return
+	}
+	if strings.TrimSpace(body.Message) == "" {
+		httpError(w, http.StatusBadRequest, errors.New("commit message cannot be empty"))
+		return
+	}
+	committed, err := commitStaged(s.cfg.DBDir, body.Message, nil, nil)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeHTTPJSON(w, http.StatusOK, map[string]bool{"committed": committed})
+}
+
+// handleEvents streams changed paths as Server-Sent Events until the client disconnects
+func (s *apiServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpError(w, http.StatusInternalServerError, errors.New("streaming not supported"))
+		return
+	}
+
+	ch := s.events.subscribe()
+	defer s.events.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case path := <-ch:
+			data, _ := json.Marshal(map[string]string{"path": path})
+			fmt.Fprintf(w, "event: change\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// requestAgent returns the agent named by the request, or the server's agent
+func requestAgent(r *http.Request) string {
+	if agent := r.Header.Get("X-Aidb-Agent"); agent != "" {
+		return agentOr(agent)
+	}
+	return agentOr(r.URL.Query().Get("agent"))
+}
+
+func queryBool(r *http.Request, name string) bool {
+	b, _ := strconv.ParseBool(r.URL.Query().Get(name))
+	return b
+}
+
+func writeHTTPJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func httpError(w http.ResponseWriter, status int, err error) {
+	writeHTTPJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// eventHub fans changed paths out to every connected event stream
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan string]bool
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: map[chan string]bool{}}
+}
+
+func (h *eventHub) subscribe() chan string {
+	ch := make(chan string, 64)
+	h.mu.Lock()
+	h.subs[ch] = true
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan string) {
+	h.mu.Lock()
+	delete(h.subs, ch)
+	h.mu.Unlock()
+}
+
+func (h *eventHub) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// publish never blocks; a subscriber that falls behind misses events
+func (h *eventHub) publish(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- path:
+		default:
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+func newTestAPI(t *testing.T) (*testutil.TestEnv, *apiServer) {
+	t.Helper()
+	env := testutil.New(t)
+	env.InitDBRepo()
+	env.CreateFile(filepath.Join(env.DBDir, "myproject", "main", "TASK.md"), "Fix the login redirect")
+
+	cfg, err := config.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env, newAPIServer(cfg, "secret")
+}
+
+// do sends an authenticated request through the handler without a network listener
+func do(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServe_RequiresToken(t *testing.T) {
+	env, api := newTestAPI(t)
+	defer env.Cleanup()
+	h := api.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/files", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status = %d", rec.Code)
+	}
+
+	// The query token is only for EventSource
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/files?token=secret", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("query token on /api/files: status = %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/api/commit?token=secret", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("query token on /api/commit: status = %d", rec.Code)
+	}
+}
+
+func TestServe_FilesSeenAndCommit(t *testing.T) {
+	env, api := newTestAPI(t)
+	defer env.Cleanup()
+	h := api.Handler()
+
+	rec := do(h, "GET", "/api/files?unseen=1", "")
+	var entries []FileEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("list: %v\n%s", err, rec.Body.String())
+	}
+	if len(entries) != 1 || entries[0].Path != "myproject/main/TASK.md" || entries[0].Seen {
+		t.Fatalf("list = %+v", entries)
+	}
+
+	rec = do(h, "GET", "/api/files/myproject/main/TASK.md", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "Fix the login redirect" {
+		t.Errorf("read = %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := do(h, "GET", "/api/files/.metadata.json", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("reading internal file: status = %d", rec.Code)
+	}
+
+	rec = do(h, "PUT", "/api/files/_aidb/patterns.md", "# Patterns")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("write = %d %s", rec.Code, rec.Body.String())
+	}
+	if got := env.ReadFile(filepath.Join(env.DBDir, "_aidb", "patterns.md")); got != "# Patterns" {
+		t.Errorf("written file = %q", got)
+	}
+
+	rec = do(h, "POST", "/api/seen", `{"paths":["myproject/main/TASK.md"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("seen = %d %s", rec.Code, rec.Body.String())
+	}
+	rec = do(h, "GET", "/api/files?unseen=1", "")
+	entries = nil
+	json.Unmarshal(rec.Body.Bytes(), &entries)
+	if len(entries) != 0 {
+		t.Errorf("unseen after seen = %+v", entries)
+	}
+
+	rec = do(h, "GET", "/api/status", "")
+	var changes []StatusEntry
+	json.Unmarshal(rec.Body.Bytes(), &changes)
+	found := false
+	for _, c := range changes {
+		if c.Path == "_aidb/patterns.md" && c.Status == "A " {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("status = %+v", changes)
+	}
+
+	rec = do(h, "POST", "/api/commit", `{"message":"Add patterns"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"committed":true`) {
+		t.Errorf("commit = %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServe_Search(t *testing.T) {
+	env, api := newTestAPI(t)
+	defer env.Cleanup()
+
+	rec := do(api.Handler(), "GET", "/api/search?q=login", "")
+	var hits []SearchHit
+	if err := json.Unmarshal(rec.Body.Bytes(), &hits); err != nil {
+		t.Fatalf("search: %v\n%s", err, rec.Body.String())
+	}
+	if len(hits) != 1 || hits[0].Path != "myproject/main/TASK.md" {
+		t.Errorf("hits = %+v", hits)
+	}
+
+	if rec := do(api.Handler(), "GET", "/api/search", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty query: status = %d", rec.Code)
+	}
+}
+
+func TestServe_ConcurrentSeen(t *testing.T) {
+	env, api := newTestAPI(t)
+	defer env.Cleanup()
+	h := api.Handler()
+
+	const n = 20
+	for i := 0; i < n; i++ {
+		env.CreateFile(filepath.Join(env.DBDir, "myproject", "main", fmt.Sprintf("note%d.md", i)), fmt.Sprintf("note %d", i))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"paths":["myproject/main/note%d.md"]}`, i)
+			if rec := do(h, "POST", "/api/seen", body); rec.Code != http.StatusOK {
+				t.Errorf("seen note%d: status = %d %s", i, rec.Code, rec.Body.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	meta, err := metadata.New(env.DBDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if meta.Consumer(fmt.Sprintf("myproject/main/note%d.md", i), metadata.DefaultAgent) == nil {
+			t.Errorf("note%d.md lost its seen update", i)
+		}
+	}
+
+	rec := do(h, "POST", "/api/seen", `{"paths":["../outside.txt"]}`)
+	var result markResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || len(result.Marked) != 0 {
+		t.Errorf("seen ../outside.txt = %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServe_Events(t *testing.T) {
+	env, api := newTestAPI(t)
+	defer env.Cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/api/events?token=secret", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		api.Handler().ServeHTTP(rec, req)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for api.events.count() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream never subscribed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	api.events.publish("myproject/main/TASK.md")
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	want := "event: change\ndata: {\"path\":\"myproject/main/TASK.md\"}\n\n"
+	if !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("stream = %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/journal"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var showMarkSeen bool
+
+var showCmd = &cobra.Command{
+	Use:     "show <path|glob>...",
+	Aliases: []string{"cat"},
+	Short:   "Print tracked files by logical name",
+	Long: `Print tracked files with a header per file.
+
+Paths are resolved against the current project/branch
+(~/.aidb/{project}/{branch}/...). A leading / makes a path relative to the
+database root instead; paths that match nothing in the current project are
+also tried from the root.
+
+--mark-seen records the hash of exactly the content that was printed, so a
+file modified between reading and marking stays unseen.
+
+Examples:
+  aidb show TASK.md                        # Current project's TASK.md
+  aidb show "_aidb/*.md"                   # Project knowledge files
+  aidb show /_aidb/patterns.md             # Global knowledge file
+  aidb cat TASK.md --mark-seen --agent ci  # Read and mark seen for ci`,
+	Args:        cobra.MinimumNArgs(1),
+	RunE:        runShow,
+	Annotations: writesDB,
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+	showCmd.Flags().BoolVar(&showMarkSeen, "mark-seen", false, "Mark the displayed content as seen")
+}
+
+// ShownFile is one file printed by show
+type ShownFile struct {
+	Path    string `json:"path"`
+	Hash    string `json:"hash"`
+	Seen    bool   `json:"seen"`
+	Content string `json:"content"`
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+
+	// Resolve everything first so nothing is printed or marked on a bad argument
+	var relPaths []string
+	resolved := map[string]bool{}
+	for _, pattern := range args {
+		matches, err := resolveShowPattern(cfg, pattern)
+		if err != nil {
+			return err
+		}
+		for _, relPath := range matches {
+			if !resolved[relPath] {
+				resolved[relPath] = true
+				relPaths = append(relPaths, relPath)
+			}
+		}
+	}
+
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata: %w", err)
+	}
+	agent := currentAgent()
+
+	var j *journal.Entry
+	if showMarkSeen {
+		if j, err = journal.Begin(cfg.DBDir, "seen", args); err != nil {
+			return fmt.Errorf("failed to start journal: %w", err)
+		}
+	}
+
+	files := []ShownFile{}
+	for _, relPath := range relPaths {
+		data, err := os.ReadFile(filepath.Join(cfg.DBDir, relPath))
+		if err != nil {
+			if j != nil {
+				j.Rollback()
+			}
+			return err
+		}
+		// The hash is taken from the bytes being shown, never re-read
+		hash := metadata.HashBytes(data)
+		if showMarkSeen {
+			if err := j.Meta(relPath, meta.GetInfo(relPath)); err != nil {
+				j.Rollback()
+				return fmt.Errorf("failed to journal: %w", err)
+			}
+			markSeen(meta, cfg.DBDir, relPath, agent, data)
+		}
+		files = append(files, ShownFile{
+			Path:    filepath.ToSlash(relPath),
+			Hash:    hash,
+			Seen:    meta.IsSeen(relPath, agent, hash, meta.Due(relPath, contentDue(relPath, data))),
+			Content: string(data),
+		})
+	}
+
+	if showMarkSeen {
+		if err := meta.Save(); err != nil {
+			j.Rollback()
+			return fmt.Errorf("failed to save metadata: %w", err)
+		}
+		updateIndex(cfg, relPaths...)
+		if err := j.Commit(); err != nil {
+			printWarning(fmt.Sprintf("failed to finish journal: %v", err))
+		}
+	}
+
+	if flagJSON {
+		return writeJSON(cmd, files)
+	}
+
+	out := cmd.OutOrStdout()
+	for i, f := range files {
+		if !flagQuiet {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprintf(out, "==> %s <==\n", f.Path)
+		}
+		fmt.Fprint(out, f.Content)
+		if f.Content != "" && !strings.HasSuffix(f.Content, "\n") {
+			fmt.Fprintln(out)
+		}
+	}
+	return nil
+}
+
+// resolveShowPattern returns the db-relative paths of tracked files matching
+// pattern, trying the current project/branch before the database root
+func resolveShowPattern(cfg *config.Config, pattern string) ([]string, error) {
+	if strings.HasPrefix(pattern, "/") {
+		return globTracked(cfg.DBDir, filepath.Join(cfg.DBDir, strings.TrimPrefix(pattern, "/")), pattern)
+	}
+
+	storagePath, err := cfg.GetStoragePath(pattern)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := globTracked(cfg.DBDir, storagePath, pattern)
+	if err == nil {
+		return matches, nil
+	}
+	return globTracked(cfg.DBDir, filepath.Join(cfg.DBDir, pattern), pattern)
+}
+
+// globTracked expands an absolute glob to sorted db-relative paths of
+// regular, non-internal files inside dbDir
+func globTracked(dbDir, glob, pattern string) ([]string, error) {
+	paths, err := filepath.Glob(glob)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %s", pattern)
+	}
+
+	var relPaths []string
+	for _, path := range paths {
+		relPath, err := filepath.Rel(dbDir, path)
+		if err != nil {
+			continue
+		}
+		if _, err := dbFilePath(dbDir, relPath); err != nil {
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		relPaths = append(relPaths, relPath)
+	}
+	if len(relPaths) == 0 {
+		return nil, fmt.Errorf("no tracked file matches: %s", pattern)
+	}
+	sort.Strings(relPaths)
+	return relPaths, nil
+}
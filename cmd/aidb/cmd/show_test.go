@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+func TestShowCommand_ResolvesProjectAndRoot(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	env.CreateFile(filepath.Join(env.DBDir, "myproject", "feature-x", "TASK.md"), "# Task\n")
+	env.CreateFile(filepath.Join(env.DBDir, "_aidb", "patterns.md"), "# Patterns")
+
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"show", "TASK.md", "/_aidb/patterns.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("show command failed: %v", err)
+	}
+
+	want := "==> myproject/feature-x/TASK.md <==\n# Task\n\n==> _aidb/patterns.md <==\n# Patterns\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+
+	rootCmd.SetArgs([]string{"cat", "MISSING.md"})
+	if err := rootCmd.Execute(); err == nil {
+		t.Error("show should fail for a path that matches nothing")
+	}
+}
+
+func TestShowCommand_MarkSeenRecordsShownHash(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { showMarkSeen, flagJSON, flagAgent = false, false, "" }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	taskPath := filepath.Join(env.DBDir, "myproject", "feature-x", "TASK.md")
+	env.CreateFile(taskPath, "version one")
+
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"show", "TASK.md", "--mark-seen", "--json", "--agent", "ci"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("show command failed: %v", err)
+	}
+
+	var files []ShownFile
+	if err := json.Unmarshal(buf.Bytes(), &files); err != nil {
+		t.Fatalf("failed to parse JSON: %v\n%s", err, buf.String())
+	}
+	if len(files) != 1 || files[0].Content != "version one" || !files[0].Seen {
+		t.Fatalf("files = %+v", files)
+	}
+
+	meta, _ := metadata.New(env.DBDir)
+	if !meta.IsSeen("myproject/feature-x/TASK.md", "ci", metadata.HashBytes([]byte("version one")), time.Time{}) {
+		t.Error("shown content should be marked seen for ci")
+	}
+	if meta.IsSeen("myproject/feature-x/TASK.md", metadata.DefaultAgent, files[0].Hash, time.Time{}) {
+		t.Error("other agents should not be marked")
+	}
+
+	// A later edit is not covered by the earlier read
+	env.CreateFile(taskPath, "version two")
+	if meta.IsSeen("myproject/feature-x/TASK.md", "ci", metadata.HashBytes([]byte("version two")), time.Time{}) {
+		t.Error("modified content should be unseen")
+	}
+	if !strings.HasPrefix(files[0].Hash, "sha256:") {
+		t.Errorf("hash = %q", files[0].Hash)
+	}
+}
+
+func TestShowCommand_MarkSeenCanBeUndone(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { showMarkSeen, flagQuiet = false, false }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	env.CreateFile(filepath.Join(env.DBDir, "myproject", "feature-x", "TASK.md"), "task")
+
+	rootCmd.SetArgs([]string{"show", "TASK.md", "--mark-seen", "-q"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("show failed: %v", err)
+	}
+	meta, _ := metadata.New(env.DBDir)
+	if meta.Consumer("myproject/feature-x/TASK.md", metadata.DefaultAgent) == nil {
+		t.Fatal("show --mark-seen should mark TASK.md seen")
+	}
+
+	rootCmd.SetArgs([]string{"undo"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	meta, _ = metadata.New(env.DBDir)
+	if meta.Consumer("myproject/feature-x/TASK.md", metadata.DefaultAgent) != nil {
+		t.Error("undo should revert show --mark-seen")
+	}
+}
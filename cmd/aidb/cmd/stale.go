@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/frontmatter"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var staleWithin string
+
+var staleCmd = &cobra.Command{
+	Use:   "stale",
+	Short: "List files whose expiry or review date has passed",
+	Long: `List tracked files, including _aidb/ knowledge, that are due for review.
+
+A file is due on the earlier of its frontmatter expires and review-after
+dates, unless aidb review recorded a later one. Once due, a file is unseen
+again for every agent that saw it before, so it shows up in list --unseen.
+
+Examples:
+  aidb stale
+  aidb stale --within 14d   # Also files due in the next two weeks
+  aidb stale --json`,
+	Args: cobra.NoArgs,
+	RunE: runStale,
+}
+
+func init() {
+	staleCmd.Flags().StringVar(&staleWithin, "within", "", "Include files due within this period (e.g. 14d, 2w, 1m)")
+	rootCmd.AddCommand(staleCmd)
+}
+
+// StaleFile is a file due for review
+type StaleFile struct {
+	Path    string `json:"path"`
+	Due     string `json:"due"`
+	Overdue bool   `json:"overdue"` // false for files only due within --within
+	Title   string `json:"title,omitempty"`
+}
+
+func runStale(cmd *cobra.Command, args []string) error {
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	horizon := now
+	if staleWithin != "" {
+		if horizon, err = addPeriod(now, staleWithin); err != nil {
+			return err
+		}
+	}
+
+	files := []StaleFile{}
+	if _, err := os.Stat(cfg.DBDir); err == nil {
+		if files, err = staleFiles(cfg, now, horizon); err != nil {
+			return err
+		}
+	}
+
+	if flagJSON {
+		return writeJSON(cmd, files)
+	}
+	if len(files) == 0 {
+		printInfo("Nothing is due for review")
+		return nil
+	}
+	out := cmd.OutOrStdout()
+	for _, f := range files {
+		due := colorYellow(f.Due)
+		if f.Overdue {
+			due = colorRed(f.Due)
+		}
+		line := fmt.Sprintf("  %s  %s", due, f.Path)
+		if f.Title != "" {
+			line += " " + colorGray("("+f.Title+")")
+		}
+		fmt.Fprintln(out, line)
+	}
+	return nil
+}
+
+// staleFiles returns the files due before horizon, oldest due date first
+func staleFiles(cfg *config.Config, now, horizon time.Time) ([]StaleFile, error) {
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load metadata: %w", err)
+	}
+	idx, paths, err := syncIndex(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []StaleFile{}
+	for _, relPath := range paths {
+		var front *frontmatter.Fields
+		if e := idx.Get(relPath); e != nil {
+			front = e.Front
+		}
+		due := meta.Due(relPath, front.Due())
+		if due.IsZero() || due.After(horizon) {
+			continue
+		}
+		f := StaleFile{Path: relPath, Due: due.Format(metadata.DateFormat), Overdue: !now.Before(due)}
+		if front != nil {
+			f.Title = front.Title
+		}
+		files = append(files, f)
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Due < files[j].Due })
+	return files, nil
+}
+
+// contentDue returns the due date a file's content declares, if any
+func contentDue(relPath string, data []byte) time.Time {
+	if !frontmatter.IsMarkdown(relPath) {
+		return time.Time{}
+	}
+	return frontmatter.Parse(data).Due()
+}
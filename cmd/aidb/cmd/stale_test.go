@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+func TestStaleAndReview(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	listAidb = false
+	defer func() {
+		listUnseen, listJSON, flagJSON, reviewExtend, staleWithin = false, false, false, "90d", ""
+		reviewCmd.Flags().Lookup("extend").Changed = false
+		rootCmd.SetOut(nil)
+	}()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	rel := filepath.Join("myproject", "feature-x", "DEPS.md")
+	content := "---\ntitle: Pinned versions\nexpires: 2020-01-31\n---\nlib v1.2\n"
+	env.CreateFile(filepath.Join(env.DBDir, rel), content)
+
+	// Seen before it expired
+	meta, err := metadata.New(env.DBDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.MarkSeen(rel, metadata.DefaultAgent, metadata.HashBytes([]byte(content))).SeenAt = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := meta.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) []byte {
+		t.Helper()
+		var buf bytes.Buffer
+		rootCmd.SetOut(&buf)
+		rootCmd.SetArgs(args)
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("%v failed: %v", args, err)
+		}
+		return buf.Bytes()
+	}
+
+	var entries []FileEntry
+	if err := json.Unmarshal(run("list", "--unseen", "--json"), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !entries[0].Stale || entries[0].Due != "2020-01-31" {
+		t.Fatalf("expired file should be unseen and stale, got %+v", entries)
+	}
+
+	var stale []StaleFile
+	if err := json.Unmarshal(run("stale", "--json"), &stale); err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 1 || stale[0].Path != filepath.ToSlash(rel) || !stale[0].Overdue || stale[0].Title != "Pinned versions" {
+		t.Fatalf("stale = %+v", stale)
+	}
+
+	run("review", "/"+filepath.ToSlash(rel), "--extend", "30d")
+
+	stale = nil
+	if err := json.Unmarshal(run("stale", "--json"), &stale); err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 0 {
+		t.Errorf("reviewed file still stale: %+v", stale)
+	}
+	if err := json.Unmarshal(run("stale", "--within", "31d", "--json"), &stale); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Now().AddDate(0, 0, 30).Format(metadata.DateFormat); len(stale) != 1 || stale[0].Due != want || stale[0].Overdue {
+		t.Errorf("stale --within = %+v, want due %s", stale, want)
+	}
+
+	entries = nil
+	if err := json.Unmarshal(run("list", "--unseen", "--json"), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("reviewed file should be seen again, got %+v", entries)
+	}
+}
+
+func TestReview_OnlyPostponesDueFiles(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() {
+		reviewExtend, reviewUntil = "90d", ""
+		reviewCmd.Flags().Lookup("extend").Changed = false
+		reviewCmd.Flags().Lookup("until").Changed = false
+		rootCmd.SetOut(nil)
+	}()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	dated := filepath.Join("myproject", "feature-x", "DEPS.md")
+	undated := filepath.Join("myproject", "feature-x", "NOTES.md")
+	env.CreateFile(filepath.Join(env.DBDir, dated), "---\nexpires: 2020-01-31\n---\nlib v1.2\n")
+	env.CreateFile(filepath.Join(env.DBDir, undated), "notes\n")
+
+	run := func(args ...string) {
+		t.Helper()
+		rootCmd.SetOut(&bytes.Buffer{})
+		rootCmd.SetArgs(args)
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("%v failed: %v", args, err)
+		}
+	}
+	reviewAfter := func(relPath string) string {
+		t.Helper()
+		meta, err := metadata.New(env.DBDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info := meta.GetInfo(relPath); info != nil {
+			return info.ReviewAfter
+		}
+		return ""
+	}
+
+	run("review", "/"+filepath.ToSlash(dated), "/"+filepath.ToSlash(undated))
+	if reviewAfter(dated) == "" {
+		t.Error("file with a due date should be postponed")
+	}
+	if got := reviewAfter(undated); got != "" {
+		t.Errorf("file without a due date got review date %s", got)
+	}
+
+	run("review", "/"+filepath.ToSlash(undated), "--until", "2030-01-31")
+	if got := reviewAfter(undated); got != "2030-01-31" {
+		t.Errorf("--until should set a review date, got %q", got)
+	}
+}
+
+func TestAddPeriod(t *testing.T) {
+	base := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	for period, want := range map[string]time.Time{
+		"90d": base.AddDate(0, 0, 90),
+		"2w":  base.AddDate(0, 0, 14),
+		"6m":  base.AddDate(0, 6, 0),
+		"1y":  base.AddDate(1, 0, 0),
+	} {
+		if got, err := addPeriod(base, period); err != nil || !got.Equal(want) {
+			t.Errorf("addPeriod(%q) = %v, %v; want %v", period, got, err, want)
+		}
+	}
+	for _, period := range []string{"", "d", "90", "-1d", "3h"} {
+		if _, err := addPeriod(base, period); err == nil {
+			t.Errorf("addPeriod(%q) should fail", period)
+		}
+	}
+}
@@ -33,15 +33,11 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	// Run git status
-	gitCmd := exec.Command("git", "-C", cfg.DBDir, "status", "--short")
-	out, err := gitCmd.Output()
+	changes, err := gitStatus(cfg.DBDir)
 	if err != nil {
-		return fmt.Errorf("git status failed: %w", err)
+		return err
 	}
-
-	output := strings.TrimSpace(string(out))
-	if output == "" {
+	if len(changes) == 0 {
 		printInfo("Nothing to commit, working tree clean")
 		return nil
 	}
@@ -49,13 +45,8 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	fmt.Println("Changes in aidb:")
 	fmt.Println()
 
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if len(line) < 3 {
-			continue
-		}
-		status := line[:2]
-		file := strings.TrimSpace(line[2:])
+	for _, c := range changes {
+		status, file := c.Status, c.Path
 
 		switch {
 		case status[0] == 'A':
@@ -75,6 +66,29 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// StatusEntry is one line of `git status --short`
+type StatusEntry struct {
+	Status string `json:"status"`
+	Path   string `json:"path"`
+}
+
+// gitStatus returns the uncommitted changes in the database
+func gitStatus(dir string) ([]StatusEntry, error) {
+	out, err := exec.Command("git", "-C", dir, "status", "--short").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git status failed: %w", err)
+	}
+
+	changes := []StatusEntry{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if len(line) < 3 {
+			continue
+		}
+		changes = append(changes, StatusEntry{Status: line[:2], Path: strings.TrimSpace(line[2:])})
+	}
+	return changes, nil
+}
+
 func colorGreen(s string) string {
 	return fmt.Sprintf("\033[0;32m%s\033[0m", s)
 }
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/frontmatter"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var tagRemove bool
+
+var tagCmd = &cobra.Command{
+	Use:   "tag <file|glob> [tags...]",
+	Short: "Show or edit the tags of tracked files",
+	Long: `Add tags to tracked files, or remove them with --remove. Without tags,
+print the current ones. Files resolve like aidb where.
+
+Markdown files keep their tags in YAML frontmatter, edited in place and
+staged; other files keep them in .metadata.json. Tags are lowercased.
+
+Examples:
+  aidb tag TASK.md auth db
+  aidb tag TASK.md db --remove
+  aidb tag "docs/*.md"
+  aidb list --tag auth`,
+	Args:        cobra.MinimumNArgs(1),
+	RunE:        runTag,
+	Annotations: writesDB,
+}
+
+func init() {
+	tagCmd.Flags().BoolVarP(&tagRemove, "remove", "r", false, "Remove the given tags instead of adding them")
+	rootCmd.AddCommand(tagCmd)
+}
+
+// FileTags is the tags of one file
+type FileTags struct {
+	Path string   `json:"path"`
+	Tags []string `json:"tags"`
+}
+
+func runTag(cmd *cobra.Command, args []string) error {
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata: %w", err)
+	}
+
+	var relPaths []string
+	if relPath, ok := linkedDBPath(cfg, args[0]); ok {
+		relPaths = []string{relPath}
+	} else if relPaths, err = resolveShowPattern(cfg, args[0]); err != nil {
+		return err
+	}
+	edit := frontmatter.Normalize(args[1:])
+	if tagRemove && len(edit) == 0 {
+		return fmt.Errorf("--remove needs tags")
+	}
+
+	results := []FileTags{}
+	metaChanged := false
+	for _, relPath := range relPaths {
+		current, err := fileTags(cfg, meta, relPath)
+		if err != nil {
+			return err
+		}
+		tags := current
+		if len(edit) > 0 {
+			tags = editTags(current, edit, tagRemove)
+		}
+
+		if !slices.Equal(tags, current) {
+			if frontmatter.IsMarkdown(relPath) {
+				if err := writeTags(cfg, relPath, tags); err != nil {
+					return err
+				}
+			} else {
+				meta.SetTags(relPath, tags)
+				metaChanged = true
+			}
+		}
+		if tags == nil {
+			tags = []string{}
+		}
+		results = append(results, FileTags{Path: filepath.ToSlash(relPath), Tags: tags})
+	}
+
+	if metaChanged {
+		if err := meta.Save(); err != nil {
+			return fmt.Errorf("failed to save metadata: %w", err)
+		}
+	}
+
+	if flagJSON {
+		return writeJSON(cmd, results)
+	}
+	for _, r := range results {
+		tags := colorGray("no tags")
+		if len(r.Tags) > 0 {
+			tags = strings.Join(r.Tags, ", ")
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", r.Path, tags)
+	}
+	return nil
+}
+
+// fileTags returns a file's tags from its frontmatter or metadata
+func fileTags(cfg *config.Config, meta *metadata.Metadata, relPath string) ([]string, error) {
+	if !frontmatter.IsMarkdown(relPath) {
+		return meta.Tags(relPath), nil
+	}
+	data, err := os.ReadFile(filepath.Join(cfg.DBDir, relPath))
+	if err != nil {
+		return nil, err
+	}
+	if f := frontmatter.Parse(data); f != nil {
+		return f.Tags, nil
+	}
+	return nil, nil
+}
+
+// editTags adds or removes tags, keeping the result normalized
+func editTags(current, edit []string, remove bool) []string {
+	if !remove {
+		return frontmatter.Normalize(append(slices.Clone(current), edit...))
+	}
+	var kept []string
+	for _, tag := range current {
+		if !slices.Contains(edit, tag) {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
+
+// writeTags rewrites the tags key of a Markdown file's frontmatter and stages it
+func writeTags(cfg *config.Config, relPath string, tags []string) error {
+	path := filepath.Join(cfg.DBDir, relPath)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var value interface{}
+	if len(tags) > 0 {
+		value = tags
+	}
+	updated, err := frontmatter.Set(data, "tags", value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", relPath, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, updated, info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := exec.Command("git", "-C", cfg.DBDir, "add", "--", path).Run(); err != nil {
+		printWarning(fmt.Sprintf("%s: git add failed", relPath))
+	}
+	updateIndex(cfg, relPath)
+	return nil
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+func TestTagCommand_Markdown(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { tagRemove = false }()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	link := filepath.Join(repoDir, "TASK.md")
+	env.CreateFile(link, "# Task\n")
+
+	rootCmd.SetArgs([]string{"add", "TASK.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+
+	// Through the checkout symlink
+	rootCmd.SetArgs([]string{"tag", "TASK.md", "Auth", "db"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("tag failed: %v", err)
+	}
+	if got, want := env.ReadFile(link), "---\ntags: [auth, db]\n---\n# Task\n"; got != want {
+		t.Errorf("TASK.md = %q, want %q", got, want)
+	}
+
+	rootCmd.SetArgs([]string{"tag", "TASK.md", "auth", "--remove"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("tag --remove failed: %v", err)
+	}
+	if got, want := env.ReadFile(link), "---\ntags: [db]\n---\n# Task\n"; got != want {
+		t.Errorf("TASK.md = %q, want %q", got, want)
+	}
+
+	rootCmd.SetArgs([]string{"tag", "TASK.md", "db", "--remove"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("tag --remove failed: %v", err)
+	}
+	if got, want := env.ReadFile(link), "# Task\n"; got != want {
+		t.Errorf("TASK.md = %q, want %q", got, want)
+	}
+}
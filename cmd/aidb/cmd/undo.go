@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/journal"
+	"github.com/spf13/cobra"
+)
+
+var undoList bool
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Revert the last add, remove, mv, restore, seen, unseen, review or promote",
+	Long: `Revert the most recent add, remove, mv, restore, seen, unseen, review or
+branch promote.
+
+Every such operation is journaled under ~/.aidb/.journal/ before it touches
+anything, so a failure part way rolls back the whole batch and an
+interrupted run is recovered by the next command that changes the database.
+Undo refuses when a file has since been moved or recreated, or when seen
+state, tags, links or the review date the operation set were changed again,
+rather than overwrite them. Metadata the operation did not touch is kept.
+
+Examples:
+  aidb undo
+  aidb undo --list`,
+	Args:        cobra.NoArgs,
+	RunE:        runUndo,
+	Annotations: writesDB,
+}
+
+func init() {
+	undoCmd.Flags().BoolVar(&undoList, "list", false, "List journaled operations instead")
+	rootCmd.AddCommand(undoCmd)
+}
+
+func runUndo(cmd *cobra.Command, args []string) error {
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+
+	if undoList {
+		entries, err := journal.List(cfg.DBDir)
+		if err != nil {
+			return fmt.Errorf("failed to read journal: %w", err)
+		}
+		if entries == nil {
+			entries = []*journal.Entry{}
+		}
+		if flagJSON {
+			return writeJSON(cmd, entries)
+		}
+		out := cmd.OutOrStdout()
+		for _, e := range entries {
+			fmt.Fprintf(out, "%s  %-6s %-11s %s\n", e.Time.Local().Format("2006-01-02 15:04:05"),
+				e.Kind, e.State, strings.Join(e.Args, " "))
+		}
+		return nil
+	}
+
+	e, err := journal.Last(cfg.DBDir)
+	if err != nil {
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+	if e == nil {
+		return fmt.Errorf("nothing to undo")
+	}
+
+	err = e.Undo()
+	restage(cfg, e.Touched)
+	if err != nil {
+		return fmt.Errorf("failed to undo %s: %w", e.Kind, err)
+	}
+
+	if flagJSON {
+		return writeJSON(cmd, e)
+	}
+	printSuccess(fmt.Sprintf("Undid %s %s", e.Kind, strings.Join(e.Args, " ")))
+	return nil
+}
+
+// rollbackJournal reverts a failed operation and restages what it changed
+func rollbackJournal(cfg *config.Config, j *journal.Entry) {
+	if err := j.Rollback(); err != nil {
+		printError(fmt.Sprintf("rollback incomplete: %v", err))
+	}
+	restage(cfg, j.Touched)
+}
+
+// recoverJournal rolls back operations interrupted by a crash
+func recoverJournal(cfg *config.Config) {
+	recovered, err := journal.Recover(cfg.DBDir)
+	for _, e := range recovered {
+		restage(cfg, e.Touched)
+		printNotice(fmt.Sprintf("rolled back interrupted %s %s", e.Kind, strings.Join(e.Args, " ")))
+	}
+	if err != nil {
+		printError(fmt.Sprintf("journal recovery: %v", err))
+	}
+}
+
+// restage brings the git index and search index in line with reverted files
+func restage(cfg *config.Config, relPaths []string) {
+	if len(relPaths) == 0 {
+		return
+	}
+	for _, relPath := range relPaths {
+		exec.Command("git", "-C", cfg.DBDir, "add", "-A", "--", relPath).Run()
+	}
+	updateIndex(cfg, relPaths...)
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/KakkoiDev/aidb/internal/journal"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+func TestUndoCommand_Add(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+
+	env.CreateFile(filepath.Join(repoDir, "A.md"), "a")
+	env.CreateFile(filepath.Join(repoDir, "B.md"), "b")
+
+	rootCmd.SetArgs([]string{"add", "A.md", "B.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if !env.IsSymlink(filepath.Join(repoDir, "A.md")) {
+		t.Fatal("A.md should be a symlink after add")
+	}
+
+	rootCmd.SetArgs([]string{"undo"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+
+	for _, name := range []string{"A.md", "B.md"} {
+		path := filepath.Join(repoDir, name)
+		if env.IsSymlink(path) {
+			t.Errorf("%s should be a regular file after undo", name)
+		}
+		if env.FileExists(filepath.Join(env.DBDir, "myproject", "feature-x", name)) {
+			t.Errorf("%s should be gone from the database", name)
+		}
+	}
+	if got := env.ReadFile(filepath.Join(repoDir, "A.md")); got != "a" {
+		t.Errorf("A.md = %q, want %q", got, "a")
+	}
+
+	meta, _ := metadata.New(env.DBDir)
+	if info := meta.GetInfo("myproject/feature-x/A.md"); info != nil {
+		t.Errorf("A.md metadata should be removed, got %+v", info)
+	}
+
+	// Nothing left to undo
+	rootCmd.SetArgs([]string{"undo"})
+	if err := rootCmd.Execute(); err == nil {
+		t.Error("second undo should fail")
+	}
+}
+
+func TestUndoCommand_Seen(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	env.CreateFile(filepath.Join(env.DBDir, "myproject", "feature-x", "TASK.md"), "task")
+
+	rootCmd.SetArgs([]string{"seen", "myproject/feature-x/TASK.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("seen failed: %v", err)
+	}
+
+	rootCmd.SetArgs([]string{"undo"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+
+	meta, _ := metadata.New(env.DBDir)
+	if c := meta.Consumer("myproject/feature-x/TASK.md", metadata.DefaultAgent); c != nil {
+		t.Error("TASK.md should be unseen after undo")
+	}
+}
+
+func TestUndoCommand_SeenKeepsLaterTags(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+	rel := "myproject/feature-x/schema.sql"
+	env.CreateFile(filepath.Join(env.DBDir, rel), "create table t;")
+
+	for _, args := range [][]string{{"seen", rel}, {"tag", "/" + rel, "auth"}, {"undo"}} {
+		rootCmd.SetArgs(args)
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("%v failed: %v", args, err)
+		}
+	}
+
+	meta, _ := metadata.New(env.DBDir)
+	if c := meta.Consumer(rel, metadata.DefaultAgent); c != nil {
+		t.Error("schema.sql should be unseen after undo")
+	}
+	if tags := meta.Tags(rel); len(tags) != 1 || tags[0] != "auth" {
+		t.Errorf("tags = %v, want [auth] kept", tags)
+	}
+}
+
+func TestRecoverInterruptedAdd(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+
+	// An add that died between moving the file and linking it back
+	src := filepath.Join(repoDir, "TASK.md")
+	dst := filepath.Join(env.DBDir, "myproject", "feature-x", "TASK.md")
+	env.CreateFile(src, "task")
+	os.MkdirAll(filepath.Dir(dst), 0755)
+
+	j, err := journal.Begin(env.DBDir, "add", []string{"TASK.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pretend the process that started it is gone
+	file := filepath.Join(env.DBDir, journal.Dir, j.ID+".json")
+	data, _ := os.ReadFile(file)
+	os.WriteFile(file, []byte(strings.Replace(string(data), fmt.Sprintf(`"pid": %d`, os.Getpid()), `"pid": 0`, 1)), 0644)
+	if err := j.Move(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read-only commands leave it alone
+	rootCmd.SetArgs([]string{"list"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if !env.FileExists(dst) {
+		t.Fatal("list should not recover the journal")
+	}
+
+	// Commands that change the database recover it first
+	defer func() { undoList = false }()
+	rootCmd.SetArgs([]string{"undo", "--list"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("undo --list failed: %v", err)
+	}
+
+	if got := env.ReadFile(src); got != "task" {
+		t.Errorf("TASK.md = %q, want it restored", got)
+	}
+	if env.FileExists(dst) {
+		t.Error("interrupted add should be rolled back from the database")
+	}
+}
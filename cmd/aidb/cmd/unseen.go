@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 
 	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/journal"
 	"github.com/KakkoiDev/aidb/internal/metadata"
 	"github.com/spf13/cobra"
 )
@@ -14,11 +15,14 @@ var unseenCmd = &cobra.Command{
 	Short: "Mark file(s) for re-processing",
 	Long: `Mark one or more files as unseen for re-processing by AI agents.
 
+Only the seen state of the agent named by --agent or AIDB_AGENT is cleared.
+
 Examples:
   aidb unseen TASK.md
   aidb unseen "project/main/*.md"`,
-	Args: cobra.MinimumNArgs(1),
-	RunE: runUnseen,
+	Args:        cobra.MinimumNArgs(1),
+	RunE:        runUnseen,
+	Annotations: writesDB,
 }
 
 func init() {
@@ -36,26 +40,31 @@ func runUnseen(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load metadata: %w", err)
 	}
 
+	j, err := journal.Begin(cfg.DBDir, "unseen", args)
+	if err != nil {
+		return fmt.Errorf("failed to start journal: %w", err)
+	}
+
+	agent := currentAgent()
 	count := 0
 	for _, pattern := range args {
-		matches, err := filepath.Glob(filepath.Join(cfg.DBDir, pattern))
+		matches, err := expandDBPattern(cfg.DBDir, pattern)
 		if err != nil {
-			printError(fmt.Sprintf("invalid pattern: %s", pattern))
+			printError(err.Error())
 			continue
 		}
 
-		if len(matches) == 0 {
-			// Try as literal path
-			matches = []string{filepath.Join(cfg.DBDir, pattern)}
-		}
-
 		for _, path := range matches {
 			relPath, err := filepath.Rel(cfg.DBDir, path)
 			if err != nil {
 				continue
 			}
 
-			meta.MarkUnseen(relPath)
+			if err := j.Meta(relPath, meta.GetInfo(relPath)); err != nil {
+				printError(fmt.Sprintf("%s: failed to journal: %v", relPath, err))
+				continue
+			}
+			meta.MarkUnseen(relPath, agent)
 			printSuccess(fmt.Sprintf("Marked unseen: %s", relPath))
 			count++
 		}
@@ -63,9 +72,13 @@ func runUnseen(cmd *cobra.Command, args []string) error {
 
 	if count > 0 {
 		if err := meta.Save(); err != nil {
+			j.Rollback()
 			return fmt.Errorf("failed to save metadata: %w", err)
 		}
 	}
+	if err := j.Commit(); err != nil {
+		printWarning(fmt.Sprintf("failed to finish journal: %v", err))
+	}
 
 	return nil
 }
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/KakkoiDev/aidb/internal/config"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+var whereCmd = &cobra.Command{
+	Use:   "where <file|glob>...",
+	Short: "Show where tracked files are linked",
+	Long: `List the checkout symlinks recorded for tracked files, on every host.
+
+Files resolve like aidb show; a symlink in the current directory works too.
+Links on this host are checked: ok, missing (nothing there any more) or
+replaced (something else is there). Hosts are identified by hostname, or
+AIDB_HOST when set.
+
+Examples:
+  aidb where TASK.md
+  aidb where "docs/*.md" --json`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runWhere,
+}
+
+func init() {
+	rootCmd.AddCommand(whereCmd)
+}
+
+// FileLinks is the recorded link locations of one file
+type FileLinks struct {
+	Path  string       `json:"path"`
+	Links []LinkStatus `json:"links"`
+}
+
+// LinkStatus is a recorded link and, on this host, whether it still holds
+type LinkStatus struct {
+	metadata.Link
+	Status string `json:"status"` // ok, missing, replaced, or remote for other hosts
+}
+
+func runWhere(cmd *cobra.Command, args []string) error {
+	cfg, err := config.New()
+	if err != nil {
+		return err
+	}
+	meta, err := metadata.New(cfg.DBDir)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata: %w", err)
+	}
+
+	var relPaths []string
+	for _, arg := range args {
+		if relPath, ok := linkedDBPath(cfg, arg); ok {
+			relPaths = append(relPaths, relPath)
+			continue
+		}
+		matches, err := resolveShowPattern(cfg, arg)
+		if err != nil {
+			return err
+		}
+		relPaths = append(relPaths, matches...)
+	}
+
+	host := hostID()
+	files := []FileLinks{}
+	for _, relPath := range relPaths {
+		f := FileLinks{Path: filepath.ToSlash(relPath), Links: []LinkStatus{}}
+		target := filepath.Join(cfg.DBDir, relPath)
+		for _, l := range meta.Links(relPath) {
+			status := "remote"
+			if l.Host == host {
+				status = linkState(l.Path, target)
+			}
+			f.Links = append(f.Links, LinkStatus{Link: l, Status: status})
+		}
+		files = append(files, f)
+	}
+
+	if flagJSON {
+		return writeJSON(cmd, files)
+	}
+
+	out := cmd.OutOrStdout()
+	for _, f := range files {
+		fmt.Fprintln(out, f.Path)
+		if len(f.Links) == 0 {
+			fmt.Fprintln(out, colorGray("  no recorded links"))
+			continue
+		}
+		for _, l := range f.Links {
+			var status string
+			switch l.Status {
+			case "ok":
+				status = colorGreen(l.Status)
+			case "remote":
+				status = colorGray(l.Status)
+			default:
+				status = colorYellow(l.Status)
+			}
+			fmt.Fprintf(out, "  %-8s %s:%s\n", status, l.Host, l.Path)
+		}
+	}
+	return nil
+}
+
+// linkedDBPath resolves arg as a symlink into the database
+func linkedDBPath(cfg *config.Config, arg string) (string, bool) {
+	target, err := os.Readlink(arg)
+	if err != nil || !strings.HasPrefix(target, cfg.DBDir+string(filepath.Separator)) {
+		return "", false
+	}
+	relPath, err := filepath.Rel(cfg.DBDir, target)
+	return relPath, err == nil
+}
+
+// linkState reports whether path is still a symlink to target
+func linkState(path, target string) string {
+	existing, err := os.Readlink(path)
+	switch {
+	case err == nil && existing == target:
+		return "ok"
+	case os.IsNotExist(err):
+		return "missing"
+	default:
+		return "replaced"
+	}
+}
+
+// hostID identifies this machine in recorded link locations
+func hostID() string {
+	if host := os.Getenv("AIDB_HOST"); host != "" {
+		return host
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "unknown"
+	}
+	return host
+}
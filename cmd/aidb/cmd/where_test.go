@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KakkoiDev/aidb/internal/testutil"
+)
+
+func TestWhereCommand(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer func() { flagJSON = false }()
+	t.Setenv("AIDB_HOST", "laptop")
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	docsDir := filepath.Join(repoDir, "docs")
+	env.CreateFile(filepath.Join(docsDir, "PLAN.md"), "plan")
+	if err := os.Chdir(docsDir); err != nil {
+		t.Fatal(err)
+	}
+	env.InitDBRepo()
+
+	rootCmd.SetArgs([]string{"add", "PLAN.md"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+
+	where := func() []FileLinks {
+		t.Helper()
+		var buf bytes.Buffer
+		rootCmd.SetOut(&buf)
+		rootCmd.SetArgs([]string{"where", "/myproject/feature-x/PLAN.md", "--json"})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("where failed: %v", err)
+		}
+		var files []FileLinks
+		if err := json.Unmarshal(buf.Bytes(), &files); err != nil {
+			t.Fatalf("failed to parse JSON: %v", err)
+		}
+		return files
+	}
+
+	link := filepath.Join(docsDir, "PLAN.md")
+	files := where()
+	if len(files) != 1 || len(files[0].Links) != 1 {
+		t.Fatalf("where = %+v", files)
+	}
+	if l := files[0].Links[0]; l.Host != "laptop" || l.Path != link || l.Status != "ok" {
+		t.Errorf("link = %+v", l)
+	}
+
+	if err := os.Remove(link); err != nil {
+		t.Fatal(err)
+	}
+	if status := where()[0].Links[0].Status; status != "missing" {
+		t.Errorf("status = %s, want missing", status)
+	}
+
+	// link restores the recorded location, not <root>/PLAN.md
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+	rootCmd.SetArgs([]string{"link"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("link failed: %v", err)
+	}
+	if !env.IsSymlink(link) || env.FileExists(filepath.Join(repoDir, "PLAN.md")) {
+		t.Error("link should recreate the recorded location")
+	}
+}
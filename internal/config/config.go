@@ -9,20 +9,27 @@ import (
 
 // Config holds aidb configuration
 type Config struct {
-	HomeDir string
-	DBDir   string // ~/.aidb
+	HomeDir  string
+	DBDir    string // ~/.aidb unless db.path is set
+	Settings *UserConfig
 }
 
-// New creates a new Config with defaults
+// New creates a new Config from the layered settings (defaults, file, env, flags)
 func New() (*Config, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, err
 	}
 
+	settings, err := Load(homeDir)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Config{
-		HomeDir: homeDir,
-		DBDir:   filepath.Join(homeDir, ".aidb"),
+		HomeDir:  homeDir,
+		DBDir:    ExpandPath(settings.DB.Path, homeDir),
+		Settings: settings,
 	}, nil
 }
 
@@ -32,34 +39,31 @@ func (c *Config) IsGitRepo() bool {
 	if err != nil {
 		return false
 	}
-	return getGitRepoName(cwd) != ""
+	return gitToplevel(cwd) != ""
 }
 
-// GetProjectFromCwd detects project/branch from current working directory
+// GetProjectFromCwd detects project/branch from current working directory.
+// The project is the storage directory of the repo (see ResolveProject).
 func (c *Config) GetProjectFromCwd() (project, branch string, err error) {
 	cwd, err := os.Getwd()
 	if err != nil {
 		return "", "", err
 	}
 
-	// Try to detect git repo name
-	project = getGitRepoName(cwd)
-	if project != "" {
-		// Git repo: use repo name and branch
-		branch = getGitBranch(cwd)
-		if branch == "" {
-			branch = "main"
-		}
-		return project, branch, nil
+	p, err := c.ResolveProject(cwd)
+	if err != nil {
+		return "", "", err
 	}
-
-	// Non-git: use directory name, default branch
-	project = filepath.Base(cwd)
-	return project, "main", nil
+	if p.Source == "path" {
+		// Non-git: use directory name, default branch
+		return filepath.Base(cwd), "main", nil
+	}
+	return p.Dir, p.Branch, nil
 }
 
 // GetStoragePath returns the storage path for a file based on current context
-// Git repos: ~/.aidb/{repo-name}/{branch}/{filename}
+// Git repos: ~/.aidb/{project}/{branch}/{filename}, where project is derived
+// from the origin URL (host/owner/repo) or the repo directory name
 // Non-git: ~/.aidb/{path-from-home}/{filename}
 func (c *Config) GetStoragePath(filename string) (string, error) {
 	cwd, err := os.Getwd()
@@ -67,53 +71,108 @@ func (c *Config) GetStoragePath(filename string) (string, error) {
 		return "", err
 	}
 
-	repoName := getGitRepoName(cwd)
-	if repoName != "" {
-		// Git repo: use repo name and branch
-		branch := getGitBranch(cwd)
-		if branch == "" {
-			branch = "main"
-		}
-		return filepath.Join(c.DBDir, repoName, branch, filename), nil
+	p, err := c.ResolveProject(cwd)
+	if err != nil {
+		return "", err
 	}
+	return c.storagePath(p, filename), nil
+}
 
-	// Non-git: use path relative to home
-	// Resolve symlinks for consistent path comparison
-	homeResolved, _ := filepath.EvalSymlinks(c.HomeDir)
-	cwdResolved, _ := filepath.EvalSymlinks(cwd)
-	if homeResolved == "" {
-		homeResolved = c.HomeDir
-	}
-	if cwdResolved == "" {
-		cwdResolved = cwd
+func (c *Config) storagePath(p *Project, filename string) string {
+	if p.Source == "path" {
+		return filepath.Join(c.DBDir, p.Dir, filename)
 	}
-
-	relPath, err := filepath.Rel(homeResolved, cwdResolved)
-	if err != nil {
-		// Fallback to directory name if can't get relative path
-		relPath = filepath.Base(cwd)
-	}
-	return filepath.Join(c.DBDir, relPath, filename), nil
+	return filepath.Join(c.DBDir, filepath.FromSlash(p.Dir), p.Branch, filename)
 }
 
-// EnsureStorageDir creates and returns the storage directory for current context
+// EnsureStorageDir creates and returns the storage directory for current
+// context, recording the project's directory (see SaveProject)
 func (c *Config) EnsureStorageDir() (string, error) {
-	path, err := c.GetStoragePath("dummy")
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	p, err := c.ResolveProject(cwd)
 	if err != nil {
 		return "", err
 	}
-	dir := filepath.Dir(path)
+	if err := c.SaveProject(p); err != nil {
+		return "", err
+	}
+	dir := filepath.Dir(c.storagePath(p, "dummy"))
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return "", err
 	}
 	return dir, nil
 }
 
+// CheckoutsFile lists the checkouts linked on this machine, for aidb link --all
+const CheckoutsFile = ".checkouts.json"
+
+// runtimeEntries are files aidb writes into the database that must never be committed
+var runtimeEntries = []string{
+	"backup.log",
+	".daemon/",
+	".index/",
+	".journal/",
+	CheckoutsFile,
+}
+
+// IsInternalPath returns true for database paths that are not tracked knowledge:
+// git internals, aidb bookkeeping and runtime state
+func IsInternalPath(relPath string) bool {
+	first := strings.SplitN(filepath.ToSlash(relPath), "/", 2)[0]
+	switch first {
+	case ".git", ".metadata.json", ".gitignore", ".gitattributes", projectsFile:
+		return true
+	}
+	for _, entry := range runtimeEntries {
+		if first == strings.TrimSuffix(entry, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// EnsureGitignore adds aidb runtime entries to the database .gitignore
+func (c *Config) EnsureGitignore() error {
+	path := filepath.Join(c.DBDir, ".gitignore")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	existing := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		existing[strings.TrimSpace(line)] = true
+	}
+
+	content := string(data)
+	changed := false
+	for _, entry := range runtimeEntries {
+		if existing[entry] {
+			continue
+		}
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += entry + "\n"
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
 // EnsureDBDir creates the base database directory and initializes git if needed
 func (c *Config) EnsureDBDir() error {
 	if err := os.MkdirAll(c.DBDir, 0755); err != nil {
 		return err
 	}
+	if err := c.EnsureGitignore(); err != nil {
+		return err
+	}
 
 	// Check if git is initialized
 	gitDir := filepath.Join(c.DBDir, ".git")
@@ -127,22 +186,54 @@ func (c *Config) EnsureDBDir() error {
 	return nil
 }
 
-// getGitRepoName returns the git repository name
-func getGitRepoName(dir string) string {
-	cmd := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel")
+// getGitBranch returns the current git branch. A detached HEAD maps to the
+// branch being rebased, an exact tag, or the nearest branch, in that order.
+func getGitBranch(dir string) string {
+	cmd := exec.Command("git", "-C", dir, "rev-parse", "--abbrev-ref", "HEAD")
 	out, err := cmd.Output()
 	if err != nil {
 		return ""
 	}
-	return filepath.Base(strings.TrimSpace(string(out)))
+	if branch := strings.TrimSpace(string(out)); branch != "HEAD" {
+		return branch
+	}
+	return detachedName(dir)
 }
 
-// getGitBranch returns the current git branch
-func getGitBranch(dir string) string {
-	cmd := exec.Command("git", "-C", dir, "rev-parse", "--abbrev-ref", "HEAD")
-	out, err := cmd.Output()
-	if err != nil {
-		return ""
+// detachedName picks a storage name for a detached HEAD, "detached" if none fits
+func detachedName(dir string) string {
+	for _, state := range []string{"rebase-merge", "rebase-apply"} {
+		out, err := exec.Command("git", "-C", dir, "rev-parse", "--git-path", state+"/head-name").Output()
+		if err != nil {
+			continue
+		}
+		path := strings.TrimSpace(string(out))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if data, err := os.ReadFile(path); err == nil {
+			if branch := strings.TrimPrefix(strings.TrimSpace(string(data)), "refs/heads/"); branch != "" {
+				return branch
+			}
+		}
+	}
+
+	if out, err := exec.Command("git", "-C", dir, "describe", "--tags", "--exact-match", "HEAD").Output(); err == nil {
+		if tag := strings.TrimSpace(string(out)); tag != "" {
+			return tag
+		}
+	}
+
+	// name-rev gives e.g. main~2; strip the ancestry suffix
+	out, err := exec.Command("git", "-C", dir, "name-rev", "--name-only", "--no-undefined", "--refs=refs/heads/*", "HEAD").Output()
+	if err == nil {
+		name := strings.TrimSpace(string(out))
+		if i := strings.IndexAny(name, "~^"); i >= 0 {
+			name = name[:i]
+		}
+		if name != "" {
+			return name
+		}
 	}
-	return strings.TrimSpace(string(out))
+	return "detached"
 }
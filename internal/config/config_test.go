@@ -178,3 +178,70 @@ func TestEnsureStorageDir(t *testing.T) {
 		t.Errorf("directory %q was not created", dir)
 	}
 }
+
+func TestNew_DBPathFromConfigFile(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+
+	env.CreateFile(filepath.Join(env.HomeDir, ".config", "aidb", "config.yaml"), "db:\n  path: ~/kb\n")
+
+	cfg, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := filepath.Join(env.HomeDir, "kb")
+	if cfg.DBDir != expected {
+		t.Errorf("DBDir = %q, want %q", cfg.DBDir, expected)
+	}
+}
+
+func TestNew_LayerPrecedence(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+	defer SetOverrides(nil)
+
+	env.CreateFile(filepath.Join(env.HomeDir, ".config", "aidb", "config.yaml"), "db:\n  path: /from/file\n")
+	t.Setenv("AIDB_DB_PATH", "/from/env")
+
+	cfg, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DBDir != "/from/env" {
+		t.Errorf("DBDir = %q, want env value %q", cfg.DBDir, "/from/env")
+	}
+
+	SetOverrides(map[string]string{"db.path": "/from/flag"})
+	cfg, err = New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DBDir != "/from/flag" {
+		t.Errorf("DBDir = %q, want flag value %q", cfg.DBDir, "/from/flag")
+	}
+}
+
+func TestStoragePath_UsesConfiguredDB(t *testing.T) {
+	env := testutil.New(t)
+	defer env.Cleanup()
+
+	customDB := filepath.Join(env.TempDir, "custom")
+	t.Setenv("AIDB_DB_PATH", customDB)
+
+	repoDir := env.InitGitRepoWithBranch("myproject", "feature-x")
+	if err := os.Chdir(repoDir); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _ := New()
+	path, err := cfg.GetStoragePath("TASK.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := filepath.Join(customDB, "myproject", "feature-x", "TASK.md")
+	if path != expected {
+		t.Errorf("path = %q, want %q", path, expected)
+	}
+}
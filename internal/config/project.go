@@ -0,0 +1,349 @@
+package config
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/KakkoiDev/aidb/internal/metadata"
+)
+
+// projectsFile maps remote-based project identities to their storage
+// directories; it is committed so every machine resolves the same tree
+const projectsFile = ".projects.json"
+
+// Project identifies where a working directory's files are stored
+type Project struct {
+	ID     string `json:"id"`               // host/owner/repo, or a directory-based name
+	Source string `json:"source"`           // origin, directory or path
+	Remote string `json:"remote,omitempty"` // origin URL the ID was derived from
+	Dir    string `json:"dir"`              // storage directory relative to the database
+	Branch string `json:"branch"`
+}
+
+// projectMap is the content of .projects.json
+type projectMap struct {
+	Version  int               `json:"version"`
+	Projects map[string]string `json:"projects"` // identity -> db-relative directory
+}
+
+// Submodule policies (git.submodules)
+const (
+	SubmodulesSuperproject = "superproject" // store under the outermost superproject
+	SubmodulesSeparate     = "separate"     // a submodule is a project of its own
+)
+
+// ResolveProject determines the project of dir. Git repos with an origin are
+// identified by the normalized remote URL; without one the repo directory name
+// is used, and outside git the path relative to home. Linked worktrees resolve
+// to their main repository, and submodules follow the git.submodules policy.
+func (c *Config) ResolveProject(dir string) (*Project, error) {
+	toplevel := gitToplevel(dir)
+	if toplevel == "" {
+		return c.pathProject(dir)
+	}
+	if c.submodulePolicy() == SubmodulesSuperproject {
+		for super := gitSuperproject(toplevel); super != ""; super = gitSuperproject(toplevel) {
+			toplevel = super
+		}
+	}
+
+	branch := getGitBranch(toplevel)
+	if branch == "" {
+		branch = "main"
+	}
+	name := filepath.Base(mainWorktree(toplevel))
+
+	remote := gitOrigin(toplevel)
+	id := NormalizeRemoteURL(remote)
+	if id == "" {
+		return &Project{ID: name, Source: "directory", Dir: name, Branch: branch}, nil
+	}
+
+	projectDir, err := c.projectDir(id, name, toplevel)
+	if err != nil {
+		return nil, err
+	}
+	return &Project{ID: id, Source: "origin", Remote: remote, Dir: projectDir, Branch: branch}, nil
+}
+
+// projectDir returns the storage directory for a remote identity. A tree
+// named after the checkout directory, stored before remote-based identities,
+// is adopted when its recorded links point into this repo; otherwise the
+// identity gets a fresh tree. Resolving records nothing (see SaveProject).
+func (c *Config) projectDir(id, legacyName, toplevel string) (string, error) {
+	m, err := c.loadProjectMap()
+	if err != nil {
+		return "", err
+	}
+	if dir, ok := m.Projects[id]; ok {
+		return dir, nil
+	}
+
+	if info, err := os.Stat(filepath.Join(c.DBDir, legacyName)); err == nil && info.IsDir() &&
+		!m.claimed(legacyName) && c.linkedFrom(legacyName, toplevel) {
+		return legacyName, nil
+	}
+	return filepath.ToSlash(id), nil
+}
+
+// SaveProject records the directory p resolved to, so it stays put once
+// knowledge is stored there. Commands call it before writing into the tree;
+// read-only commands leave .projects.json alone.
+func (c *Config) SaveProject(p *Project) error {
+	if p.Source != "origin" {
+		return nil
+	}
+	// Only persist into an existing database; resolving must not create one
+	if _, err := os.Stat(c.DBDir); err != nil {
+		return nil
+	}
+	m, err := c.loadProjectMap()
+	if err != nil {
+		return err
+	}
+	if dir, ok := m.Projects[p.ID]; ok && dir == p.Dir {
+		return nil
+	}
+	m.Projects[p.ID] = p.Dir
+	return c.saveProjectMap(m)
+}
+
+// linkedFrom reports whether a file stored under dir has a recorded link
+// inside one of the worktrees of the repo at toplevel
+func (c *Config) linkedFrom(dir, toplevel string) bool {
+	meta, err := metadata.New(c.DBDir)
+	if err != nil {
+		return false
+	}
+	roots := worktrees(toplevel)
+	for rel, info := range meta.Files {
+		if !strings.HasPrefix(rel, dir+"/") {
+			continue
+		}
+		for _, l := range info.Links {
+			for _, root := range roots {
+				if within(l.Path, root) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// worktrees lists the working trees of the repo at toplevel, as given and
+// with symlinks resolved
+func worktrees(toplevel string) []string {
+	roots := []string{toplevel}
+	if out, err := exec.Command("git", "-C", toplevel, "worktree", "list", "--porcelain").Output(); err == nil {
+		for _, line := range strings.Split(string(out), "\n") {
+			if root, ok := strings.CutPrefix(line, "worktree "); ok {
+				roots = append(roots, root)
+			}
+		}
+	}
+	for _, root := range roots {
+		if resolved, err := filepath.EvalSymlinks(root); err == nil && resolved != root {
+			roots = append(roots, resolved)
+		}
+	}
+	return roots
+}
+
+// within reports whether path is root or below it
+func within(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// ProjectMappings returns the recorded identity -> directory mappings
+func (c *Config) ProjectMappings() (map[string]string, error) {
+	m, err := c.loadProjectMap()
+	if err != nil {
+		return nil, err
+	}
+	return m.Projects, nil
+}
+
+func (m *projectMap) claimed(dir string) bool {
+	for _, d := range m.Projects {
+		if d == dir {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) loadProjectMap() (*projectMap, error) {
+	m := &projectMap{Version: 1, Projects: map[string]string{}}
+	data, err := os.ReadFile(filepath.Join(c.DBDir, projectsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Projects == nil {
+		m.Projects = map[string]string{}
+	}
+	return m, nil
+}
+
+func (c *Config) saveProjectMap(m *projectMap) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(c.DBDir, projectsFile), append(data, '\n'), 0644)
+}
+
+// pathProject identifies a non-git directory by its path relative to home
+func (c *Config) pathProject(dir string) (*Project, error) {
+	// Resolve symlinks for consistent path comparison
+	homeResolved, _ := filepath.EvalSymlinks(c.HomeDir)
+	dirResolved, _ := filepath.EvalSymlinks(dir)
+	if homeResolved == "" {
+		homeResolved = c.HomeDir
+	}
+	if dirResolved == "" {
+		dirResolved = dir
+	}
+
+	relPath, err := filepath.Rel(homeResolved, dirResolved)
+	if err != nil {
+		// Fallback to directory name if can't get relative path
+		relPath = filepath.Base(dir)
+	}
+	return &Project{ID: filepath.ToSlash(relPath), Source: "path", Dir: relPath}, nil
+}
+
+// NormalizeRemoteURL turns a git remote URL into host/owner/repo, dropping
+// scheme, credentials, port and .git suffix. Local paths yield "".
+//
+//	git@github.com:Acme/api.git        -> github.com/Acme/api
+//	https://github.com/Acme/api        -> github.com/Acme/api
+//	ssh://git@gitlab.example:2222/g/r  -> gitlab.example/g/r
+func NormalizeRemoteURL(remote string) string {
+	remote = strings.TrimSpace(remote)
+	if remote == "" {
+		return ""
+	}
+
+	var host, path string
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil || u.Scheme == "file" || u.Host == "" {
+			return ""
+		}
+		host, path = u.Hostname(), u.Path
+	} else {
+		// scp-like syntax: [user@]host:path
+		colon := strings.Index(remote, ":")
+		if colon < 0 || strings.Contains(remote[:colon], "/") {
+			return ""
+		}
+		host, path = remote[:colon], remote[colon+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	}
+
+	path = strings.Trim(path, "/")
+	path = strings.TrimSuffix(path, ".git")
+	if host == "" || path == "" {
+		return ""
+	}
+
+	var parts []string
+	for _, p := range strings.Split(path, "/") {
+		if p != "" && p != "." && p != ".." {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.ToLower(host) + "/" + strings.Join(parts, "/")
+}
+
+// SortedProjectIDs returns the identities of a mapping in order
+func SortedProjectIDs(mappings map[string]string) []string {
+	ids := make([]string, 0, len(mappings))
+	for id := range mappings {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func gitToplevel(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (c *Config) submodulePolicy() string {
+	if c.Settings == nil || c.Settings.Git.Submodules == "" {
+		return SubmodulesSuperproject
+	}
+	return c.Settings.Git.Submodules
+}
+
+// gitSuperproject returns the working tree of the repo dir is a submodule of
+func gitSuperproject(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-superproject-working-tree").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// mainWorktree returns the main working tree of the repo toplevel belongs to,
+// so linked worktrees (git worktree add ../api-fix) share its project name
+func mainWorktree(toplevel string) string {
+	out, err := exec.Command("git", "-C", toplevel, "rev-parse", "--git-common-dir").Output()
+	if err != nil {
+		return toplevel
+	}
+	common := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(toplevel, common)
+	}
+	if filepath.Base(common) == ".git" {
+		return filepath.Dir(common)
+	}
+	// Submodule or bare repo: .git/modules/<name> or <name>.git
+	if filepath.Base(filepath.Dir(common)) == "modules" {
+		return toplevel
+	}
+	return strings.TrimSuffix(common, ".git")
+}
+
+func gitOrigin(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// DefaultBranch returns the branch origin/HEAD points at in dir, or "main"
+func DefaultBranch(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD").Output()
+	if err == nil {
+		if branch := strings.TrimPrefix(strings.TrimSpace(string(out)), "origin/"); branch != "" {
+			return branch
+		}
+	}
+	return "main"
+}
@@ -0,0 +1,291 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UserConfig holds the settings that can be stored in ~/.config/aidb/config.yaml
+type UserConfig struct {
+	DB struct {
+		Path string `yaml:"path,omitempty"`
+	} `yaml:"db,omitempty"`
+	Backup struct {
+		Enabled   bool   `yaml:"enabled,omitempty"`
+		Interval  string `yaml:"interval,omitempty"`
+		Scheduler string `yaml:"scheduler,omitempty"`
+	} `yaml:"backup,omitempty"`
+	Git struct {
+		Remote     string `yaml:"remote,omitempty"`
+		Submodules string `yaml:"submodules,omitempty"`
+	} `yaml:"git,omitempty"`
+	Daemon struct {
+		Debounce     string `yaml:"debounce,omitempty"`
+		PushInterval string `yaml:"push-interval,omitempty"`
+	} `yaml:"daemon,omitempty"`
+	Serve struct {
+		Addr  string `yaml:"addr,omitempty"`
+		Token string `yaml:"token,omitempty"`
+	} `yaml:"serve,omitempty"`
+}
+
+// Key describes a single setting addressable as "section.name"
+type Key struct {
+	Name string
+	Get  func(u *UserConfig) string
+	Set  func(u *UserConfig, value string) error
+}
+
+var keys = map[string]Key{
+	"db.path": {
+		Name: "db.path",
+		Get:  func(u *UserConfig) string { return u.DB.Path },
+		Set: func(u *UserConfig, v string) error {
+			u.DB.Path = v
+			return nil
+		},
+	},
+	"backup.enabled": {
+		Name: "backup.enabled",
+		Get:  func(u *UserConfig) string { return strconv.FormatBool(u.Backup.Enabled) },
+		Set: func(u *UserConfig, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid boolean: %s", v)
+			}
+			u.Backup.Enabled = b
+			return nil
+		},
+	},
+	"backup.interval": {
+		Name: "backup.interval",
+		Get:  func(u *UserConfig) string { return u.Backup.Interval },
+		Set: func(u *UserConfig, v string) error {
+			if _, err := time.ParseDuration(v); err != nil {
+				return fmt.Errorf("invalid duration: %s", v)
+			}
+			u.Backup.Interval = v
+			return nil
+		},
+	},
+	"backup.scheduler": {
+		Name: "backup.scheduler",
+		Get:  func(u *UserConfig) string { return u.Backup.Scheduler },
+		Set: func(u *UserConfig, v string) error {
+			switch v {
+			case "auto", "launchd", "systemd", "cron":
+			default:
+				return fmt.Errorf("invalid scheduler: %s (use auto, launchd, systemd or cron)", v)
+			}
+			u.Backup.Scheduler = v
+			return nil
+		},
+	},
+	"daemon.debounce": {
+		Name: "daemon.debounce",
+		Get:  func(u *UserConfig) string { return u.Daemon.Debounce },
+		Set: func(u *UserConfig, v string) error {
+			if _, err := time.ParseDuration(v); err != nil {
+				return fmt.Errorf("invalid duration: %s", v)
+			}
+			u.Daemon.Debounce = v
+			return nil
+		},
+	},
+	"daemon.push-interval": {
+		Name: "daemon.push-interval",
+		Get:  func(u *UserConfig) string { return u.Daemon.PushInterval },
+		Set: func(u *UserConfig, v string) error {
+			if _, err := time.ParseDuration(v); err != nil {
+				return fmt.Errorf("invalid duration: %s", v)
+			}
+			u.Daemon.PushInterval = v
+			return nil
+		},
+	},
+	"serve.addr": {
+		Name: "serve.addr",
+		Get:  func(u *UserConfig) string { return u.Serve.Addr },
+		Set: func(u *UserConfig, v string) error {
+			if _, _, err := net.SplitHostPort(v); err != nil {
+				return fmt.Errorf("invalid address: %s (use host:port)", v)
+			}
+			u.Serve.Addr = v
+			return nil
+		},
+	},
+	"serve.token": {
+		Name: "serve.token",
+		Get:  func(u *UserConfig) string { return u.Serve.Token },
+		Set: func(u *UserConfig, v string) error {
+			u.Serve.Token = v
+			return nil
+		},
+	},
+	"git.remote": {
+		Name: "git.remote",
+		Get:  func(u *UserConfig) string { return u.Git.Remote },
+		Set: func(u *UserConfig, v string) error {
+			u.Git.Remote = v
+			return nil
+		},
+	},
+	"git.submodules": {
+		Name: "git.submodules",
+		Get:  func(u *UserConfig) string { return u.Git.Submodules },
+		Set: func(u *UserConfig, v string) error {
+			switch v {
+			case SubmodulesSuperproject, SubmodulesSeparate:
+			default:
+				return fmt.Errorf("invalid submodule policy: %s (use superproject or separate)", v)
+			}
+			u.Git.Submodules = v
+			return nil
+		},
+	},
+}
+
+// overrides holds values set by CLI flags, the highest precedence layer
+var overrides = map[string]string{}
+
+// Keys returns all known setting names in sorted order
+func Keys() []string {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupKey returns the setting with the given name
+func LookupKey(name string) (Key, bool) {
+	k, ok := keys[name]
+	return k, ok
+}
+
+// EnvName returns the environment variable that overrides a setting (db.path -> AIDB_DB_PATH)
+func EnvName(key string) string {
+	r := strings.NewReplacer(".", "_", "-", "_")
+	return "AIDB_" + strings.ToUpper(r.Replace(key))
+}
+
+// SetOverrides replaces the CLI flag layer. Empty values are ignored.
+func SetOverrides(values map[string]string) {
+	overrides = map[string]string{}
+	for k, v := range values {
+		if v != "" {
+			overrides[k] = v
+		}
+	}
+}
+
+// ConfigPath returns the path of the user config file
+func ConfigPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "aidb", "config.yaml")
+}
+
+// Defaults returns the built-in settings
+func Defaults(homeDir string) *UserConfig {
+	u := &UserConfig{}
+	u.DB.Path = filepath.Join(homeDir, ".aidb")
+	u.Backup.Interval = "1h"
+	u.Backup.Scheduler = "auto"
+	u.Daemon.Debounce = "2s"
+	u.Daemon.PushInterval = "15m"
+	u.Serve.Addr = "127.0.0.1:7420"
+	u.Git.Submodules = SubmodulesSuperproject
+	return u
+}
+
+// LoadFile reads only the config file layer, returning an empty config if it does not exist
+func LoadFile() (*UserConfig, error) {
+	u := &UserConfig{}
+	if err := readFile(ConfigPath(), u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// SaveFile writes the config file layer. It is private to the user
+// because it may hold the API token.
+func SaveFile(u *UserConfig) error {
+	path := ConfigPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(u)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file
+	return os.Chmod(path, 0600)
+}
+
+// Load resolves settings from built-in defaults, the config file,
+// AIDB_* environment variables and CLI flags, later layers winning
+func Load(homeDir string) (*UserConfig, error) {
+	u := Defaults(homeDir)
+
+	// The file is decoded over the defaults so only keys it sets take effect
+	if err := readFile(ConfigPath(), u); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ConfigPath(), err)
+	}
+
+	for _, name := range Keys() {
+		if v, ok := os.LookupEnv(EnvName(name)); ok && v != "" {
+			if err := keys[name].Set(u, v); err != nil {
+				return nil, fmt.Errorf("%s: %w", EnvName(name), err)
+			}
+		}
+	}
+
+	for name, v := range overrides {
+		k, ok := keys[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown config key: %s", name)
+		}
+		if err := k.Set(u, v); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return u, nil
+}
+
+// ExpandPath resolves ~ and relative paths against the home directory
+func ExpandPath(path, homeDir string) string {
+	if path == "~" {
+		return homeDir
+	}
+	if strings.HasPrefix(path, "~/") {
+		path = filepath.Join(homeDir, path[2:])
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(homeDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+func readFile(path string, u *UserConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return yaml.Unmarshal(data, u)
+}
@@ -0,0 +1,131 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Paths locates the daemon's runtime files
+type Paths struct {
+	Dir     string // e.g. ~/.aidb/.daemon
+	PIDFile string
+	Socket  string
+}
+
+// NewPaths returns runtime file locations under dir
+func NewPaths(dir string) Paths {
+	return Paths{
+		Dir:     dir,
+		PIDFile: filepath.Join(dir, "daemon.pid"),
+		Socket:  filepath.Join(dir, "daemon.sock"),
+	}
+}
+
+// ReadPID returns the recorded pid if that process is still alive
+func (p Paths) ReadPID() (int, bool) {
+	data, err := os.ReadFile(p.PIDFile)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	// Signal 0 checks existence without delivering anything
+	if err := syscall.Kill(pid, 0); err != nil && err != syscall.EPERM {
+		return 0, false
+	}
+	return pid, true
+}
+
+// Acquire writes the pidfile, failing if another daemon is alive
+func (p Paths) Acquire() error {
+	if pid, alive := p.ReadPID(); alive && pid != os.Getpid() {
+		return fmt.Errorf("daemon already running (pid %d)", pid)
+	}
+	if err := os.MkdirAll(p.Dir, 0755); err != nil {
+		return err
+	}
+	// A stale socket from a crashed daemon would make Listen fail
+	os.Remove(p.Socket)
+	return os.WriteFile(p.PIDFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// Release removes the pidfile and socket
+func (p Paths) Release() {
+	os.Remove(p.Socket)
+	os.Remove(p.PIDFile)
+}
+
+// Serve answers "status" and "stop" requests on the control socket until ctx ends.
+// stop is called when a client asks the daemon to exit.
+func (d *Daemon) Serve(ctx context.Context, socket string, stop func()) error {
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go d.handle(conn, stop)
+	}
+}
+
+func (d *Daemon) handle(conn net.Conn, stop func()) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+
+	enc := json.NewEncoder(conn)
+	switch strings.TrimSpace(line) {
+	case "status":
+		enc.Encode(d.State())
+	case "stop":
+		enc.Encode(d.State())
+		stop()
+	default:
+		enc.Encode(map[string]string{"error": "unknown request"})
+	}
+}
+
+// Query sends a request ("status" or "stop") to a running daemon
+func Query(socket, request string) (*State, error) {
+	conn, err := net.DialTimeout("unix", socket, 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := fmt.Fprintf(conn, "%s\n", request); err != nil {
+		return nil, err
+	}
+	state := &State{}
+	if err := json.NewDecoder(conn).Decode(state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
@@ -0,0 +1,196 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxPushBackoff caps the retry delay while the remote is unreachable
+const maxPushBackoff = time.Hour
+
+// maxWaitFactor bounds, in debounce periods, how long a steady stream of
+// changes can postpone a commit when Options.MaxWait is unset
+const maxWaitFactor = 10
+
+// Options configures the auto-commit loop
+type Options struct {
+	Debounce     time.Duration // quiet period after the last change before committing
+	MaxWait      time.Duration // commit at the latest this long after the first pending change
+	PushInterval time.Duration // time between pushes; 0 disables pushing
+	// Changed is called with the paths of a debounced burst before committing
+	Changed func(paths []string)
+	// Commit stages everything and commits, returning the changed paths (none if clean)
+	Commit func() ([]string, error)
+	// Push sends commits to the remote
+	Push func() error
+	// Log receives one line per action
+	Log func(msg string)
+}
+
+// State is a snapshot of what the daemon has done, served over the control socket
+type State struct {
+	PID         int       `json:"pid"`
+	StartedAt   time.Time `json:"startedAt"`
+	Pending     int       `json:"pending"`
+	LastCommit  time.Time `json:"lastCommit,omitempty"`
+	LastPush    time.Time `json:"lastPush,omitempty"`
+	NextPush    time.Time `json:"nextPush,omitempty"`
+	LastError   string    `json:"lastError,omitempty"`
+	PushBackoff string    `json:"pushBackoff,omitempty"`
+}
+
+// Daemon debounces file changes into commits and pushes on a cadence
+type Daemon struct {
+	opts Options
+
+	mu    sync.Mutex
+	state State
+}
+
+// New creates a Daemon
+func New(opts Options, pid int) *Daemon {
+	if opts.Log == nil {
+		opts.Log = func(string) {}
+	}
+	return &Daemon{
+		opts:  opts,
+		state: State{PID: pid, StartedAt: time.Now().UTC()},
+	}
+}
+
+// State returns a copy of the current state
+func (d *Daemon) State() State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state
+}
+
+// Run consumes change events until ctx is cancelled or events closes.
+// Pending changes are committed before returning.
+func (d *Daemon) Run(ctx context.Context, events <-chan string) error {
+	pending := map[string]bool{}
+
+	maxWait := d.opts.MaxWait
+	if maxWait <= 0 {
+		maxWait = maxWaitFactor * d.opts.Debounce
+	}
+
+	var commitTimer, deadline <-chan time.Time
+	var pushTimer <-chan time.Time
+	backoff := d.opts.PushInterval
+	if d.opts.PushInterval > 0 {
+		pushTimer = d.schedulePush(d.opts.PushInterval)
+	}
+
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		if d.opts.Changed != nil {
+			paths := make([]string, 0, len(pending))
+			for p := range pending {
+				paths = append(paths, p)
+			}
+			sort.Strings(paths)
+			d.opts.Changed(paths)
+		}
+		d.commit()
+		pending = map[string]bool{}
+		d.setPending(0)
+		commitTimer, deadline = nil, nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			flush()
+			return nil
+
+		case path, ok := <-events:
+			if !ok {
+				flush()
+				return nil
+			}
+			if len(pending) == 0 {
+				deadline = time.After(maxWait)
+			}
+			pending[path] = true
+			d.setPending(len(pending))
+			// Every change restarts the quiet period, up to the deadline
+			commitTimer = time.After(d.opts.Debounce)
+
+		case <-commitTimer:
+			flush()
+
+		case <-deadline:
+			flush()
+
+		case <-pushTimer:
+			if err := d.opts.Push(); err != nil {
+				// Double the delay on each failure so an offline laptop doesn't spin
+				backoff *= 2
+				if backoff > maxPushBackoff {
+					backoff = maxPushBackoff
+				}
+				d.record(func(s *State) {
+					s.LastError = fmt.Sprintf("push: %v", err)
+					s.PushBackoff = backoff.String()
+				})
+				d.opts.Log(fmt.Sprintf("Push failed, retrying in %s: %v", backoff, err))
+			} else {
+				backoff = d.opts.PushInterval
+				d.record(func(s *State) {
+					s.LastPush = time.Now().UTC()
+					s.PushBackoff = ""
+				})
+			}
+			pushTimer = d.schedulePush(backoff)
+		}
+	}
+}
+
+func (d *Daemon) commit() {
+	paths, err := d.opts.Commit()
+	if err != nil {
+		d.record(func(s *State) { s.LastError = fmt.Sprintf("commit: %v", err) })
+		d.opts.Log(fmt.Sprintf("Commit failed: %v", err))
+		return
+	}
+	if len(paths) == 0 {
+		return
+	}
+	d.record(func(s *State) { s.LastCommit = time.Now().UTC() })
+	d.opts.Log(fmt.Sprintf("Committed %d file(s)", len(paths)))
+}
+
+func (d *Daemon) schedulePush(after time.Duration) <-chan time.Time {
+	d.record(func(s *State) { s.NextPush = time.Now().UTC().Add(after) })
+	return time.After(after)
+}
+
+func (d *Daemon) setPending(n int) {
+	d.record(func(s *State) { s.Pending = n })
+}
+
+func (d *Daemon) record(update func(s *State)) {
+	d.mu.Lock()
+	update(&d.state)
+	d.mu.Unlock()
+}
+
+// CommitMessage builds an auto-commit message from `git diff --name-status` lines
+func CommitMessage(changes []string) string {
+	sorted := append([]string(nil), changes...)
+	sort.Strings(sorted)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Auto-commit: %d file(s) changed\n\n", len(sorted))
+	for _, change := range sorted {
+		fmt.Fprintf(&b, "%s\n", change)
+	}
+	return b.String()
+}
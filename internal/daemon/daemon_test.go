@@ -0,0 +1,163 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRun_DebouncesBurstIntoOneCommit(t *testing.T) {
+	var mu sync.Mutex
+	commits := 0
+
+	d := New(Options{
+		Debounce: 50 * time.Millisecond,
+		Commit: func() ([]string, error) {
+			mu.Lock()
+			commits++
+			mu.Unlock()
+			return []string{"M\ta.md"}, nil
+		},
+	}, 1)
+
+	events := make(chan string)
+	done := make(chan error)
+	go func() { done <- d.Run(context.Background(), events) }()
+
+	for i := 0; i < 5; i++ {
+		events <- "a.md"
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(150 * time.Millisecond)
+	close(events)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if commits != 1 {
+		t.Errorf("commits = %d, want 1", commits)
+	}
+	if d.State().LastCommit.IsZero() {
+		t.Error("LastCommit should be recorded")
+	}
+}
+
+func TestRun_PushBackoff(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+
+	d := New(Options{
+		Debounce:     time.Second,
+		PushInterval: 20 * time.Millisecond,
+		Commit:       func() ([]string, error) { return nil, nil },
+		Push: func() error {
+			mu.Lock()
+			attempts++
+			mu.Unlock()
+			return errors.New("unreachable")
+		},
+	}, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 130*time.Millisecond)
+	defer cancel()
+	d.Run(ctx, make(chan string))
+
+	// Delays of 20ms, 40ms, 80ms fit two attempts in 130ms; without backoff there would be six
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts < 1 || attempts > 3 {
+		t.Errorf("attempts = %d, want backoff to limit retries", attempts)
+	}
+	state := d.State()
+	if !strings.Contains(state.LastError, "unreachable") {
+		t.Errorf("LastError = %q", state.LastError)
+	}
+	if state.PushBackoff == "" {
+		t.Error("PushBackoff should be reported")
+	}
+}
+
+func TestControlSocket(t *testing.T) {
+	paths := NewPaths(t.TempDir())
+	if err := paths.Acquire(); err != nil {
+		t.Fatal(err)
+	}
+	defer paths.Release()
+
+	d := New(Options{}, 42)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() { served <- d.Serve(ctx, paths.Socket, cancel) }()
+
+	var state *State
+	var err error
+	for i := 0; i < 50; i++ {
+		if state, err = Query(paths.Socket, "status"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.PID != 42 {
+		t.Errorf("PID = %d, want 42", state.PID)
+	}
+
+	if _, err := Query(paths.Socket, "stop"); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommitMessage(t *testing.T) {
+	msg := CommitMessage([]string{"M\tproj/main/b.md", "A\tproj/main/a.md"})
+	want := "Auto-commit: 2 file(s) changed\n\nA\tproj/main/a.md\nM\tproj/main/b.md\n"
+	if msg != want {
+		t.Errorf("msg = %q, want %q", msg, want)
+	}
+}
+
+func TestRun_MaxWaitCommitsSteadyStream(t *testing.T) {
+	var mu sync.Mutex
+	commits := 0
+
+	d := New(Options{
+		Debounce: 50 * time.Millisecond,
+		MaxWait:  100 * time.Millisecond,
+		Commit: func() ([]string, error) {
+			mu.Lock()
+			commits++
+			mu.Unlock()
+			return []string{"M\ta.md"}, nil
+		},
+	}, 1)
+
+	events := make(chan string)
+	done := make(chan error)
+	go func() { done <- d.Run(context.Background(), events) }()
+
+	// Changes never pause for a full debounce period
+	for i := 0; i < 15; i++ {
+		events <- "a.md"
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	mu.Lock()
+	during := commits
+	mu.Unlock()
+	close(events)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if during == 0 {
+		t.Error("a steady stream of changes should still be committed by MaxWait")
+	}
+}
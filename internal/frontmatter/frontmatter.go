@@ -0,0 +1,196 @@
+// Package frontmatter reads and edits the YAML frontmatter of Markdown files.
+package frontmatter
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fields are the frontmatter keys aidb understands; others are ignored
+type Fields struct {
+	Title       string `yaml:"title" json:"title,omitempty"`
+	Tags        Tags   `yaml:"tags" json:"tags,omitempty"`
+	Summary     string `yaml:"summary" json:"summary,omitempty"`
+	Expires     string `yaml:"expires" json:"expires,omitempty"`
+	ReviewAfter string `yaml:"review-after" json:"reviewAfter,omitempty"`
+	Confidence  string `yaml:"confidence" json:"confidence,omitempty"`
+	Source      string `yaml:"source" json:"source,omitempty"`
+}
+
+// Tags accepts a YAML list or a comma-separated string
+type Tags []string
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (t *Tags) UnmarshalYAML(node *yaml.Node) error {
+	var list []string
+	if node.Kind == yaml.ScalarNode {
+		list = strings.Split(node.Value, ",")
+	} else if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*t = Normalize(list)
+	return nil
+}
+
+// Normalize trims, lowercases, dedupes and sorts tags
+func Normalize(tags []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// IsMarkdown reports whether path is a file whose frontmatter aidb reads
+func IsMarkdown(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown", ".mdx":
+		return true
+	}
+	return false
+}
+
+// Parse returns the frontmatter of data, or nil if it has none or it is not
+// valid YAML
+func Parse(data []byte) *Fields {
+	block, _, ok := split(data)
+	if !ok {
+		return nil
+	}
+	f := &Fields{}
+	if err := yaml.Unmarshal(block, f); err != nil {
+		return nil
+	}
+	if f.empty() {
+		return nil
+	}
+	return f
+}
+
+func (f *Fields) empty() bool {
+	return f.Title == "" && len(f.Tags) == 0 && f.Summary == "" && f.Expires == "" &&
+		f.ReviewAfter == "" && f.Confidence == "" && f.Source == ""
+}
+
+// Due returns the earlier of expires and review-after, or zero if neither is
+// a valid date (YYYY-MM-DD or RFC 3339)
+func (f *Fields) Due() time.Time {
+	if f == nil {
+		return time.Time{}
+	}
+	var due time.Time
+	for _, s := range []string{f.Expires, f.ReviewAfter} {
+		t, ok := parseDate(s)
+		if ok && (due.IsZero() || t.Before(due)) {
+			due = t
+		}
+	}
+	return due
+}
+
+func parseDate(s string) (time.Time, bool) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// Set replaces key in the frontmatter of data with value, rendered as YAML,
+// adding frontmatter if there is none. Other lines are kept as written. A
+// nil value removes the key, and the frontmatter if it ends up empty.
+func Set(data []byte, key string, value interface{}) ([]byte, error) {
+	var line []byte
+	if value != nil {
+		node := &yaml.Node{}
+		if err := node.Encode(map[string]interface{}{key: value}); err != nil {
+			return nil, err
+		}
+		if seq := node.Content[1]; seq.Kind == yaml.SequenceNode {
+			seq.Style = yaml.FlowStyle
+		}
+		out, err := yaml.Marshal(node)
+		if err != nil {
+			return nil, err
+		}
+		line = out
+	}
+
+	block, body, ok := split(data)
+	if !ok {
+		if line == nil {
+			return data, nil
+		}
+		return concat([]byte("---\n"), line, []byte("---\n"), data), nil
+	}
+
+	// Drop the key's line and its indented or list continuation lines
+	var kept [][]byte
+	inserted := false
+	lines := bytes.SplitAfter(block, []byte("\n"))
+	for i := 0; i < len(lines); i++ {
+		if !bytes.HasPrefix(lines[i], []byte(key+":")) {
+			kept = append(kept, lines[i])
+			continue
+		}
+		for i+1 < len(lines) && continuation(lines[i+1]) {
+			i++
+		}
+		if line != nil && !inserted {
+			kept = append(kept, line)
+			inserted = true
+		}
+	}
+	if line != nil && !inserted {
+		kept = append(kept, line)
+	}
+	if len(bytes.TrimSpace(bytes.Join(kept, nil))) == 0 {
+		return body, nil
+	}
+	kept = append([][]byte{[]byte("---\n")}, kept...)
+	kept = append(kept, []byte("---\n"), body)
+	return bytes.Join(kept, nil), nil
+}
+
+// split separates a leading --- delimited block from the rest of data
+func split(data []byte) (block, body []byte, ok bool) {
+	rest, found := bytes.CutPrefix(data, []byte("---\n"))
+	if !found {
+		if rest, found = bytes.CutPrefix(data, []byte("---\r\n")); !found {
+			return nil, data, false
+		}
+	}
+	for offset := 0; offset < len(rest); {
+		end := bytes.IndexByte(rest[offset:], '\n')
+		next := len(rest)
+		if end >= 0 {
+			next = offset + end + 1
+		}
+		if l := strings.TrimRight(string(rest[offset:next]), "\r\n"); l == "---" || l == "..." {
+			return rest[:offset], rest[next:], true
+		}
+		offset = next
+	}
+	return nil, data, false
+}
+
+func continuation(line []byte) bool {
+	return len(line) > 0 && (line[0] == ' ' || line[0] == '\t' || bytes.HasPrefix(line, []byte("- ")))
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
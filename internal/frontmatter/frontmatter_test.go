@@ -0,0 +1,111 @@
+package frontmatter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	data := []byte("---\ntitle: Auth flow\ntags: [Auth, db, auth]\nsummary: How login works\nexpires: 2026-01-31\nconfidence: high\nsource: https://example.com\nother: ignored\n---\n# Body\n")
+	f := Parse(data)
+	if f == nil {
+		t.Fatal("Parse returned nil")
+	}
+	want := &Fields{
+		Title:      "Auth flow",
+		Tags:       Tags{"auth", "db"},
+		Summary:    "How login works",
+		Expires:    "2026-01-31",
+		Confidence: "high",
+		Source:     "https://example.com",
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("Parse = %+v, want %+v", f, want)
+	}
+}
+
+func TestParse_CommaTags(t *testing.T) {
+	f := Parse([]byte("---\ntags: auth, db\n---\n"))
+	if f == nil || !reflect.DeepEqual([]string(f.Tags), []string{"auth", "db"}) {
+		t.Errorf("Parse = %+v, want tags [auth db]", f)
+	}
+}
+
+func TestFields_Due(t *testing.T) {
+	f := Parse([]byte("---\nexpires: 2026-03-01\nreview-after: 2026-02-01\n---\n"))
+	if want := time.Date(2026, 2, 1, 0, 0, 0, 0, time.Local); !f.Due().Equal(want) {
+		t.Errorf("Due = %v, want the earlier %v", f.Due(), want)
+	}
+	if f := Parse([]byte("---\nexpires: soon\ntitle: x\n---\n")); !f.Due().IsZero() {
+		t.Errorf("invalid date should give no due date, got %v", f.Due())
+	}
+	var none *Fields
+	if !none.Due().IsZero() {
+		t.Error("nil fields should have no due date")
+	}
+}
+
+func TestParse_None(t *testing.T) {
+	for _, data := range []string{
+		"# No frontmatter\n",
+		"---\nunterminated: true\n",
+		"---\n: : invalid\n---\n",
+		"---\nunknown: only\n---\n",
+	} {
+		if f := Parse([]byte(data)); f != nil {
+			t.Errorf("Parse(%q) = %+v, want nil", data, f)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		value interface{}
+		want  string
+	}{
+		{
+			name:  "replace block list, keep other lines",
+			data:  "---\ntitle: A  # keep\ntags:\n  - x\n  - y\nsummary: s\n---\nbody\n",
+			value: []string{"a", "b"},
+			want:  "---\ntitle: A  # keep\ntags: [a, b]\nsummary: s\n---\nbody\n",
+		},
+		{
+			name:  "append key",
+			data:  "---\ntitle: A\n---\nbody\n",
+			value: []string{"a"},
+			want:  "---\ntitle: A\ntags: [a]\n---\nbody\n",
+		},
+		{
+			name:  "add frontmatter",
+			data:  "body\n",
+			value: []string{"a"},
+			want:  "---\ntags: [a]\n---\nbody\n",
+		},
+		{
+			name:  "remove key",
+			data:  "---\ntitle: A\ntags: [a]\n---\nbody\n",
+			value: nil,
+			want:  "---\ntitle: A\n---\nbody\n",
+		},
+		{
+			name:  "remove last key drops frontmatter",
+			data:  "---\ntags: [a]\n---\nbody\n",
+			value: nil,
+			want:  "body\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Set([]byte(tt.data), "tags", tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Set = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
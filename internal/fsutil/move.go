@@ -0,0 +1,131 @@
+// Package fsutil moves files between the database and checkouts, which may
+// live on different filesystems.
+package fsutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// rename is os.Rename, replaceable in tests to simulate EXDEV
+var rename = os.Rename
+
+// Move moves the regular file src to dst. When they are on different
+// filesystems (EXDEV) it copies src to a temporary file next to dst,
+// preserving mode and mtime, fsyncs it, verifies the copy by hash, renames it
+// into place and only then removes src. On failure src is left untouched and
+// nothing is left at dst.
+func Move(src, dst string) error {
+	err := rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	return copyMove(src, dst)
+}
+
+func copyMove(src, dst string) (err error) {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s: not a regular file", src)
+	}
+
+	tmp, err := copyToTemp(src, filepath.Dir(dst), info)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+
+	if err := os.Rename(tmp, dst); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(dst))
+
+	if err := os.Remove(src); err != nil {
+		// Both copies exist; keep the original so the caller's state holds
+		os.Remove(dst)
+		return fmt.Errorf("copied but could not remove %s: %w", src, err)
+	}
+	syncDir(filepath.Dir(src))
+	return nil
+}
+
+// copyToTemp writes a verified copy of src into dir and returns its path
+func copyToTemp(src, dir string, info os.FileInfo) (path string, err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(dir, ".aidb-move-*")
+	if err != nil {
+		return "", err
+	}
+	path = out.Name()
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(path)
+		}
+	}()
+
+	srcHash := sha256.New()
+	if _, err := io.Copy(out, io.TeeReader(in, srcHash)); err != nil {
+		return "", err
+	}
+	if err := out.Chmod(info.Mode().Perm()); err != nil {
+		return "", err
+	}
+	if err := out.Sync(); err != nil {
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		return "", err
+	}
+
+	dstHash, err := hashFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Equal(srcHash.Sum(nil), dstHash) {
+		return "", fmt.Errorf("copy of %s does not match the original", src)
+	}
+	return path, nil
+}
+
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// syncDir persists directory entries; not every platform supports it
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,99 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// crossDevice makes every rename fail like a move across filesystems
+func crossDevice(t *testing.T) {
+	t.Helper()
+	rename = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	t.Cleanup(func() { rename = os.Rename })
+}
+
+func TestMove_SameFilesystem(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "a.md"), filepath.Join(dir, "b.md")
+	if err := os.WriteFile(src, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Move(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "hello" {
+		t.Errorf("dst = %q", data)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("src should be gone")
+	}
+}
+
+func TestMove_CrossDeviceCopies(t *testing.T) {
+	crossDevice(t)
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	src, dst := filepath.Join(srcDir, "run.sh"), filepath.Join(dstDir, "run.sh")
+	if err := os.WriteFile(src, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Move(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("mode = %v, want 0755", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("mtime = %v, want %v", info.ModTime(), mtime)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "#!/bin/sh\n" {
+		t.Errorf("dst = %q", data)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("src should be removed after a verified copy")
+	}
+	if entries, _ := os.ReadDir(dstDir); len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestMove_CrossDeviceFailureLeavesSource(t *testing.T) {
+	crossDevice(t)
+	src := filepath.Join(t.TempDir(), "a.md")
+	if err := os.WriteFile(src, []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(t.TempDir(), "missing", "a.md")
+
+	if err := Move(src, dst); err == nil {
+		t.Fatal("expected an error when the destination directory is missing")
+	}
+	if data, _ := os.ReadFile(src); string(data) != "keep me" {
+		t.Errorf("src = %q, want it untouched", data)
+	}
+	if _, err := os.Lstat(dst); !os.IsNotExist(err) {
+		t.Error("nothing should be left at dst")
+	}
+}
+
+func TestMove_OtherErrorsAreReturned(t *testing.T) {
+	dir := t.TempDir()
+	if err := Move(filepath.Join(dir, "nope"), filepath.Join(dir, "b")); !os.IsNotExist(err) {
+		t.Errorf("err = %v, want not-exist", err)
+	}
+}
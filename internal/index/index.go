@@ -0,0 +1,191 @@
+package index
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/KakkoiDev/aidb/internal/frontmatter"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+	"github.com/KakkoiDev/aidb/internal/search"
+)
+
+// version is bumped whenever the on-disk format or tokenizer changes;
+// an index with a different version is discarded and rebuilt
+const version = 3
+
+// Entry caches what aidb knows about one file's content
+type Entry struct {
+	Size    int64    `json:"size"`
+	ModTime int64    `json:"mtime"` // UnixNano
+	Hash    string   `json:"hash"`
+	Length  int      `json:"length"` // token count, for BM25 length normalization
+	Terms   []string `json:"terms"`  // distinct terms, to drop postings on update
+
+	Front *frontmatter.Fields `json:"front,omitempty"` // Markdown frontmatter
+}
+
+// Index is a file-stat cache plus token inverted index stored under ~/.aidb/.index/
+type Index struct {
+	Version  int                       `json:"version"`
+	Files    map[string]*Entry         `json:"files"`
+	Postings map[string]map[string]int `json:"postings"` // term -> path -> frequency
+
+	dbDir string
+	path  string
+	dirty bool
+}
+
+// Dir returns the index directory for a database
+func Dir(dbDir string) string {
+	return filepath.Join(dbDir, ".index")
+}
+
+// Open loads the index for dbDir, returning an empty one if missing or stale
+func Open(dbDir string) (*Index, error) {
+	x := &Index{
+		Version:  version,
+		Files:    make(map[string]*Entry),
+		Postings: make(map[string]map[string]int),
+		dbDir:    dbDir,
+		path:     filepath.Join(Dir(dbDir), "index.json"),
+	}
+
+	data, err := os.ReadFile(x.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return x, nil
+		}
+		return nil, err
+	}
+
+	loaded := &Index{}
+	if err := json.Unmarshal(data, loaded); err != nil || loaded.Version != version {
+		// A corrupt or outdated cache is rebuilt, not fatal
+		x.dirty = true
+		return x, nil
+	}
+	if loaded.Files != nil {
+		x.Files = loaded.Files
+	}
+	if loaded.Postings != nil {
+		x.Postings = loaded.Postings
+	}
+	return x, nil
+}
+
+// Save writes the index if it changed, via a temp file so readers never see a partial write
+func (x *Index) Save() error {
+	if !x.dirty {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(x.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(x)
+	if err != nil {
+		return err
+	}
+	tmp := x.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, x.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	x.dirty = false
+	return nil
+}
+
+// Get returns the cached entry for a db-relative path, or nil
+func (x *Index) Get(relPath string) *Entry {
+	return x.Files[relPath]
+}
+
+// Update refreshes relPath, rereading it only when size or mtime changed.
+// A missing file is removed from the index and returns nil; directories are ignored.
+func (x *Index) Update(relPath string) (*Entry, error) {
+	info, err := os.Stat(filepath.Join(x.dbDir, relPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			x.Remove(relPath)
+			return nil, nil
+		}
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, nil
+	}
+	if e := x.Files[relPath]; e != nil && e.Size == info.Size() && e.ModTime == info.ModTime().UnixNano() {
+		return e, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(x.dbDir, relPath))
+	if err != nil {
+		return nil, err
+	}
+
+	x.Remove(relPath)
+	doc := search.NewDoc(relPath, string(data))
+	e := &Entry{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Hash:    metadata.HashBytes(data),
+		Length:  doc.Length,
+	}
+	if frontmatter.IsMarkdown(relPath) {
+		e.Front = frontmatter.Parse(data)
+	}
+	for term, tf := range doc.Terms {
+		e.Terms = append(e.Terms, term)
+		if x.Postings[term] == nil {
+			x.Postings[term] = make(map[string]int)
+		}
+		x.Postings[term][relPath] = tf
+	}
+	sort.Strings(e.Terms)
+	x.Files[relPath] = e
+	x.dirty = true
+	return e, nil
+}
+
+// Remove drops relPath and its postings
+func (x *Index) Remove(relPath string) {
+	e, ok := x.Files[relPath]
+	if !ok {
+		return
+	}
+	for _, term := range e.Terms {
+		delete(x.Postings[term], relPath)
+		if len(x.Postings[term]) == 0 {
+			delete(x.Postings, term)
+		}
+	}
+	delete(x.Files, relPath)
+	x.dirty = true
+}
+
+// Prune removes every indexed path not in keep
+func (x *Index) Prune(keep map[string]bool) {
+	for relPath := range x.Files {
+		if !keep[relPath] {
+			x.Remove(relPath)
+		}
+	}
+}
+
+// Doc returns the BM25 statistics of relPath restricted to the query terms
+func (x *Index) Doc(relPath string, query []string) search.Doc {
+	doc := search.Doc{Path: relPath, Terms: make(map[string]int)}
+	if e := x.Files[relPath]; e != nil {
+		doc.Length = e.Length
+	}
+	for _, q := range query {
+		if tf := x.Postings[q][relPath]; tf > 0 {
+			doc.Terms[q] = tf
+		}
+	}
+	return doc
+}
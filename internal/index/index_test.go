@@ -0,0 +1,79 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUpdate_SkipsUnchangedFiles(t *testing.T) {
+	dbDir := t.TempDir()
+	path := filepath.Join(dbDir, "p", "main", "a.md")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("alpha beta"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	x, _ := Open(dbDir)
+	first, err := x.Update("p/main/a.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.Postings["alpha"]["p/main/a.md"] != 1 {
+		t.Errorf("postings = %v", x.Postings)
+	}
+
+	// Same size and mtime: content is trusted from the cache, not reread
+	stat, _ := os.Stat(path)
+	if err := os.WriteFile(path, []byte("gamma beta"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, stat.ModTime(), stat.ModTime())
+	second, _ := x.Update("p/main/a.md")
+	if second.Hash != first.Hash {
+		t.Error("unchanged stat should reuse cached entry")
+	}
+
+	// A new mtime forces a reread and replaces postings
+	later := stat.ModTime().Add(time.Second)
+	os.Chtimes(path, later, later)
+	third, _ := x.Update("p/main/a.md")
+	if third.Hash == first.Hash {
+		t.Error("changed mtime should rehash")
+	}
+	if _, ok := x.Postings["alpha"]; ok {
+		t.Error("stale postings should be removed")
+	}
+	if x.Postings["gamma"]["p/main/a.md"] != 1 {
+		t.Errorf("postings = %v", x.Postings)
+	}
+}
+
+func TestSaveAndReopen(t *testing.T) {
+	dbDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dbDir, "a.md"), []byte("alpha"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	x, _ := Open(dbDir)
+	x.Update("a.md")
+	if err := x.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	y, err := Open(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if y.Get("a.md") == nil {
+		t.Fatal("entry should persist")
+	}
+
+	y.Prune(map[string]bool{})
+	if y.Get("a.md") != nil || len(y.Postings) != 0 {
+		t.Error("Prune should drop entries and postings")
+	}
+}
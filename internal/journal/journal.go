@@ -0,0 +1,696 @@
+// Package journal records file operations before they happen so a batch can
+// be rolled back on failure, undone later, or recovered after a crash.
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/KakkoiDev/aidb/internal/fsutil"
+	"github.com/KakkoiDev/aidb/internal/metadata"
+)
+
+// Dir is the journal directory inside the database
+const Dir = ".journal"
+
+// keep bounds how many finished operations are kept for undo
+const keep = 20
+
+// Operation states
+const (
+	Pending    = "pending"     // in progress, or interrupted
+	Done       = "done"        // completed; can be undone
+	RolledBack = "rolled-back" // failed or interrupted and reverted
+	Undone     = "undone"      // reverted by aidb undo
+)
+
+// Step is one recorded mutation, written before it is performed
+type Step struct {
+	Op     string          `json:"op"`               // move, symlink, unlink, create or meta
+	From   string          `json:"from,omitempty"`   // move: original location
+	To     string          `json:"to,omitempty"`     // move: new location
+	Path   string          `json:"path,omitempty"`   // symlink/unlink/create: path; meta: db-relative file
+	Target string          `json:"target,omitempty"` // symlink/unlink: link target
+	Hash   string          `json:"hash,omitempty"`   // create: content hash of the new file
+	Before json.RawMessage `json:"before,omitempty"` // meta: metadata entry before, null if none
+	After  json.RawMessage `json:"after,omitempty"`  // meta: metadata entry the operation left
+}
+
+// Entry is one journaled operation
+type Entry struct {
+	ID    string    `json:"id"`
+	Kind  string    `json:"kind"` // add, remove, seen, unseen
+	Args  []string  `json:"args,omitempty"`
+	Time  time.Time `json:"time"`
+	PID   int       `json:"pid"`
+	State string    `json:"state"`
+	Steps []Step    `json:"steps"`
+
+	// Touched lists the db-relative paths a rollback or undo changed, so
+	// callers can restage them
+	Touched []string `json:"touched,omitempty"`
+
+	dbDir string
+}
+
+// Begin starts a journaled operation
+func Begin(dbDir, kind string, args []string) (*Entry, error) {
+	if err := os.MkdirAll(filepath.Join(dbDir, Dir), 0755); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	e := &Entry{
+		ID:    now.Format("20060102T150405.000000000") + "-" + kind,
+		Kind:  kind,
+		Args:  args,
+		Time:  now,
+		PID:   os.Getpid(),
+		State: Pending,
+		Steps: []Step{},
+		dbDir: dbDir,
+	}
+	return e, e.save()
+}
+
+// Move records that from is about to be moved to to
+func (e *Entry) Move(from, to string) error {
+	return e.record(Step{Op: "move", From: from, To: to})
+}
+
+// Symlink records that a symlink path -> target is about to be created
+func (e *Entry) Symlink(path, target string) error {
+	return e.record(Step{Op: "symlink", Path: path, Target: target})
+}
+
+// Unlink records that the symlink path -> target is about to be removed
+func (e *Entry) Unlink(path, target string) error {
+	return e.record(Step{Op: "unlink", Path: path, Target: target})
+}
+
+// Create records that a file with content hash is about to be written at
+// path, where nothing exists (move the old file away first)
+func (e *Entry) Create(path, hash string) error {
+	return e.record(Step{Op: "create", Path: path, Hash: hash})
+}
+
+// Meta records the metadata entry of relPath before it is changed. Only the
+// first record per path is kept, since that is the state to restore.
+func (e *Entry) Meta(relPath string, before *metadata.FileInfo) error {
+	for _, s := range e.Steps {
+		if s.Op == "meta" && s.Path == relPath {
+			return nil
+		}
+	}
+	data, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	return e.record(Step{Op: "meta", Path: relPath, Before: data})
+}
+
+// Trash returns where a file deleted by the operation is kept, so it can
+// still be rolled back or undone. Callers journal a Move there.
+func (e *Entry) Trash(path string) string {
+	return filepath.Join(e.dbDir, Dir, e.ID, strconv.Itoa(len(e.Steps)), filepath.Base(path))
+}
+
+// Len returns the number of recorded steps
+func (e *Entry) Len() int {
+	return len(e.Steps)
+}
+
+// Commit marks the operation complete and records the metadata entries it
+// left, so undo only reverts what the operation changed. Operations without
+// steps are discarded, as there is nothing to undo.
+func (e *Entry) Commit() error {
+	if len(e.Steps) == 0 {
+		os.Remove(e.stepsFile())
+		return os.Remove(e.file())
+	}
+	if err := e.recordAfter(); err != nil {
+		return err
+	}
+	e.State = Done
+	if err := e.save(); err != nil {
+		return err
+	}
+	prune(e.dbDir)
+	return nil
+}
+
+// Rollback reverts whatever part of the operation happened, skipping steps
+// that never took effect
+func (e *Entry) Rollback() error {
+	touched, err := e.revert(false)
+	e.State = RolledBack
+	e.Touched = touched
+	if serr := e.save(); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// Undo reverts a completed operation. It refuses if a moved file is no
+// longer where the operation left it, or metadata the operation changed was
+// changed again since, so nothing is overwritten.
+func (e *Entry) Undo() error {
+	if e.State != Done {
+		return fmt.Errorf("operation %s is %s", e.ID, e.State)
+	}
+	if err := e.check(); err != nil {
+		return err
+	}
+	touched, err := e.revert(true)
+	e.Touched = touched
+	if err != nil {
+		return err
+	}
+	e.State = Undone
+	return e.save()
+}
+
+// Last returns the most recent completed operation, or nil
+func Last(dbDir string) (*Entry, error) {
+	entries, err := List(dbDir)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].State == Done {
+			return entries[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// List returns the journaled operations, oldest first
+func List(dbDir string) ([]*Entry, error) {
+	dir := filepath.Join(dbDir, Dir)
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		e, err := load(dbDir, filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+// Recover rolls back operations left pending by a process that is gone
+func Recover(dbDir string) ([]*Entry, error) {
+	entries, err := List(dbDir)
+	if err != nil {
+		return nil, err
+	}
+	var recovered []*Entry
+	for _, e := range entries {
+		if e.State != Pending || alive(e.PID) {
+			continue
+		}
+		if err := e.Rollback(); err != nil {
+			return recovered, fmt.Errorf("recovering %s: %w", e.ID, err)
+		}
+		recovered = append(recovered, e)
+	}
+	return recovered, nil
+}
+
+// record appends s to the entry's step log and syncs it, so each step costs
+// one line and one fsync however long the operation gets
+func (e *Entry) record(s Step) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(e.stepsFile(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	e.Steps = append(e.Steps, s)
+	return nil
+}
+
+func (e *Entry) file() string {
+	return filepath.Join(e.dbDir, Dir, e.ID+".json")
+}
+
+// stepsFile is the log steps are appended to while the operation is pending
+func (e *Entry) stepsFile() string {
+	return filepath.Join(e.dbDir, Dir, e.ID+".steps")
+}
+
+// load reads the entry in file, with any steps still in its log
+func load(dbDir, file string) (*Entry, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	e := &Entry{dbDir: dbDir}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(file), err)
+	}
+	if len(e.Steps) > 0 {
+		return e, nil // saved with its steps; a leftover log is stale
+	}
+	log, err := os.ReadFile(e.stepsFile())
+	if os.IsNotExist(err) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(log), "\n") {
+		var s Step
+		if err := json.Unmarshal([]byte(line), &s); err != nil {
+			break // a torn last line: that step never took effect
+		}
+		e.Steps = append(e.Steps, s)
+	}
+	return e, nil
+}
+
+// save writes the entry, steps included, atomically and durably, then drops
+// the step log it replaces
+func (e *Entry) save() error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := e.file() + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, e.file()); err != nil {
+		return err
+	}
+	if err := os.Remove(e.stepsFile()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// check verifies every moved file is still where the operation put it, every
+// created file is unchanged, and so is the metadata the operation changed
+func (e *Entry) check() error {
+	if err := e.checkMeta(); err != nil {
+		return err
+	}
+	// Paths the operation itself filled again after moving their file away
+	created := map[string]bool{}
+	for _, s := range e.Steps {
+		if s.Op == "move" {
+			created[s.To] = true
+		}
+		if s.Op != "create" {
+			continue
+		}
+		created[s.Path] = true
+		if hash, err := metadata.HashFile(s.Path); err == nil && hash != s.Hash {
+			return fmt.Errorf("cannot undo: %s was modified since", s.Path)
+		}
+	}
+	for _, s := range e.Steps {
+		if s.Op != "move" {
+			continue
+		}
+		if _, err := os.Lstat(s.To); err != nil {
+			return fmt.Errorf("cannot undo: %s is gone", s.To)
+		}
+		if _, err := os.Lstat(s.From); err == nil && !created[s.From] {
+			if target, _ := os.Readlink(s.From); target != s.To {
+				return fmt.Errorf("cannot undo: %s already exists", s.From)
+			}
+		}
+	}
+	return nil
+}
+
+// revert undoes steps in reverse order, then restores metadata entries
+func (e *Entry) revert(strict bool) ([]string, error) {
+	touched := map[string]bool{}
+	touch := func(path string) {
+		if rel, err := filepath.Rel(e.dbDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			touched[rel] = true
+		}
+	}
+
+	var errs []error
+	for i := len(e.Steps) - 1; i >= 0; i-- {
+		s := e.Steps[i]
+		switch s.Op {
+		case "move":
+			if _, err := os.Lstat(s.To); err != nil {
+				continue // never happened
+			}
+			// The link created over the original location goes first
+			if target, err := os.Readlink(s.From); err == nil && target == s.To {
+				os.Remove(s.From)
+			}
+			if _, err := os.Lstat(s.From); err == nil {
+				if strict {
+					errs = append(errs, fmt.Errorf("%s already exists", s.From))
+				}
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(s.From), 0755); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if err := fsutil.Move(s.To, s.From); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			touch(s.From)
+			touch(s.To)
+		case "create":
+			if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+			touch(s.Path)
+		case "symlink":
+			if target, err := os.Readlink(s.Path); err == nil && target == s.Target {
+				if err := os.Remove(s.Path); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		case "unlink":
+			if _, err := os.Lstat(s.Path); err == nil {
+				if target, _ := os.Readlink(s.Path); target != s.Target && strict {
+					errs = append(errs, fmt.Errorf("%s already exists", s.Path))
+				}
+				continue
+			}
+			if err := os.Symlink(s.Target, s.Path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if err := e.restoreMeta(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, s := range e.Steps {
+		if s.Op == "meta" {
+			touched[s.Path] = true
+		}
+	}
+
+	paths := make([]string, 0, len(touched))
+	for p := range touched {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, errors.Join(errs...)
+}
+
+// recordAfter stores the current metadata entry of every journaled path
+func (e *Entry) recordAfter() error {
+	var meta *metadata.Metadata
+	for i, s := range e.Steps {
+		if s.Op != "meta" {
+			continue
+		}
+		if meta == nil {
+			var err error
+			if meta, err = metadata.New(e.dbDir); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(meta.GetInfo(s.Path))
+		if err != nil {
+			return err
+		}
+		e.Steps[i].After = data
+	}
+	return nil
+}
+
+// metaChange is the part of one metadata entry an operation changed
+type metaChange struct {
+	path          string
+	before, after map[string]string
+	known         bool // after was recorded at commit
+}
+
+func (e *Entry) metaChanges() ([]metaChange, error) {
+	var changes []metaChange
+	for _, s := range e.Steps {
+		if s.Op != "meta" {
+			continue
+		}
+		var before, after *metadata.FileInfo
+		if err := json.Unmarshal(s.Before, &before); err != nil {
+			return nil, err
+		}
+		c := metaChange{path: s.Path, before: fields(before), known: s.After != nil}
+		if c.known {
+			if err := json.Unmarshal(s.After, &after); err != nil {
+				return nil, err
+			}
+			c.after = fields(after)
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// checkMeta verifies the fields the operation changed still hold what it left
+func (e *Entry) checkMeta() error {
+	changes, err := e.metaChanges()
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	meta, err := metadata.New(e.dbDir)
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if !c.known {
+			continue
+		}
+		current := fields(meta.GetInfo(c.path))
+		for _, key := range changedKeys(c.before, c.after) {
+			if current[key] != c.after[key] {
+				return fmt.Errorf("cannot undo: %s changed since (%s)", c.path, describe(key))
+			}
+		}
+	}
+	return nil
+}
+
+// restoreMeta puts back the fields the operation changed, leaving fields it
+// did not touch, and fields changed again since, as they are
+func (e *Entry) restoreMeta() error {
+	changes, err := e.metaChanges()
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+
+	meta, err := metadata.New(e.dbDir)
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		current := fields(meta.GetInfo(c.path))
+		after := c.after
+		if !c.known {
+			// Interrupted before commit: whatever is there came from the operation
+			after = current
+		}
+		info := meta.GetInfo(c.path)
+		if info == nil {
+			info = &metadata.FileInfo{}
+		}
+		for _, key := range changedKeys(c.before, after) {
+			if current[key] != after[key] {
+				continue
+			}
+			if err := setField(info, key, c.before[key]); err != nil {
+				return err
+			}
+		}
+		if len(fields(info)) == 0 {
+			meta.Remove(c.path)
+		} else {
+			meta.Files[c.path] = info
+		}
+	}
+	return meta.Save()
+}
+
+// fields splits a metadata entry into the parts operations change on their
+// own: each agent's seen state, the links, the tags and the review date.
+// Unset parts are left out.
+func fields(info *metadata.FileInfo) map[string]string {
+	m := map[string]string{}
+	if info == nil {
+		return m
+	}
+	for agent, c := range info.Consumers {
+		if data, err := json.Marshal(c); err == nil {
+			m["consumer:"+agent] = string(data)
+		}
+	}
+	if len(info.Links) > 0 {
+		if data, err := json.Marshal(info.Links); err == nil {
+			m["links"] = string(data)
+		}
+	}
+	if len(info.Tags) > 0 {
+		if data, err := json.Marshal(info.Tags); err == nil {
+			m["tags"] = string(data)
+		}
+	}
+	if info.ReviewAfter != "" {
+		m["reviewAfter"] = info.ReviewAfter
+	}
+	return m
+}
+
+// setField sets one part of info as returned by fields; empty unsets it
+func setField(info *metadata.FileInfo, key, value string) error {
+	switch {
+	case strings.HasPrefix(key, "consumer:"):
+		agent := strings.TrimPrefix(key, "consumer:")
+		if value == "" {
+			delete(info.Consumers, agent)
+			if len(info.Consumers) == 0 {
+				info.Consumers = nil
+			}
+			return nil
+		}
+		var c metadata.ConsumerState
+		if err := json.Unmarshal([]byte(value), &c); err != nil {
+			return err
+		}
+		if info.Consumers == nil {
+			info.Consumers = map[string]*metadata.ConsumerState{}
+		}
+		info.Consumers[agent] = &c
+	case key == "links":
+		info.Links = nil
+		if value != "" {
+			return json.Unmarshal([]byte(value), &info.Links)
+		}
+	case key == "tags":
+		info.Tags = nil
+		if value != "" {
+			return json.Unmarshal([]byte(value), &info.Tags)
+		}
+	case key == "reviewAfter":
+		info.ReviewAfter = value
+	}
+	return nil
+}
+
+// changedKeys returns the fields that differ between a and b, sorted
+func changedKeys(a, b map[string]string) []string {
+	var keys []string
+	for k, v := range a {
+		if b[k] != v {
+			keys = append(keys, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// describe names a field for error messages
+func describe(key string) string {
+	if agent, ok := strings.CutPrefix(key, "consumer:"); ok {
+		return "seen state of agent " + agent
+	}
+	if key == "reviewAfter" {
+		return "review date"
+	}
+	return key
+}
+
+// prune deletes the oldest finished operations beyond keep
+func prune(dbDir string) {
+	entries, err := List(dbDir)
+	if err != nil {
+		return
+	}
+	var finished []*Entry
+	for _, e := range entries {
+		if e.State != Pending {
+			finished = append(finished, e)
+		}
+	}
+	for len(finished) > keep {
+		os.Remove(finished[0].file())
+		os.Remove(finished[0].stepsFile())
+		os.RemoveAll(filepath.Join(dbDir, Dir, finished[0].ID))
+		finished = finished[1:]
+	}
+}
+
+// alive reports whether pid is a running process. EPERM means it exists
+// but belongs to another user.
+func alive(pid int) bool {
+	if pid == os.Getpid() {
+		return true
+	}
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,329 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KakkoiDev/aidb/internal/metadata"
+)
+
+// addFile journals and performs what aidb add does for one file
+func addFile(t *testing.T, e *Entry, src, dst string) {
+	t.Helper()
+	if err := e.Move(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Symlink(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dst, src); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setup(t *testing.T) (dbDir, work string) {
+	t.Helper()
+	dbDir = t.TempDir()
+	work = t.TempDir()
+	if err := os.WriteFile(filepath.Join(work, "a.md"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(work, "b.md"), []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dbDir, work
+}
+
+func assertRestored(t *testing.T, work string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(work, name)
+		info, err := os.Lstat(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !info.Mode().IsRegular() {
+			t.Errorf("%s should be a regular file again, mode %v", name, info.Mode())
+		}
+	}
+}
+
+func TestRollback(t *testing.T) {
+	dbDir, work := setup(t)
+
+	e, err := Begin(dbDir, "add", []string{"*.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addFile(t, e, filepath.Join(work, "a.md"), filepath.Join(dbDir, "a.md"))
+	// b.md fails after being journaled but before being moved
+	if err := e.Move(filepath.Join(work, "b.md"), filepath.Join(dbDir, "b.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	assertRestored(t, work, "a.md", "b.md")
+	if _, err := os.Lstat(filepath.Join(dbDir, "a.md")); !os.IsNotExist(err) {
+		t.Error("a.md should be gone from the database")
+	}
+	if e.State != RolledBack {
+		t.Errorf("State = %q, want %q", e.State, RolledBack)
+	}
+	if len(e.Touched) != 1 || e.Touched[0] != "a.md" {
+		t.Errorf("Touched = %v, want [a.md]", e.Touched)
+	}
+
+	// Rolled back operations cannot be undone
+	if last, _ := Last(dbDir); last != nil {
+		t.Errorf("Last = %s, want nil", last.ID)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	dbDir, work := setup(t)
+
+	meta, _ := metadata.New(dbDir)
+	meta.MarkSeen("b.md", "default", "sha256:b")
+	if err := meta.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := Begin(dbDir, "add", []string{"a.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addFile(t, e, filepath.Join(work, "a.md"), filepath.Join(dbDir, "a.md"))
+	if err := e.Meta("a.md", meta.GetInfo("a.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Meta("b.md", meta.GetInfo("b.md")); err != nil {
+		t.Fatal(err)
+	}
+	meta.AddLink("a.md", "host", filepath.Join(work, "a.md"))
+	meta.MarkUnseen("b.md", "default")
+	meta.Save()
+	if err := e.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	last, err := Last(dbDir)
+	if err != nil || last == nil {
+		t.Fatalf("Last = %v, %v", last, err)
+	}
+	if err := last.Undo(); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	assertRestored(t, work, "a.md")
+
+	meta, _ = metadata.New(dbDir)
+	if meta.GetInfo("a.md") != nil {
+		t.Error("a.md metadata should be removed")
+	}
+	if !meta.IsSeen("b.md", "default", "sha256:b", time.Time{}) {
+		t.Error("b.md seen state should be restored")
+	}
+
+	if err := last.Undo(); err == nil {
+		t.Error("second Undo should fail")
+	}
+}
+
+func TestUndo_RefusesOverwrite(t *testing.T) {
+	dbDir, work := setup(t)
+	src := filepath.Join(work, "a.md")
+
+	e, _ := Begin(dbDir, "add", []string{"a.md"})
+	addFile(t, e, src, filepath.Join(dbDir, "a.md"))
+	e.Commit()
+
+	// The user replaced the symlink with a new file
+	os.Remove(src)
+	os.WriteFile(src, []byte("new"), 0644)
+
+	if err := e.Undo(); err == nil {
+		t.Fatal("Undo should refuse to overwrite a.md")
+	}
+	if data, _ := os.ReadFile(src); string(data) != "new" {
+		t.Errorf("a.md = %q, want it untouched", data)
+	}
+	if _, err := os.Stat(filepath.Join(dbDir, "a.md")); err != nil {
+		t.Error("database copy should be untouched")
+	}
+}
+
+func TestRecover(t *testing.T) {
+	dbDir, work := setup(t)
+
+	e, _ := Begin(dbDir, "add", []string{"a.md"})
+	addFile(t, e, filepath.Join(work, "a.md"), filepath.Join(dbDir, "a.md"))
+
+	// Our own pending operations are still running
+	if recovered, err := Recover(dbDir); err != nil || len(recovered) != 0 {
+		t.Fatalf("Recover = %v, %v; want nothing", recovered, err)
+	}
+
+	// Pretend the process that started it is gone
+	e.PID = 0
+	e.save()
+
+	recovered, err := Recover(dbDir)
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if len(recovered) != 1 || recovered[0].ID != e.ID {
+		t.Fatalf("recovered = %v, want %s", recovered, e.ID)
+	}
+	assertRestored(t, work, "a.md")
+}
+
+func TestCommit_Prunes(t *testing.T) {
+	dbDir, work := setup(t)
+
+	empty, _ := Begin(dbDir, "seen", nil)
+	if err := empty.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := List(dbDir); len(entries) != 0 {
+		t.Errorf("operation without steps should be discarded, got %d", len(entries))
+	}
+
+	for i := 0; i < keep+5; i++ {
+		e, _ := Begin(dbDir, "seen", nil)
+		e.Meta(work, nil)
+		if err := e.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entries, _ := List(dbDir); len(entries) != keep {
+		t.Errorf("kept %d operations, want %d", len(entries), keep)
+	}
+}
+
+func TestUndo_Create(t *testing.T) {
+	dbDir, _ := setup(t)
+	path := filepath.Join(dbDir, "a.md")
+	os.WriteFile(path, []byte("old"), 0644)
+
+	e, _ := Begin(dbDir, "restore", []string{"a.md"})
+	trash := e.Trash(path)
+	os.MkdirAll(filepath.Dir(trash), 0755)
+	e.Move(path, trash)
+	os.Rename(path, trash)
+	e.Create(path, metadata.HashBytes([]byte("new")))
+	os.WriteFile(path, []byte("new"), 0644)
+	e.Commit()
+
+	// Edited since: refuse
+	os.WriteFile(path, []byte("edited"), 0644)
+	if err := e.Undo(); err == nil {
+		t.Fatal("Undo should refuse to drop edits")
+	}
+
+	os.WriteFile(path, []byte("new"), 0644)
+	if err := e.Undo(); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old" {
+		t.Errorf("a.md = %q, want %q", data, "old")
+	}
+}
+
+func TestUndo_MetaOnlyChangedFields(t *testing.T) {
+	dbDir, _ := setup(t)
+
+	meta, _ := metadata.New(dbDir)
+	e, _ := Begin(dbDir, "seen", []string{"a.sql"})
+	e.Meta("a.sql", meta.GetInfo("a.sql"))
+	meta.MarkSeen("a.sql", "default", "sha256:a")
+	meta.Save()
+	if err := e.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unrelated changes made afterwards survive the undo
+	meta, _ = metadata.New(dbDir)
+	meta.SetTags("a.sql", []string{"auth"})
+	meta.MarkSeen("a.sql", "other", "sha256:a")
+	meta.Save()
+
+	if err := e.Undo(); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	meta, _ = metadata.New(dbDir)
+	if meta.Consumer("a.sql", "default") != nil {
+		t.Error("default should be unseen after undo")
+	}
+	if meta.Consumer("a.sql", "other") == nil {
+		t.Error("other agent's seen state should be kept")
+	}
+	if tags := meta.Tags("a.sql"); len(tags) != 1 {
+		t.Errorf("tags = %v, want [auth]", tags)
+	}
+}
+
+func TestUndo_RefusesChangedMeta(t *testing.T) {
+	dbDir, _ := setup(t)
+
+	meta, _ := metadata.New(dbDir)
+	e, _ := Begin(dbDir, "seen", []string{"a.sql"})
+	e.Meta("a.sql", meta.GetInfo("a.sql"))
+	meta.MarkSeen("a.sql", "default", "sha256:a")
+	meta.Save()
+	e.Commit()
+
+	// Seen again since, by an operation that is not journaled
+	meta, _ = metadata.New(dbDir)
+	meta.MarkSeen("a.sql", "default", "sha256:b")
+	meta.Save()
+
+	if err := e.Undo(); err == nil {
+		t.Fatal("Undo should refuse to drop the newer seen state")
+	}
+	meta, _ = metadata.New(dbDir)
+	if c := meta.Consumer("a.sql", "default"); c == nil || c.Hash != "sha256:b" {
+		t.Errorf("seen state = %+v, want it untouched", c)
+	}
+}
+
+func TestRecord_AppendsSteps(t *testing.T) {
+	dbDir, work := setup(t)
+
+	e, _ := Begin(dbDir, "add", []string{"*.md"})
+	addFile(t, e, filepath.Join(work, "a.md"), filepath.Join(dbDir, "a.md"))
+
+	// The entry itself is not rewritten per step
+	data, _ := os.ReadFile(e.file())
+	if !strings.Contains(string(data), `"steps": []`) {
+		t.Errorf("entry was rewritten by a step:\n%s", data)
+	}
+
+	// A step torn by a crash is ignored, the ones before it are not
+	f, _ := os.OpenFile(e.stepsFile(), os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString(`{"op":"mo`)
+	f.Close()
+	entries, err := List(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Len() != 2 {
+		t.Fatalf("entries = %v, want one with 2 steps", entries)
+	}
+
+	if err := e.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(e.stepsFile()); !os.IsNotExist(err) {
+		t.Error("step log should be folded into the entry on commit")
+	}
+	if last, _ := Last(dbDir); last == nil || last.Len() != 2 {
+		t.Errorf("Last = %v, want the entry with 2 steps", last)
+	}
+}
@@ -0,0 +1,268 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Protocol versions this server understands, newest first
+var protocolVersions = []string{"2025-03-26", "2024-11-05"}
+
+// JSON-RPC 2.0 error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Tool is a callable exposed to the client. Handler receives the raw
+// "arguments" object; a string result is sent as text, anything else as JSON.
+type Tool struct {
+	Name        string
+	Description string
+	InputSchema map[string]interface{}
+	Handler     func(args json.RawMessage) (interface{}, error)
+}
+
+// Resource describes a readable item
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// Server speaks the Model Context Protocol over newline-delimited JSON-RPC
+type Server struct {
+	Name    string
+	Version string
+	// ListResources enumerates resources for resources/list
+	ListResources func() ([]Resource, error)
+	// ReadResource returns the text content of a resource URI
+	ReadResource func(uri string) (text string, mimeType string, err error)
+
+	tools []Tool
+	mu    sync.Mutex // serializes writes to the output stream
+}
+
+// AddTool registers a tool
+func (s *Server) AddTool(t Tool) {
+	s.tools = append(s.tools, t)
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Serve reads requests from r and writes responses to w until r is exhausted or ctx ends
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return nil
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			s.write(w, response{JSONRPC: "2.0", ID: json.RawMessage("null"),
+				Error: &rpcError{Code: codeParseError, Message: err.Error()}})
+			continue
+		}
+
+		result, rerr := s.dispatch(req)
+
+		// Notifications (no id) never get a response
+		if len(req.ID) == 0 {
+			continue
+		}
+		resp := response{JSONRPC: "2.0", ID: req.ID}
+		if rerr != nil {
+			resp.Error = rerr
+		} else {
+			resp.Result = result
+		}
+		if err := s.write(w, resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *Server) write(w io.Writer, resp response) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+func (s *Server) dispatch(req request) (interface{}, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		return s.initialize(req.Params), nil
+	case "ping":
+		return map[string]interface{}{}, nil
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	case "tools/list":
+		return s.listTools(), nil
+	case "tools/call":
+		return s.callTool(req.Params)
+	case "resources/list":
+		return s.listResources()
+	case "resources/read":
+		return s.readResource(req.Params)
+	default:
+		if req.Method == "" {
+			return nil, &rpcError{Code: codeInvalidRequest, Message: "missing method"}
+		}
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+}
+
+func (s *Server) initialize(params json.RawMessage) interface{} {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	json.Unmarshal(params, &p)
+
+	// Echo the client's version if we support it, otherwise offer our newest
+	version := protocolVersions[0]
+	for _, v := range protocolVersions {
+		if v == p.ProtocolVersion {
+			version = v
+		}
+	}
+
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities": map[string]interface{}{
+			"tools":     map[string]interface{}{},
+			"resources": map[string]interface{}{},
+		},
+		"serverInfo": map[string]string{
+			"name":    s.Name,
+			"version": s.Version,
+		},
+	}
+}
+
+func (s *Server) listTools() interface{} {
+	tools := make([]map[string]interface{}, 0, len(s.tools))
+	for _, t := range s.tools {
+		schema := t.InputSchema
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object"}
+		}
+		tools = append(tools, map[string]interface{}{
+			"name":        t.Name,
+			"description": t.Description,
+			"inputSchema": schema,
+		})
+	}
+	return map[string]interface{}{"tools": tools}
+}
+
+func (s *Server) callTool(params json.RawMessage) (interface{}, *rpcError) {
+	var p struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	if len(p.Arguments) == 0 {
+		p.Arguments = json.RawMessage("{}")
+	}
+
+	for _, t := range s.tools {
+		if t.Name != p.Name {
+			continue
+		}
+		result, err := t.Handler(p.Arguments)
+		if err != nil {
+			// Tool failures are reported in-band so the model can react to them
+			return toolResult(err.Error(), true), nil
+		}
+		if text, ok := result.(string); ok {
+			return toolResult(text, false), nil
+		}
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return toolResult(err.Error(), true), nil
+		}
+		return toolResult(string(data), false), nil
+	}
+	return nil, &rpcError{Code: codeInvalidParams, Message: "unknown tool: " + p.Name}
+}
+
+func toolResult(text string, isError bool) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+func (s *Server) listResources() (interface{}, *rpcError) {
+	resources := []Resource{}
+	if s.ListResources != nil {
+		list, err := s.ListResources()
+		if err != nil {
+			return nil, &rpcError{Code: codeInvalidRequest, Message: err.Error()}
+		}
+		resources = append(resources, list...)
+	}
+	return map[string]interface{}{"resources": resources}, nil
+}
+
+func (s *Server) readResource(params json.RawMessage) (interface{}, *rpcError) {
+	var p struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.URI == "" {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "uri is required"}
+	}
+	if s.ReadResource == nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "resource not found: " + p.URI}
+	}
+	text, mimeType, err := s.ReadResource(p.URI)
+	if err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return map[string]interface{}{
+		"contents": []map[string]string{{
+			"uri":      p.URI,
+			"mimeType": mimeType,
+			"text":     text,
+		}},
+	}, nil
+}
@@ -0,0 +1,112 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// roundTrip feeds newline-delimited requests to a server and decodes each response line
+func roundTrip(t *testing.T, s *Server, requests ...string) []map[string]interface{} {
+	t.Helper()
+	var out bytes.Buffer
+	if err := s.Serve(context.Background(), strings.NewReader(strings.Join(requests, "\n")+"\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	var responses []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var resp map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("bad response %q: %v", line, err)
+		}
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+func TestServe_InitializeAndTools(t *testing.T) {
+	s := &Server{Name: "aidb", Version: "test"}
+	s.AddTool(Tool{
+		Name: "echo",
+		Handler: func(args json.RawMessage) (interface{}, error) {
+			var p struct{ Text string }
+			json.Unmarshal(args, &p)
+			return p.Text, nil
+		},
+	})
+	s.AddTool(Tool{
+		Name:    "fail",
+		Handler: func(json.RawMessage) (interface{}, error) { return nil, errors.New("boom") },
+	})
+
+	responses := roundTrip(t, s,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"fail"}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"bogus"}`,
+	)
+
+	// The notification gets no response
+	if len(responses) != 5 {
+		t.Fatalf("got %d responses, want 5", len(responses))
+	}
+
+	init := responses[0]["result"].(map[string]interface{})
+	if init["protocolVersion"] != "2024-11-05" {
+		t.Errorf("protocolVersion = %v", init["protocolVersion"])
+	}
+
+	tools := responses[1]["result"].(map[string]interface{})["tools"].([]interface{})
+	if len(tools) != 2 {
+		t.Errorf("tools = %v", tools)
+	}
+
+	echo := responses[2]["result"].(map[string]interface{})
+	text := echo["content"].([]interface{})[0].(map[string]interface{})["text"]
+	if text != "hi" || echo["isError"] != false {
+		t.Errorf("echo result = %v", echo)
+	}
+
+	fail := responses[3]["result"].(map[string]interface{})
+	if fail["isError"] != true {
+		t.Errorf("tool error should be reported in-band: %v", fail)
+	}
+
+	rpcErr := responses[4]["error"].(map[string]interface{})
+	if rpcErr["code"].(float64) != codeMethodNotFound {
+		t.Errorf("error = %v", rpcErr)
+	}
+}
+
+func TestServe_Resources(t *testing.T) {
+	s := &Server{
+		ListResources: func() ([]Resource, error) {
+			return []Resource{{URI: "aidb://p/main/a.md", Name: "p/main/a.md"}}, nil
+		},
+		ReadResource: func(uri string) (string, string, error) {
+			return "content of " + uri, "text/markdown", nil
+		},
+	}
+
+	responses := roundTrip(t, s,
+		`{"jsonrpc":"2.0","id":1,"method":"resources/list"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":"aidb://p/main/a.md"}}`,
+	)
+
+	list := responses[0]["result"].(map[string]interface{})["resources"].([]interface{})
+	if len(list) != 1 {
+		t.Fatalf("resources = %v", list)
+	}
+	contents := responses[1]["result"].(map[string]interface{})["contents"].([]interface{})
+	if contents[0].(map[string]interface{})["text"] != "content of aidb://p/main/a.md" {
+		t.Errorf("contents = %v", contents)
+	}
+}
@@ -0,0 +1,187 @@
+package metadata
+
+import (
+	"slices"
+	"sort"
+)
+
+// Merge combines two descendants of base, file by file and agent by agent.
+// A side that left an agent's state untouched yields to the side that changed
+// it; when both changed it, the later SeenAt wins, and a re-mark wins over an
+// unmark. Links and tags are merged as sets and the later review date wins.
+// An entry one side deleted stays deleted unless the other side changed more
+// than its seen state. The result never conflicts.
+func Merge(base, ours, theirs *Metadata) *Metadata {
+	merged := &Metadata{
+		Version: Version,
+		Files:   make(map[string]*FileInfo),
+		path:    ours.path,
+	}
+
+	for relPath := range unionKeys(base.Files, ours.Files, theirs.Files) {
+		if info, ok := mergeDeleted(base, ours, theirs, relPath); ok {
+			if info != nil {
+				merged.Files[relPath] = info
+			}
+			continue
+		}
+
+		b := consumers(base, relPath)
+		o := consumers(ours, relPath)
+		t := consumers(theirs, relPath)
+
+		result := make(map[string]*ConsumerState)
+		for agent := range unionKeys(b, o, t) {
+			if c := mergeConsumer(b[agent], o[agent], t[agent]); c != nil {
+				result[agent] = c
+			}
+		}
+		info := &FileInfo{
+			Consumers: result,
+			Links:     mergeLinks(links(base, relPath), links(ours, relPath), links(theirs, relPath)),
+			Tags:      mergeTags(base.Tags(relPath), ours.Tags(relPath), theirs.Tags(relPath)),
+		}
+		// Review dates only move forward; the later one wins
+		if o, ok := ours.Files[relPath]; ok {
+			info.ReviewAfter = o.ReviewAfter
+		}
+		if t, ok := theirs.Files[relPath]; ok {
+			info.ReviewAfter = max(info.ReviewAfter, t.ReviewAfter)
+		}
+		if len(result) == 0 {
+			info.Consumers = nil
+		}
+		if !info.empty() {
+			merged.Files[relPath] = info
+		}
+	}
+	return merged
+}
+
+// mergeDeleted handles an entry in base that one side no longer has. The
+// delete wins over an unchanged entry or one whose seen state alone changed,
+// as marking a file seen says nothing about keeping it; otherwise the other
+// side's entry is kept. ok is false if neither side deleted the entry.
+func mergeDeleted(base, ours, theirs *Metadata, relPath string) (info *FileInfo, ok bool) {
+	b, inBase := base.Files[relPath]
+	o, inOurs := ours.Files[relPath]
+	t, inTheirs := theirs.Files[relPath]
+	if !inBase || (inOurs && inTheirs) {
+		return nil, false
+	}
+	other := o
+	if !inOurs {
+		other = t
+	}
+	if !inOurs && !inTheirs || sameExceptSeen(b, other) {
+		return nil, true
+	}
+	return other, true
+}
+
+// sameExceptSeen reports whether a and b differ at most in seen state
+func sameExceptSeen(a, b *FileInfo) bool {
+	sameLink := func(x, y Link) bool { return x.Host == y.Host && x.Path == y.Path }
+	return a.ReviewAfter == b.ReviewAfter && slices.Equal(a.Tags, b.Tags) && slices.EqualFunc(a.Links, b.Links, sameLink)
+}
+
+// mergeLinks keeps a link both sides have, or one side added; a link either
+// side removed since base is dropped
+func mergeLinks(base, ours, theirs []Link) []Link {
+	key := func(l Link) string { return l.Host + "\x00" + l.Path }
+	index := func(links []Link) map[string]Link {
+		m := make(map[string]Link, len(links))
+		for _, l := range links {
+			m[key(l)] = l
+		}
+		return m
+	}
+	b, o, t := index(base), index(ours), index(theirs)
+
+	var merged []Link
+	for k := range unionKeys(o, t) {
+		_, inBase := b[k]
+		l, inOurs := o[k]
+		if !inOurs {
+			l = t[k]
+		}
+		_, inTheirs := t[k]
+		if (inOurs && inTheirs) || !inBase {
+			merged = append(merged, l)
+		}
+	}
+	sortLinks(merged)
+	return merged
+}
+
+// mergeTags keeps a tag both sides have, or one side added; a tag either
+// side removed since base is dropped
+func mergeTags(base, ours, theirs []string) []string {
+	set := func(tags []string) map[string]bool {
+		m := make(map[string]bool, len(tags))
+		for _, tag := range tags {
+			m[tag] = true
+		}
+		return m
+	}
+	b, o, t := set(base), set(ours), set(theirs)
+
+	var merged []string
+	for tag := range unionKeys(o, t) {
+		if (o[tag] && t[tag]) || !b[tag] {
+			merged = append(merged, tag)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+func mergeConsumer(base, ours, theirs *ConsumerState) *ConsumerState {
+	switch {
+	case sameState(ours, theirs):
+		return ours
+	case sameState(base, ours):
+		return theirs
+	case sameState(base, theirs):
+		return ours
+	case ours == nil:
+		return theirs
+	case theirs == nil:
+		return ours
+	case theirs.SeenAt.After(ours.SeenAt):
+		return theirs
+	default:
+		return ours
+	}
+}
+
+func sameState(a, b *ConsumerState) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Hash == b.Hash && a.SeenAt.Equal(b.SeenAt)
+}
+
+func consumers(m *Metadata, relPath string) map[string]*ConsumerState {
+	if info, ok := m.Files[relPath]; ok {
+		return info.Consumers
+	}
+	return nil
+}
+
+func links(m *Metadata, relPath string) []Link {
+	if info, ok := m.Files[relPath]; ok {
+		return info.Links
+	}
+	return nil
+}
+
+func unionKeys[V any](maps ...map[string]V) map[string]bool {
+	keys := make(map[string]bool)
+	for _, m := range maps {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+	return keys
+}
@@ -0,0 +1,153 @@
+package metadata
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func state(hash string, minute int) *ConsumerState {
+	return &ConsumerState{Hash: hash, SeenAt: time.Date(2025, 1, 1, 0, minute, 0, 0, time.UTC)}
+}
+
+func withFiles(files map[string]map[string]*ConsumerState) *Metadata {
+	m, _ := Parse(nil)
+	for relPath, c := range files {
+		m.Files[relPath] = &FileInfo{Consumers: c}
+	}
+	return m
+}
+
+func TestMerge_DifferentFilesOnEachSide(t *testing.T) {
+	base := withFiles(nil)
+	ours := withFiles(map[string]map[string]*ConsumerState{
+		"a.md": {"claude": state("sha256:a", 1)},
+	})
+	theirs := withFiles(map[string]map[string]*ConsumerState{
+		"b.md": {"cursor": state("sha256:b", 2)},
+	})
+
+	m := Merge(base, ours, theirs)
+	if !m.IsSeen("a.md", "claude", "sha256:a", time.Time{}) || !m.IsSeen("b.md", "cursor", "sha256:b", time.Time{}) {
+		t.Errorf("merge lost a side: %+v", m.Files)
+	}
+}
+
+func TestMerge_LastWriterWins(t *testing.T) {
+	base := withFiles(map[string]map[string]*ConsumerState{
+		"a.md": {"claude": state("sha256:old", 0)},
+	})
+	ours := withFiles(map[string]map[string]*ConsumerState{
+		"a.md": {"claude": state("sha256:ours", 5)},
+	})
+	theirs := withFiles(map[string]map[string]*ConsumerState{
+		"a.md": {"claude": state("sha256:theirs", 9)},
+	})
+
+	if c := Merge(base, ours, theirs).Consumer("a.md", "claude"); c == nil || c.Hash != "sha256:theirs" {
+		t.Errorf("later SeenAt should win, got %+v", c)
+	}
+	if c := Merge(base, theirs, ours).Consumer("a.md", "claude"); c == nil || c.Hash != "sha256:theirs" {
+		t.Errorf("later SeenAt should win regardless of side, got %+v", c)
+	}
+}
+
+func TestMerge_UnseenOnOneSide(t *testing.T) {
+	base := withFiles(map[string]map[string]*ConsumerState{
+		"a.md": {"claude": state("sha256:a", 0), "cursor": state("sha256:a", 0)},
+	})
+	// Ours unmarked claude; theirs left it alone
+	ours := withFiles(map[string]map[string]*ConsumerState{
+		"a.md": {"cursor": state("sha256:a", 0)},
+	})
+	theirs := withFiles(map[string]map[string]*ConsumerState{
+		"a.md": {"claude": state("sha256:a", 0), "cursor": state("sha256:a", 3)},
+	})
+
+	m := Merge(base, ours, theirs)
+	if m.Consumer("a.md", "claude") != nil {
+		t.Error("unmark on one side should survive an untouched other side")
+	}
+	if c := m.Consumer("a.md", "cursor"); c == nil || !c.SeenAt.Equal(state("", 3).SeenAt) {
+		t.Errorf("cursor = %+v, want theirs", c)
+	}
+}
+
+func TestMerge_Links(t *testing.T) {
+	link := func(host, path string) Link { return Link{Host: host, Path: path} }
+	withLinks := func(links ...Link) *Metadata {
+		m, _ := Parse(nil)
+		m.Files["a.md"] = &FileInfo{Links: links}
+		return m
+	}
+
+	base := withLinks(link("laptop", "/a"), link("laptop", "/b"))
+	ours := withLinks(link("laptop", "/a"), link("laptop", "/b"), link("laptop", "/c"))
+	theirs := withLinks(link("laptop", "/a"), link("desktop", "/d"))
+
+	got := Merge(base, ours, theirs).Links("a.md")
+	want := []Link{link("desktop", "/d"), link("laptop", "/a"), link("laptop", "/c")}
+	if len(got) != len(want) {
+		t.Fatalf("links = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Host != want[i].Host || got[i].Path != want[i].Path {
+			t.Errorf("links[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMerge_Tags(t *testing.T) {
+	withTags := func(tags ...string) *Metadata {
+		m, _ := Parse(nil)
+		m.Files["a.json"] = &FileInfo{Tags: tags}
+		return m
+	}
+
+	base := withTags("api", "old")
+	ours := withTags("api", "old", "db")
+	theirs := withTags("api", "auth")
+
+	got := Merge(base, ours, theirs).Tags("a.json")
+	if want := []string{"api", "auth", "db"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tags = %v, want %v", got, want)
+	}
+}
+
+func TestMerge_Deleted(t *testing.T) {
+	entry := func(hash string, minute int, tags ...string) *FileInfo {
+		return &FileInfo{
+			Consumers:   map[string]*ConsumerState{"claude": state(hash, minute)},
+			Links:       []Link{{Host: "laptop", Path: "/a"}},
+			Tags:        tags,
+			ReviewAfter: "2025-06-01",
+		}
+	}
+	with := func(info *FileInfo) *Metadata {
+		m, _ := Parse(nil)
+		if info != nil {
+			m.Files["a.json"] = info
+		}
+		return m
+	}
+
+	base := with(entry("sha256:a", 0))
+	deleted := with(nil)
+	for name, other := range map[string]*Metadata{
+		"unchanged": with(entry("sha256:a", 0)),
+		"seen":      with(entry("sha256:b", 5)),
+	} {
+		if info := Merge(base, deleted, other).GetInfo("a.json"); info != nil {
+			t.Errorf("%s: delete on ours should win, got %+v", name, info)
+		}
+		if info := Merge(base, other, deleted).GetInfo("a.json"); info != nil {
+			t.Errorf("%s: delete on theirs should win, got %+v", name, info)
+		}
+	}
+
+	// A tag added on the other side keeps the entry
+	tagged := with(entry("sha256:a", 0, "auth"))
+	if tags := Merge(base, deleted, tagged).Tags("a.json"); !reflect.DeepEqual(tags, []string{"auth"}) {
+		t.Errorf("tags = %v, want the other side's entry kept", tags)
+	}
+}
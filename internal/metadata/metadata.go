@@ -1,14 +1,27 @@
 package metadata
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 )
 
+// Version is the current .metadata.json schema version
+const Version = 5
+
+// DateFormat is the layout of review dates
+const DateFormat = "2006-01-02"
+
+// DefaultAgent is the consumer used when no agent is named, and the one
+// that inherits seen state from version 1 files
+const DefaultAgent = "default"
+
 // Metadata stores file tracking information
 type Metadata struct {
 	Version int                  `json:"version"`
@@ -18,6 +31,37 @@ type Metadata struct {
 
 // FileInfo stores per-file metadata
 type FileInfo struct {
+	Consumers map[string]*ConsumerState `json:"consumers,omitempty"`
+	Links     []Link                    `json:"links,omitempty"` // since version 3
+	Tags      []string                  `json:"tags,omitempty"`  // since version 4; non-Markdown files only
+
+	// ReviewAfter (YYYY-MM-DD) postpones the file's review; since version 5
+	ReviewAfter string `json:"reviewAfter,omitempty"`
+}
+
+// Link is a symlink to the file in a checkout. Paths are only meaningful on
+// the host that created them, since the database is synced across machines.
+type Link struct {
+	Host      string    `json:"host"`
+	Path      string    `json:"path"` // absolute
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (f *FileInfo) empty() bool {
+	return len(f.Consumers) == 0 && len(f.Links) == 0 && len(f.Tags) == 0 && f.ReviewAfter == ""
+}
+
+// ConsumerState records which content a consumer (agent) has processed.
+// Blob and Commit locate that content in git so it can be diffed later.
+type ConsumerState struct {
+	Hash   string    `json:"hash"`
+	SeenAt time.Time `json:"seenAt"`
+	Blob   string    `json:"blob,omitempty"`   // git blob of the seen content, under refs/aidb/seen/
+	Commit string    `json:"commit,omitempty"` // HEAD when the file was marked seen
+}
+
+// fileInfoV1 is the version 1 layout with a single global seen flag
+type fileInfoV1 struct {
 	Seen   bool      `json:"seen"`
 	Hash   string    `json:"hash"`
 	SeenAt time.Time `json:"seenAt,omitempty"`
@@ -27,7 +71,7 @@ type FileInfo struct {
 func New(dbDir string) (*Metadata, error) {
 	path := filepath.Join(dbDir, ".metadata.json")
 	m := &Metadata{
-		Version: 1,
+		Version: Version,
 		Files:   make(map[string]*FileInfo),
 		path:    path,
 	}
@@ -40,55 +84,177 @@ func New(dbDir string) (*Metadata, error) {
 		return nil, err
 	}
 
-	if err := json.Unmarshal(data, m); err != nil {
+	if err := m.decode(data); err != nil {
 		return nil, err
 	}
-	m.path = path
 	return m, nil
 }
 
+// Parse decodes metadata content in any known version. Empty content
+// (e.g. a merge base where the file did not exist) yields empty metadata.
+func Parse(data []byte) (*Metadata, error) {
+	m := &Metadata{
+		Version: Version,
+		Files:   make(map[string]*FileInfo),
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return m, nil
+	}
+	if err := m.decode(data); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// decode parses data in any known schema version, migrating it to the current one
+func (m *Metadata) decode(data []byte) error {
+	var raw struct {
+		Version int                        `json:"version"`
+		Files   map[string]json.RawMessage `json:"files"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Version > Version {
+		return fmt.Errorf("metadata version %d is newer than supported (%d); upgrade aidb", raw.Version, Version)
+	}
+
+	for relPath, fileData := range raw.Files {
+		if raw.Version >= 2 {
+			info := &FileInfo{}
+			if err := json.Unmarshal(fileData, info); err != nil {
+				return fmt.Errorf("%s: %w", relPath, err)
+			}
+			if !info.empty() {
+				m.Files[relPath] = info
+			}
+			continue
+		}
+
+		// Version 1: the global flag becomes the default agent's state
+		var old fileInfoV1
+		if err := json.Unmarshal(fileData, &old); err != nil {
+			return fmt.Errorf("%s: %w", relPath, err)
+		}
+		if old.Seen {
+			m.Files[relPath] = &FileInfo{Consumers: map[string]*ConsumerState{
+				DefaultAgent: {Hash: old.Hash, SeenAt: old.SeenAt},
+			}}
+		}
+	}
+	return nil
+}
+
 // Save writes metadata to disk
 func (m *Metadata) Save() error {
-	dir := filepath.Dir(m.path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	return m.SaveAs(m.path)
+}
+
+// SaveAs writes metadata to path in the current schema version
+func (m *Metadata) SaveAs(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
 
+	m.Version = Version
 	data, err := json.MarshalIndent(m, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(m.path, data, 0644)
+	return os.WriteFile(path, data, 0644)
 }
 
-// MarkSeen marks a file as seen with current hash
-func (m *Metadata) MarkSeen(relPath string, hash string) {
-	m.Files[relPath] = &FileInfo{
-		Seen:   true,
+// MarkSeen records that agent has processed the file at hash and returns
+// the new state so callers can attach git locations
+func (m *Metadata) MarkSeen(relPath, agent, hash string) *ConsumerState {
+	info, ok := m.Files[relPath]
+	if !ok {
+		info = &FileInfo{}
+		m.Files[relPath] = info
+	}
+	if info.Consumers == nil {
+		info.Consumers = make(map[string]*ConsumerState)
+	}
+	c := &ConsumerState{
 		Hash:   hash,
 		SeenAt: time.Now().UTC(),
 	}
+	info.Consumers[agent] = c
+	return c
 }
 
-// MarkUnseen marks a file as unseen
-func (m *Metadata) MarkUnseen(relPath string) {
-	if info, ok := m.Files[relPath]; ok {
-		info.Seen = false
+// MarkUnseen forgets that agent has processed the file
+func (m *Metadata) MarkUnseen(relPath, agent string) {
+	info, ok := m.Files[relPath]
+	if !ok {
+		return
+	}
+	delete(info.Consumers, agent)
+	if info.empty() {
+		delete(m.Files, relPath)
 	}
 }
 
-// IsSeen returns true if file was seen and hash matches
-func (m *Metadata) IsSeen(relPath string, currentHash string) bool {
+// IsSeen returns true if agent has seen the file and its hash still matches.
+// Once due (see Due) has passed, a file seen before it is unseen again.
+func (m *Metadata) IsSeen(relPath, agent, currentHash string, due time.Time) bool {
+	return seenCurrent(m.Consumer(relPath, agent), currentHash, due)
+}
+
+// SeenBy returns the agents that have seen the current content, sorted
+func (m *Metadata) SeenBy(relPath, currentHash string, due time.Time) []string {
 	info, ok := m.Files[relPath]
 	if !ok {
-		return false
+		return nil
 	}
-	// If hash changed, mark as unseen
-	if info.Hash != currentHash {
-		info.Seen = false
+	var agents []string
+	for agent, c := range info.Consumers {
+		if seenCurrent(c, currentHash, due) {
+			agents = append(agents, agent)
+		}
+	}
+	sort.Strings(agents)
+	return agents
+}
+
+func seenCurrent(c *ConsumerState, currentHash string, due time.Time) bool {
+	if c == nil || c.Hash != currentHash {
 		return false
 	}
-	return info.Seen
+	return due.IsZero() || time.Now().Before(due) || !c.SeenAt.Before(due)
+}
+
+// Due returns when a file needs review: the date from its content (e.g.
+// frontmatter), unless a later review was recorded. Zero means never.
+func (m *Metadata) Due(relPath string, content time.Time) time.Time {
+	info, ok := m.Files[relPath]
+	if !ok || info.ReviewAfter == "" {
+		return content
+	}
+	reviewed, err := time.ParseInLocation(DateFormat, info.ReviewAfter, time.Local)
+	if err != nil || reviewed.Before(content) {
+		return content
+	}
+	return reviewed
+}
+
+// SetReviewAfter records that a file was reviewed and is next due on date
+func (m *Metadata) SetReviewAfter(relPath string, date time.Time) {
+	info, ok := m.Files[relPath]
+	if !ok {
+		info = &FileInfo{}
+		m.Files[relPath] = info
+	}
+	info.ReviewAfter = date.Format(DateFormat)
+}
+
+// Consumer returns agent's state for a file or nil
+func (m *Metadata) Consumer(relPath, agent string) *ConsumerState {
+	info, ok := m.Files[relPath]
+	if !ok {
+		return nil
+	}
+	return info.Consumers[agent]
 }
 
 // GetInfo returns file info or nil
@@ -101,12 +267,135 @@ func (m *Metadata) Remove(relPath string) {
 	delete(m.Files, relPath)
 }
 
+// Move re-keys a file's seen state and links to newPath. If newPath already
+// has state, each agent keeps whichever record is more recent.
+func (m *Metadata) Move(oldPath, newPath string) {
+	info, ok := m.Files[oldPath]
+	if !ok || oldPath == newPath {
+		return
+	}
+	delete(m.Files, oldPath)
+
+	existing, ok := m.Files[newPath]
+	if !ok {
+		m.Files[newPath] = info
+		return
+	}
+	if existing.Consumers == nil {
+		existing.Consumers = make(map[string]*ConsumerState)
+	}
+	for agent, c := range info.Consumers {
+		if cur := existing.Consumers[agent]; cur == nil || c.SeenAt.After(cur.SeenAt) {
+			existing.Consumers[agent] = c
+		}
+	}
+	for _, l := range info.Links {
+		existing.addLink(l)
+	}
+	existing.Tags = unionTags(existing.Tags, info.Tags)
+	existing.ReviewAfter = max(existing.ReviewAfter, info.ReviewAfter)
+}
+
+// AddLink records a symlink to the file on host, ignoring duplicates
+func (m *Metadata) AddLink(relPath, host, path string) {
+	info, ok := m.Files[relPath]
+	if !ok {
+		info = &FileInfo{}
+		m.Files[relPath] = info
+	}
+	info.addLink(Link{Host: host, Path: path, CreatedAt: time.Now().UTC()})
+}
+
+// RemoveLink forgets a recorded symlink
+func (m *Metadata) RemoveLink(relPath, host, path string) {
+	info, ok := m.Files[relPath]
+	if !ok {
+		return
+	}
+	links := info.Links[:0]
+	for _, l := range info.Links {
+		if l.Host != host || l.Path != path {
+			links = append(links, l)
+		}
+	}
+	info.Links = links
+	if info.empty() {
+		delete(m.Files, relPath)
+	}
+}
+
+// Links returns the recorded symlinks of a file, sorted by host and path
+func (m *Metadata) Links(relPath string) []Link {
+	if info, ok := m.Files[relPath]; ok {
+		return info.Links
+	}
+	return nil
+}
+
+// SetTags replaces the tags of a file, which should be normalized
+func (m *Metadata) SetTags(relPath string, tags []string) {
+	info, ok := m.Files[relPath]
+	if !ok {
+		info = &FileInfo{}
+		m.Files[relPath] = info
+	}
+	info.Tags = tags
+	if info.empty() {
+		delete(m.Files, relPath)
+	}
+}
+
+// Tags returns the tags recorded for a file
+func (m *Metadata) Tags(relPath string) []string {
+	if info, ok := m.Files[relPath]; ok {
+		return info.Tags
+	}
+	return nil
+}
+
+func unionTags(a, b []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, tag := range append(append([]string{}, a...), b...) {
+		if !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (f *FileInfo) addLink(link Link) {
+	for _, l := range f.Links {
+		if l.Host == link.Host && l.Path == link.Path {
+			return
+		}
+	}
+	f.Links = append(f.Links, link)
+	sortLinks(f.Links)
+}
+
+func sortLinks(links []Link) {
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Host != links[j].Host {
+			return links[i].Host < links[j].Host
+		}
+		return links[i].Path < links[j].Path
+	})
+}
+
 // HashFile computes SHA256 hash of file content
 func HashFile(path string) (string, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return "", err
 	}
+	return HashBytes(data), nil
+}
+
+// HashBytes computes SHA256 hash of content already in memory
+func HashBytes(data []byte) string {
 	hash := sha256.Sum256(data)
-	return "sha256:" + hex.EncodeToString(hash[:]), nil
+	return "sha256:" + hex.EncodeToString(hash[:])
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestNew_NoFile(t *testing.T) {
@@ -14,8 +15,8 @@ func TestNew_NoFile(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if m.Version != 1 {
-		t.Errorf("Version = %d, want 1", m.Version)
+	if m.Version != Version {
+		t.Errorf("Version = %d, want %d", m.Version, Version)
 	}
 	if len(m.Files) != 0 {
 		t.Errorf("Files = %v, want empty", m.Files)
@@ -26,7 +27,7 @@ func TestSaveAndLoad(t *testing.T) {
 	tmpDir := t.TempDir()
 
 	m, _ := New(tmpDir)
-	m.MarkSeen("test/file.md", "sha256:abc123")
+	m.MarkSeen("test/file.md", "claude", "sha256:abc123")
 	if err := m.Save(); err != nil {
 		t.Fatal(err)
 	}
@@ -37,15 +38,15 @@ func TestSaveAndLoad(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	info := m2.GetInfo("test/file.md")
-	if info == nil {
-		t.Fatal("info is nil")
+	c := m2.Consumer("test/file.md", "claude")
+	if c == nil {
+		t.Fatal("consumer state is nil")
 	}
-	if !info.Seen {
-		t.Error("Seen = false, want true")
+	if c.Hash != "sha256:abc123" {
+		t.Errorf("Hash = %q, want %q", c.Hash, "sha256:abc123")
 	}
-	if info.Hash != "sha256:abc123" {
-		t.Errorf("Hash = %q, want %q", info.Hash, "sha256:abc123")
+	if c.SeenAt.IsZero() {
+		t.Error("SeenAt not recorded")
 	}
 }
 
@@ -53,22 +54,19 @@ func TestIsSeen_HashChanged(t *testing.T) {
 	tmpDir := t.TempDir()
 
 	m, _ := New(tmpDir)
-	m.MarkSeen("file.md", "sha256:original")
+	m.MarkSeen("file.md", DefaultAgent, "sha256:original")
 
 	// Same hash - still seen
-	if !m.IsSeen("file.md", "sha256:original") {
+	if !m.IsSeen("file.md", DefaultAgent, "sha256:original", time.Time{}) {
 		t.Error("IsSeen should be true for same hash")
 	}
 
 	// Different hash - becomes unseen
-	if m.IsSeen("file.md", "sha256:changed") {
+	if m.IsSeen("file.md", DefaultAgent, "sha256:changed", time.Time{}) {
 		t.Error("IsSeen should be false for changed hash")
 	}
-
-	// Verify it was marked unseen
-	info := m.GetInfo("file.md")
-	if info.Seen {
-		t.Error("Seen should be false after hash change")
+	if got := m.SeenBy("file.md", "sha256:changed", time.Time{}); len(got) != 0 {
+		t.Errorf("SeenBy after hash change = %v, want none", got)
 	}
 }
 
@@ -76,19 +74,76 @@ func TestMarkUnseen(t *testing.T) {
 	tmpDir := t.TempDir()
 
 	m, _ := New(tmpDir)
-	m.MarkSeen("file.md", "sha256:abc")
-	m.MarkUnseen("file.md")
+	m.MarkSeen("file.md", DefaultAgent, "sha256:abc")
+	m.MarkUnseen("file.md", DefaultAgent)
 
-	if m.IsSeen("file.md", "sha256:abc") {
+	if m.IsSeen("file.md", DefaultAgent, "sha256:abc", time.Time{}) {
 		t.Error("IsSeen should be false after MarkUnseen")
 	}
 }
 
+func TestSeen_PerAgent(t *testing.T) {
+	m, _ := New(t.TempDir())
+	m.MarkSeen("file.md", "claude", "sha256:abc")
+	m.MarkSeen("file.md", "cursor", "sha256:abc")
+
+	if m.IsSeen("file.md", "ci", "sha256:abc", time.Time{}) {
+		t.Error("seen by claude should not mean seen by ci")
+	}
+
+	m.MarkUnseen("file.md", "claude")
+	if m.IsSeen("file.md", "claude", "sha256:abc", time.Time{}) {
+		t.Error("claude should be unseen after MarkUnseen")
+	}
+	if got := m.SeenBy("file.md", "sha256:abc", time.Time{}); len(got) != 1 || got[0] != "cursor" {
+		t.Errorf("SeenBy = %v, want [cursor]", got)
+	}
+}
+
+func TestNew_MigratesVersion1(t *testing.T) {
+	tmpDir := t.TempDir()
+	v1 := `{
+  "version": 1,
+  "files": {
+    "p/main/seen.md": {"seen": true, "hash": "sha256:abc", "seenAt": "2024-01-02T03:04:05Z"},
+    "p/main/unseen.md": {"seen": false, "hash": "sha256:def"}
+  }
+}`
+	if err := os.WriteFile(filepath.Join(tmpDir, ".metadata.json"), []byte(v1), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := New(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.IsSeen("p/main/seen.md", DefaultAgent, "sha256:abc", time.Time{}) {
+		t.Error("v1 seen file should be seen by the default agent")
+	}
+	if c := m.Consumer("p/main/seen.md", DefaultAgent); c == nil || c.SeenAt.Year() != 2024 {
+		t.Errorf("SeenAt not migrated: %+v", c)
+	}
+	if m.GetInfo("p/main/unseen.md") != nil {
+		t.Error("v1 unseen file should have no consumers")
+	}
+
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	m2, err := New(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m2.Version != Version || !m2.IsSeen("p/main/seen.md", DefaultAgent, "sha256:abc", time.Time{}) {
+		t.Error("migrated metadata did not round-trip")
+	}
+}
+
 func TestRemove(t *testing.T) {
 	tmpDir := t.TempDir()
 
 	m, _ := New(tmpDir)
-	m.MarkSeen("file.md", "sha256:abc")
+	m.MarkSeen("file.md", DefaultAgent, "sha256:abc")
 	m.Remove("file.md")
 
 	if m.GetInfo("file.md") != nil {
@@ -115,3 +170,91 @@ func TestHashFile(t *testing.T) {
 		t.Errorf("hash = %q, want %q", hash, expected)
 	}
 }
+
+func TestMove(t *testing.T) {
+	m, _ := New(t.TempDir())
+	m.MarkSeen("p/feature/a.md", "claude", "sha256:new")
+	m.MarkSeen("p/main/a.md", "cursor", "sha256:old")
+
+	m.Move("p/feature/a.md", "p/main/a.md")
+
+	if m.GetInfo("p/feature/a.md") != nil {
+		t.Error("old path should be gone")
+	}
+	if !m.IsSeen("p/main/a.md", "claude", "sha256:new", time.Time{}) || !m.IsSeen("p/main/a.md", "cursor", "sha256:old", time.Time{}) {
+		t.Errorf("consumers not merged: %+v", m.GetInfo("p/main/a.md").Consumers)
+	}
+}
+
+func TestLinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	m, _ := New(tmpDir)
+
+	m.AddLink("p/main/a.md", "laptop", "/home/u/p/a.md")
+	m.AddLink("p/main/a.md", "laptop", "/home/u/p/a.md")
+	m.AddLink("p/main/a.md", "desktop", "/srv/p/a.md")
+	if links := m.Links("p/main/a.md"); len(links) != 2 || links[0].Host != "desktop" {
+		t.Fatalf("links = %+v", links)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Links alone keep an entry alive across a reload
+	m2, err := New(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m2.Links("p/main/a.md")) != 2 {
+		t.Errorf("links not persisted: %+v", m2.GetInfo("p/main/a.md"))
+	}
+
+	m2.Move("p/main/a.md", "p/main/b.md")
+	if len(m2.Links("p/main/b.md")) != 2 {
+		t.Error("links should follow a move")
+	}
+
+	m2.RemoveLink("p/main/b.md", "desktop", "/srv/p/a.md")
+	m2.RemoveLink("p/main/b.md", "laptop", "/home/u/p/a.md")
+	if m2.GetInfo("p/main/b.md") != nil {
+		t.Error("entry without links or consumers should be dropped")
+	}
+}
+
+func TestNew_ReadsVersion2(t *testing.T) {
+	tmpDir := t.TempDir()
+	v2 := `{"version": 2, "files": {"a.md": {"consumers": {"claude": {"hash": "sha256:a", "seenAt": "2025-01-01T00:00:00Z"}}}}}`
+	if err := os.WriteFile(filepath.Join(tmpDir, ".metadata.json"), []byte(v2), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := New(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.IsSeen("a.md", "claude", "sha256:a", time.Time{}) || len(m.Links("a.md")) != 0 {
+		t.Errorf("version 2 not read: %+v", m.GetInfo("a.md"))
+	}
+}
+
+func TestIsSeen_Due(t *testing.T) {
+	m, _ := Parse(nil)
+	m.MarkSeen("a.md", "claude", "sha256:a").SeenAt = time.Now().AddDate(0, 0, -30)
+
+	if !m.IsSeen("a.md", "claude", "sha256:a", time.Now().Add(24*time.Hour)) {
+		t.Error("file due tomorrow should stay seen")
+	}
+	past := time.Now().Add(-24 * time.Hour)
+	if m.IsSeen("a.md", "claude", "sha256:a", past) || len(m.SeenBy("a.md", "sha256:a", past)) != 0 {
+		t.Error("file seen before its due date should be unseen once due")
+	}
+	m.MarkSeen("a.md", "claude", "sha256:a")
+	if !m.IsSeen("a.md", "claude", "sha256:a", past) {
+		t.Error("seeing a due file again should clear it")
+	}
+
+	m.SetReviewAfter("a.md", time.Now().AddDate(0, 0, 90))
+	if due := m.Due("a.md", past); !due.After(time.Now()) {
+		t.Errorf("due = %v, want the later review date", due)
+	}
+}
@@ -0,0 +1,164 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const cronTemplate = `# BEGIN {{.Label}} (managed by aidb)
+{{.Spec}} {{.Command}} >> {{.LogPath}} 2>&1
+# END {{.Label}}
+`
+
+// Cron schedules jobs as a marked block in the user's crontab
+type Cron struct {
+	Run Runner
+}
+
+func (c *Cron) Name() string { return "cron" }
+
+// Spec converts an interval into a cron schedule. Only intervals that divide
+// evenly into minutes (< 1h), hours (< 1d) or whole days are representable.
+func Spec(interval time.Duration) (string, error) {
+	switch {
+	case interval < time.Minute || interval%time.Minute != 0:
+		return "", fmt.Errorf("cron interval must be a whole number of minutes: %s", interval)
+	case interval < time.Hour:
+		return fmt.Sprintf("*/%d * * * *", int(interval.Minutes())), nil
+	case interval%time.Hour != 0:
+		return "", fmt.Errorf("cron interval over 1h must be a whole number of hours: %s", interval)
+	case interval < 24*time.Hour:
+		return fmt.Sprintf("0 */%d * * *", int(interval.Hours())), nil
+	case interval%(24*time.Hour) != 0:
+		return "", fmt.Errorf("cron interval over 1d must be a whole number of days: %s", interval)
+	default:
+		return fmt.Sprintf("0 0 */%d * *", int(interval.Hours()/24)), nil
+	}
+}
+
+// Render returns existing crontab content with the job's block replaced
+func (c *Cron) Render(existing string, job Job) (string, error) {
+	spec, err := Spec(job.Interval)
+	if err != nil {
+		return "", err
+	}
+	quoted := make([]string, len(job.Command))
+	for i, arg := range job.Command {
+		quoted[i] = cronQuote(arg)
+	}
+	block, err := render("cron", cronTemplate, map[string]string{
+		"Label":   job.Label,
+		"Spec":    spec,
+		"Command": strings.Join(quoted, " "),
+		"LogPath": cronQuote(job.LogPath),
+	})
+	if err != nil {
+		return "", err
+	}
+	return stripBlock(existing, job.Label) + block, nil
+}
+
+func (c *Cron) Enable(job Job) error {
+	existing, err := c.read()
+	if err != nil {
+		return err
+	}
+	content, err := c.Render(existing, job)
+	if err != nil {
+		return err
+	}
+	if _, err := c.Run(content, "crontab", "-"); err != nil {
+		return fmt.Errorf("failed to install crontab: %w", err)
+	}
+	return nil
+}
+
+func (c *Cron) Disable(job Job) error {
+	existing, err := c.read()
+	if err != nil {
+		return err
+	}
+	content := stripBlock(existing, job.Label)
+	if content == existing {
+		return nil
+	}
+	if strings.TrimSpace(content) == "" {
+		if _, err := c.Run("", "crontab", "-r"); err != nil {
+			return fmt.Errorf("failed to remove crontab: %w", err)
+		}
+		return nil
+	}
+	if _, err := c.Run(content, "crontab", "-"); err != nil {
+		return fmt.Errorf("failed to update crontab: %w", err)
+	}
+	return nil
+}
+
+func (c *Cron) Status(job Job) (Status, error) {
+	existing, err := c.read()
+	if err != nil {
+		return Status{}, err
+	}
+	if stripBlock(existing, job.Label) == existing {
+		return Status{}, nil
+	}
+	return Status{Installed: true, Active: true, Detail: "crontab"}, nil
+}
+
+// read returns the current crontab, or "" if the user has none. Any other
+// failure is an error, so a crontab that could not be read is never replaced.
+// The C locale keeps vixie cron's "no crontab for" untranslated; other crons
+// just exit 1 without output.
+func (c *Cron) read() (string, error) {
+	out, err := c.Run("", "env", "LC_ALL=C", "crontab", "-l")
+	if err == nil {
+		return string(out), nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) &&
+		(strings.Contains(string(exitErr.Stderr), "no crontab for") || exitErr.ExitCode() == 1 && len(out) == 0) {
+		return "", nil
+	}
+	return "", fmt.Errorf("failed to read crontab: %w", err)
+}
+
+// stripBlock removes the BEGIN/END block for label from crontab content
+func stripBlock(content, label string) string {
+	begin := "# BEGIN " + label
+	end := "# END " + label
+
+	var kept []string
+	inBlock := false
+	for _, line := range strings.Split(content, "\n") {
+		switch {
+		case strings.HasPrefix(line, begin):
+			inBlock = true
+		case inBlock && strings.HasPrefix(line, end):
+			inBlock = false
+		case !inBlock:
+			kept = append(kept, line)
+		}
+	}
+	out := strings.Join(kept, "\n")
+	if out != "" && !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	return out
+}
+
+// cronQuote quotes an argument for a crontab command line. Cron turns an
+// unescaped % into a newline even inside quotes, so it is escaped as well.
+func cronQuote(arg string) string {
+	return strings.ReplaceAll(shellQuote(arg), "%", `\%`)
+}
+
+// shellQuote single-quotes an argument for /bin/sh if needed
+func shellQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`;&|<>*?()#~%") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
@@ -0,0 +1,90 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const plistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+    <key>Label</key>
+    <string>{{.Label}}</string>
+    <key>ProgramArguments</key>
+    <array>
+{{- range .Command}}
+        <string>{{.}}</string>
+{{- end}}
+    </array>
+    <key>StartInterval</key>
+    <integer>{{.Seconds}}</integer>
+    <key>RunAtLoad</key>
+    <true/>
+    <key>StandardOutPath</key>
+    <string>{{.LogPath}}</string>
+    <key>StandardErrorPath</key>
+    <string>{{.LogPath}}</string>
+</dict>
+</plist>
+`
+
+// Launchd schedules jobs as macOS LaunchAgents
+type Launchd struct {
+	Run Runner
+}
+
+func (l *Launchd) Name() string { return "launchd" }
+
+// PlistPath returns the LaunchAgent path for a job
+func (l *Launchd) PlistPath(job Job) string {
+	return filepath.Join(job.HomeDir, "Library", "LaunchAgents", job.Label+".plist")
+}
+
+// Render returns the plist content for a job
+func (l *Launchd) Render(job Job) (string, error) {
+	return render("plist", plistTemplate, struct {
+		Job
+		Seconds int
+	}{job, int(job.Interval.Seconds())})
+}
+
+func (l *Launchd) Enable(job Job) error {
+	content, err := l.Render(job)
+	if err != nil {
+		return err
+	}
+	path := l.PlistPath(job)
+	if err := writeFile(path, content); err != nil {
+		return err
+	}
+
+	l.Run("", "launchctl", "unload", path) // Ignore error if not loaded
+	if _, err := l.Run("", "launchctl", "load", path); err != nil {
+		return fmt.Errorf("failed to load launch agent: %w", err)
+	}
+	return nil
+}
+
+func (l *Launchd) Disable(job Job) error {
+	path := l.PlistPath(job)
+	l.Run("", "launchctl", "unload", path)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove plist: %w", err)
+	}
+	return nil
+}
+
+func (l *Launchd) Status(job Job) (Status, error) {
+	path := l.PlistPath(job)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return Status{}, nil
+	}
+	out, _ := l.Run("", "launchctl", "list", job.Label)
+	return Status{
+		Installed: true,
+		Active:    len(out) > 0,
+		Detail:    path,
+	}, nil
+}
@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"text/template"
+	"time"
+)
+
+// Job describes a periodic command to install
+type Job struct {
+	Label    string        // unique identifier, e.g. "com.aidb.backup"
+	Command  []string      // binary and arguments
+	Interval time.Duration // time between runs
+	LogPath  string        // stdout/stderr destination
+	HomeDir  string
+}
+
+// Status reports what a scheduler knows about an installed job
+type Status struct {
+	Installed bool   // unit/plist/crontab entry exists
+	Active    bool   // loaded by the service manager
+	Detail    string // scheduler-specific description
+}
+
+// Scheduler installs and removes periodic jobs
+type Scheduler interface {
+	Name() string
+	Enable(job Job) error
+	Disable(job Job) error
+	Status(job Job) (Status, error)
+}
+
+// Runner executes an external command, feeding stdin when non-empty
+type Runner func(stdin string, name string, args ...string) ([]byte, error)
+
+// ExecRunner runs commands with os/exec
+func ExecRunner(stdin string, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != "" {
+		cmd.Stdin = bytes.NewBufferString(stdin)
+	}
+	return cmd.Output()
+}
+
+// New returns the scheduler with the given name. "auto" picks launchd on
+// macOS, systemd user timers when a user manager is reachable, and cron otherwise.
+func New(name string, run Runner) (Scheduler, error) {
+	if run == nil {
+		run = ExecRunner
+	}
+	if name == "" || name == "auto" {
+		name = detect(run)
+	}
+
+	switch name {
+	case "launchd":
+		return &Launchd{Run: run}, nil
+	case "systemd":
+		return &Systemd{Run: run}, nil
+	case "cron":
+		return &Cron{Run: run}, nil
+	default:
+		return nil, fmt.Errorf("unknown scheduler: %s", name)
+	}
+}
+
+func detect(run Runner) string {
+	if runtime.GOOS == "darwin" {
+		return "launchd"
+	}
+	if _, err := run("", "systemctl", "--user", "show-environment"); err == nil {
+		return "systemd"
+	}
+	return "cron"
+}
+
+// render executes a text template into a string
+func render(name, text string, data interface{}) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// writeFile creates parent directories and writes content
+func writeFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
@@ -0,0 +1,225 @@
+package scheduler
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRunner records invocations and returns canned output per command name
+type fakeRunner struct {
+	calls  []string
+	stdin  []string
+	output map[string]string
+	errs   map[string]error
+}
+
+func (f *fakeRunner) run(stdin string, name string, args ...string) ([]byte, error) {
+	call := name + " " + strings.Join(args, " ")
+	f.calls = append(f.calls, call)
+	f.stdin = append(f.stdin, stdin)
+	return []byte(f.output[call]), f.errs[call]
+}
+
+func testJob(t *testing.T) Job {
+	return Job{
+		Label:    "com.aidb.backup",
+		Command:  []string{"/usr/local/bin/aidb", "backup-run"},
+		Interval: 30 * time.Minute,
+		LogPath:  "/home/u/.aidb/backup.log",
+		HomeDir:  t.TempDir(),
+	}
+}
+
+func TestLaunchd_Enable(t *testing.T) {
+	job := testJob(t)
+	f := &fakeRunner{}
+	l := &Launchd{Run: f.run}
+
+	if err := l.Enable(job); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(l.PlistPath(job))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plist := string(data)
+	for _, want := range []string{
+		"<string>com.aidb.backup</string>",
+		"<string>/usr/local/bin/aidb</string>",
+		"<string>backup-run</string>",
+		"<integer>1800</integer>",
+	} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("plist missing %q:\n%s", want, plist)
+		}
+	}
+}
+
+func TestSystemd_EnableDisable(t *testing.T) {
+	job := testJob(t)
+	f := &fakeRunner{}
+	s := &Systemd{Run: f.run}
+
+	if err := s.Enable(job); err != nil {
+		t.Fatal(err)
+	}
+
+	servicePath, timerPath := s.UnitPaths(job)
+	service, err := os.ReadFile(servicePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timer, err := os.ReadFile(timerPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(service), "ExecStart=/usr/local/bin/aidb backup-run") {
+		t.Errorf("service missing ExecStart:\n%s", service)
+	}
+	if !strings.Contains(string(service), "StandardOutput=append:/home/u/.aidb/backup.log") {
+		t.Errorf("service missing log redirection:\n%s", service)
+	}
+	if !strings.Contains(string(timer), "OnUnitActiveSec=1800s") {
+		t.Errorf("timer missing interval:\n%s", timer)
+	}
+	if !strings.Contains(string(timer), "Unit=aidb-backup.service") {
+		t.Errorf("timer missing unit:\n%s", timer)
+	}
+	if got := f.calls[len(f.calls)-1]; got != "systemctl --user enable --now aidb-backup.timer" {
+		t.Errorf("last call = %q", got)
+	}
+
+	if err := s.Disable(job); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(timerPath); !os.IsNotExist(err) {
+		t.Error("timer unit should be removed")
+	}
+}
+
+func TestCron_RenderReplacesBlock(t *testing.T) {
+	job := testJob(t)
+	c := &Cron{}
+
+	existing := "0 5 * * * /usr/bin/other\n"
+	first, err := c.Render(existing, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(first, "*/30 * * * * /usr/local/bin/aidb backup-run >> /home/u/.aidb/backup.log 2>&1") {
+		t.Errorf("crontab missing job line:\n%s", first)
+	}
+	if !strings.HasPrefix(first, existing) {
+		t.Errorf("existing entries should be preserved:\n%s", first)
+	}
+
+	// Re-rendering replaces rather than duplicates the block
+	job.Interval = 2 * time.Hour
+	second, err := c.Render(first, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(second, "# BEGIN com.aidb.backup") != 1 {
+		t.Errorf("expected exactly one block:\n%s", second)
+	}
+	if !strings.Contains(second, "0 */2 * * *") {
+		t.Errorf("expected updated schedule:\n%s", second)
+	}
+
+	if got := stripBlock(second, job.Label); got != existing {
+		t.Errorf("stripBlock = %q, want %q", got, existing)
+	}
+}
+
+func TestCron_ReadErrors(t *testing.T) {
+	job := testJob(t)
+
+	// No crontab yet: the job's block is installed on its own
+	f := &fakeRunner{errs: map[string]error{
+		"env LC_ALL=C crontab -l": &exec.ExitError{Stderr: []byte("no crontab for u\n")},
+	}}
+	if err := (&Cron{Run: f.run}).Enable(job); err != nil {
+		t.Fatalf("Enable without a crontab: %v", err)
+	}
+	if len(f.calls) != 2 || f.calls[1] != "crontab -" {
+		t.Errorf("calls = %v, want crontab -l then crontab -", f.calls)
+	}
+
+	// Crons without that message exit 1 with nothing on stdout
+	exit1 := exec.Command("sh", "-c", "exit 1").Run()
+	f = &fakeRunner{errs: map[string]error{"env LC_ALL=C crontab -l": exit1}}
+	if err := (&Cron{Run: f.run}).Enable(job); err != nil {
+		t.Fatalf("Enable after exit 1 without output: %v", err)
+	}
+
+	// Any other failure must not replace the user's crontab
+	f = &fakeRunner{errs: map[string]error{
+		"env LC_ALL=C crontab -l": &exec.ExitError{Stderr: []byte("crontab: permission denied\n")},
+	}}
+	c := &Cron{Run: f.run}
+	if err := c.Enable(job); err == nil {
+		t.Error("Enable should fail when the crontab cannot be read")
+	}
+	if err := c.Disable(job); err == nil {
+		t.Error("Disable should fail when the crontab cannot be read")
+	}
+	for _, call := range f.calls {
+		if call != "env LC_ALL=C crontab -l" {
+			t.Errorf("unexpected %q after a failed read", call)
+		}
+	}
+
+	// Removing the last block reports a failed crontab -r
+	existing, _ := c.Render("", job)
+	f = &fakeRunner{
+		output: map[string]string{"env LC_ALL=C crontab -l": existing},
+		errs:   map[string]error{"crontab -r": errors.New("exit status 1")},
+	}
+	if err := (&Cron{Run: f.run}).Disable(job); err == nil {
+		t.Error("Disable should report a failed crontab -r")
+	}
+}
+
+func TestCron_RenderEscapesPercent(t *testing.T) {
+	job := testJob(t)
+	job.Command = []string{"/usr/local/bin/aidb", "backup-run", "--message", "100%"}
+	job.LogPath = "/home/u/%h/backup.log"
+
+	got, err := (&Cron{}).Render("", job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, `'100\%'`) || !strings.Contains(got, `'/home/u/\%h/backup.log'`) {
+		t.Errorf("%% should be escaped for cron:\n%s", got)
+	}
+}
+
+func TestSpec(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		want     string
+		wantErr  bool
+	}{
+		{15 * time.Minute, "*/15 * * * *", false},
+		{time.Hour, "0 */1 * * *", false},
+		{48 * time.Hour, "0 0 */2 * *", false},
+		{90 * time.Second, "", true},
+		{90 * time.Minute, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Spec(tt.interval)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Spec(%s) error = %v, wantErr %v", tt.interval, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("Spec(%s) = %q, want %q", tt.interval, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const serviceTemplate = `[Unit]
+Description=aidb {{.Label}}
+
+[Service]
+Type=oneshot
+ExecStart={{.ExecStart}}
+StandardOutput=append:{{.LogPath}}
+StandardError=append:{{.LogPath}}
+`
+
+const timerTemplate = `[Unit]
+Description=aidb {{.Label}} timer
+
+[Timer]
+OnBootSec=1min
+OnUnitActiveSec={{.Seconds}}s
+Unit={{.Unit}}.service
+
+[Install]
+WantedBy=timers.target
+`
+
+// Systemd schedules jobs as systemd --user timer/service pairs
+type Systemd struct {
+	Run Runner
+}
+
+func (s *Systemd) Name() string { return "systemd" }
+
+// unitName turns a label like "com.aidb.backup" into "aidb-backup"
+func unitName(job Job) string {
+	return strings.ReplaceAll(strings.TrimPrefix(job.Label, "com."), ".", "-")
+}
+
+// UnitPaths returns the service and timer unit paths for a job
+func (s *Systemd) UnitPaths(job Job) (service, timer string) {
+	dir := filepath.Join(job.HomeDir, ".config", "systemd", "user")
+	name := unitName(job)
+	return filepath.Join(dir, name+".service"), filepath.Join(dir, name+".timer")
+}
+
+// Render returns the service and timer unit contents for a job
+func (s *Systemd) Render(job Job) (service, timer string, err error) {
+	quoted := make([]string, len(job.Command))
+	for i, arg := range job.Command {
+		quoted[i] = systemdQuote(arg)
+	}
+	data := struct {
+		Job
+		ExecStart string
+		Seconds   int
+		Unit      string
+	}{job, strings.Join(quoted, " "), int(job.Interval.Seconds()), unitName(job)}
+
+	if service, err = render("service", serviceTemplate, data); err != nil {
+		return "", "", err
+	}
+	if timer, err = render("timer", timerTemplate, data); err != nil {
+		return "", "", err
+	}
+	return service, timer, nil
+}
+
+func (s *Systemd) Enable(job Job) error {
+	service, timer, err := s.Render(job)
+	if err != nil {
+		return err
+	}
+	servicePath, timerPath := s.UnitPaths(job)
+	if err := writeFile(servicePath, service); err != nil {
+		return err
+	}
+	if err := writeFile(timerPath, timer); err != nil {
+		return err
+	}
+
+	if _, err := s.Run("", "systemctl", "--user", "daemon-reload"); err != nil {
+		return fmt.Errorf("systemctl daemon-reload failed: %w", err)
+	}
+	if _, err := s.Run("", "systemctl", "--user", "enable", "--now", filepath.Base(timerPath)); err != nil {
+		return fmt.Errorf("failed to enable timer: %w", err)
+	}
+	return nil
+}
+
+func (s *Systemd) Disable(job Job) error {
+	servicePath, timerPath := s.UnitPaths(job)
+	s.Run("", "systemctl", "--user", "disable", "--now", filepath.Base(timerPath)) // Ignore error if not enabled
+	for _, path := range []string{timerPath, servicePath} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", filepath.Base(path), err)
+		}
+	}
+	s.Run("", "systemctl", "--user", "daemon-reload")
+	return nil
+}
+
+func (s *Systemd) Status(job Job) (Status, error) {
+	_, timerPath := s.UnitPaths(job)
+	if _, err := os.Stat(timerPath); os.IsNotExist(err) {
+		return Status{}, nil
+	}
+	out, _ := s.Run("", "systemctl", "--user", "is-active", filepath.Base(timerPath))
+	state := strings.TrimSpace(string(out))
+	return Status{
+		Installed: true,
+		Active:    state == "active",
+		Detail:    timerPath,
+	}, nil
+}
+
+// systemdQuote quotes an ExecStart argument if it contains whitespace or quotes
+func systemdQuote(arg string) string {
+	if !strings.ContainsAny(arg, " \t\"'\\") {
+		return arg
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(arg) + `"`
+}
@@ -0,0 +1,199 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 tuning constants (standard Okapi values)
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Doc holds the term statistics BM25 needs for one file
+type Doc struct {
+	Path   string         `json:"path"`
+	Length int            `json:"length"`
+	Terms  map[string]int `json:"terms"`
+}
+
+// Result is a scored document
+type Result struct {
+	Path  string
+	Score float64
+}
+
+// Tokenize lowercases text and splits it on anything that is not a letter or digit
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// NewDoc computes term frequencies for text
+func NewDoc(path, text string) Doc {
+	tokens := Tokenize(text)
+	terms := make(map[string]int)
+	for _, t := range tokens {
+		terms[t]++
+	}
+	return Doc{Path: path, Length: len(tokens), Terms: terms}
+}
+
+// Rank scores docs against query terms with BM25 and returns matches, best first.
+// The file path is indexed too, so a query for "auth" finds auth.md.
+func Rank(docs []Doc, query []string) []Result {
+	if len(docs) == 0 || len(query) == 0 {
+		return nil
+	}
+
+	total := 0
+	df := make(map[string]int)
+	for _, d := range docs {
+		total += d.Length
+		for _, q := range uniq(query) {
+			if d.Terms[q] > 0 || pathHas(d.Path, q) {
+				df[q]++
+			}
+		}
+	}
+	avgdl := float64(total) / float64(len(docs))
+	if avgdl == 0 {
+		avgdl = 1
+	}
+	n := float64(len(docs))
+
+	var results []Result
+	for _, d := range docs {
+		score := 0.0
+		for _, q := range uniq(query) {
+			tf := float64(d.Terms[q])
+			if pathHas(d.Path, q) {
+				tf++ // a path match counts as one extra occurrence
+			}
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log(1 + (n-float64(df[q])+0.5)/(float64(df[q])+0.5))
+			norm := tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(d.Length)/avgdl))
+			score += idf * norm
+		}
+		if score > 0 {
+			results = append(results, Result{Path: d.Path, Score: score})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Path < results[j].Path
+	})
+	return results
+}
+
+// Snippet returns the line of text with the most query terms, trimmed to about
+// width characters around the first match, and its 1-based line number
+func Snippet(text string, query []string, width int) (string, int) {
+	lines := strings.Split(text, "\n")
+	best, bestLine := -1, 0
+	for i, line := range lines {
+		count := 0
+		for _, tok := range Tokenize(line) {
+			for _, q := range query {
+				if tok == q {
+					count++
+				}
+			}
+		}
+		if count > best {
+			best, bestLine = count, i
+		}
+	}
+	if best <= 0 {
+		return "", 0
+	}
+
+	line := strings.TrimSpace(lines[bestLine])
+	runes := []rune(line)
+	if len(runes) <= width {
+		return line, bestLine + 1
+	}
+
+	// Center the window on the first match
+	lower := strings.ToLower(line)
+	start := 0
+	for _, q := range query {
+		if idx := strings.Index(lower, q); idx >= 0 {
+			start = len([]rune(lower[:idx]))
+			break
+		}
+	}
+	start -= width / 3
+	if start < 0 {
+		start = 0
+	}
+	end := start + width
+	if end > len(runes) {
+		end = len(runes)
+		start = max(0, end-width)
+	}
+
+	snippet := string(runes[start:end])
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet, bestLine + 1
+}
+
+// Highlight wraps every case-insensitive occurrence of the query terms with mark
+func Highlight(text string, query []string, mark func(string) string) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		return text // case mapping changed byte offsets; skip highlighting
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		matched := 0
+		for _, q := range query {
+			if q != "" && strings.HasPrefix(lower[i:], q) && len(q) > matched {
+				matched = len(q)
+			}
+		}
+		if matched > 0 {
+			b.WriteString(mark(text[i : i+matched]))
+			i += matched
+			continue
+		}
+		b.WriteByte(text[i])
+		i++
+	}
+	return b.String()
+}
+
+func pathHas(path, term string) bool {
+	for _, t := range Tokenize(path) {
+		if t == term {
+			return true
+		}
+	}
+	return false
+}
+
+func uniq(terms []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, t := range terms {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRank_OrdersByRelevance(t *testing.T) {
+	docs := []Doc{
+		NewDoc("p/main/deploy.md", "How we deploy: build, push image, roll out."),
+		NewDoc("p/main/auth.md", "Auth tokens expire hourly. Refresh auth tokens via the auth service."),
+		NewDoc("p/main/notes.md", "Misc notes mentioning auth once."),
+	}
+
+	results := Rank(docs, Tokenize("auth tokens"))
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2: %+v", len(results), results)
+	}
+	if results[0].Path != "p/main/auth.md" {
+		t.Errorf("top result = %q, want auth.md", results[0].Path)
+	}
+	if results[0].Score <= results[1].Score {
+		t.Errorf("scores not descending: %+v", results)
+	}
+}
+
+func TestRank_MatchesPath(t *testing.T) {
+	docs := []Doc{NewDoc("p/main/deploy.md", "nothing relevant")}
+	if results := Rank(docs, []string{"deploy"}); len(results) != 1 {
+		t.Errorf("expected path match, got %+v", results)
+	}
+}
+
+func TestSnippetAndHighlight(t *testing.T) {
+	text := "# Title\n\nunrelated line\nThe retry policy uses exponential backoff.\n"
+	snippet, line := Snippet(text, []string{"backoff"}, 100)
+	if line != 4 {
+		t.Errorf("line = %d, want 4", line)
+	}
+	if !strings.Contains(snippet, "exponential backoff") {
+		t.Errorf("snippet = %q", snippet)
+	}
+
+	got := Highlight(snippet, []string{"backoff"}, func(s string) string { return "[" + s + "]" })
+	if !strings.Contains(got, "[backoff]") {
+		t.Errorf("highlight = %q", got)
+	}
+}
@@ -0,0 +1,109 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Watcher reports paths that change anywhere under a directory tree.
+// On Linux it is backed by inotify; elsewhere it polls modification times.
+type Watcher struct {
+	Events chan string
+	Errors chan error
+
+	root string
+	skip func(relPath string) bool
+	done chan struct{}
+	impl backend
+}
+
+// backend is the platform-specific event source
+type backend interface {
+	add(dir string) error
+	run(w *Watcher) // releases backend resources when w.done closes
+	close() error   // releases resources if run was never started
+}
+
+// New starts watching root recursively. skip is called with root-relative
+// paths and may exclude subtrees (e.g. .git) from watching.
+func New(root string, skip func(relPath string) bool) (*Watcher, error) {
+	if skip == nil {
+		skip = func(string) bool { return false }
+	}
+
+	impl, err := newBackend()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		Events: make(chan string, 256),
+		Errors: make(chan error, 16),
+		root:   root,
+		skip:   skip,
+		done:   make(chan struct{}),
+		impl:   impl,
+	}
+
+	if err := w.addTree(root); err != nil {
+		impl.close()
+		return nil, err
+	}
+
+	go impl.run(w)
+	return w, nil
+}
+
+// Close stops watching; the Events channel is closed once the backend exits
+func (w *Watcher) Close() error {
+	select {
+	case <-w.done:
+	default:
+		close(w.done)
+	}
+	return nil
+}
+
+// addTree registers dir and every non-skipped directory beneath it
+func (w *Watcher) addTree(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if w.skipped(path) {
+			return filepath.SkipDir
+		}
+		return w.impl.add(path)
+	})
+}
+
+// skipped reports whether an absolute path falls under an excluded subtree
+func (w *Watcher) skipped(path string) bool {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	return w.skip(rel)
+}
+
+// emit delivers a change without blocking shutdown
+func (w *Watcher) emit(path string) {
+	if w.skipped(path) {
+		return
+	}
+	select {
+	case w.Events <- path:
+	case <-w.done:
+	}
+}
+
+// fail delivers an error, dropping it if nobody is listening
+func (w *Watcher) fail(err error) {
+	select {
+	case w.Errors <- err:
+	default:
+	}
+}
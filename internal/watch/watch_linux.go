@@ -0,0 +1,125 @@
+//go:build linux
+
+package watch
+
+import (
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_MODIFY |
+	unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ATTRIB
+
+// inotify watches each directory with its own watch descriptor
+type inotify struct {
+	fd   int
+	mu   sync.Mutex
+	dirs map[int]string
+}
+
+func newBackend() (backend, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	return &inotify{fd: fd, dirs: make(map[int]string)}, nil
+}
+
+func (n *inotify) add(dir string) error {
+	wd, err := unix.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.dirs[wd] = dir
+	n.mu.Unlock()
+	return nil
+}
+
+func (n *inotify) close() error {
+	return unix.Close(n.fd)
+}
+
+func (n *inotify) run(w *Watcher) {
+	defer close(w.Events)
+	defer n.close()
+
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		// Poll with a timeout so Close is noticed without a pending event
+		fds := []unix.PollFd{{Fd: int32(n.fd), Events: unix.POLLIN}}
+		_, err := unix.Poll(fds, 250)
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+		if err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			w.fail(err)
+			return
+		}
+		if fds[0].Revents&unix.POLLIN == 0 {
+			continue
+		}
+
+		count, err := unix.Read(n.fd, buf)
+		if err != nil {
+			if err == unix.EAGAIN || err == unix.EINTR {
+				continue
+			}
+			w.fail(err)
+			return
+		}
+		n.parse(w, buf[:count])
+	}
+}
+
+// parse walks the packed inotify_event records in buf
+func (n *inotify) parse(w *Watcher, buf []byte) {
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameStart := offset + unix.SizeofInotifyEvent
+		nameEnd := nameStart + int(event.Len)
+		offset = nameEnd
+
+		if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+			w.emit(w.root)
+			continue
+		}
+
+		n.mu.Lock()
+		dir, ok := n.dirs[int(event.Wd)]
+		if event.Mask&unix.IN_IGNORED != 0 {
+			delete(n.dirs, int(event.Wd))
+		}
+		n.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		name := string(buf[nameStart:nameEnd])
+		for len(name) > 0 && name[len(name)-1] == 0 {
+			name = name[:len(name)-1]
+		}
+		if name == "" {
+			continue
+		}
+		path := filepath.Join(dir, name)
+
+		// New directories need their own watches (and may already hold files)
+		if event.Mask&unix.IN_ISDIR != 0 && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+			if !w.skipped(path) {
+				if err := w.addTree(path); err != nil {
+					w.fail(err)
+				}
+			}
+		}
+		w.emit(path)
+	}
+}
@@ -0,0 +1,97 @@
+//go:build !linux
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// pollInterval is how often the tree is rescanned on platforms without inotify
+const pollInterval = time.Second
+
+// poller rescans the tree and diffs size/mtime snapshots
+type poller struct {
+	mu    sync.Mutex
+	roots []string
+}
+
+func newBackend() (backend, error) {
+	return &poller{}, nil
+}
+
+func (p *poller) add(dir string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Only the top-level root is needed; scan walks recursively
+	if len(p.roots) == 0 {
+		p.roots = append(p.roots, dir)
+	}
+	return nil
+}
+
+func (p *poller) close() error {
+	return nil
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func (p *poller) run(w *Watcher) {
+	defer close(w.Events)
+
+	prev := p.scan(w)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		cur := p.scan(w)
+		for path, state := range cur {
+			if old, ok := prev[path]; !ok || old != state {
+				w.emit(path)
+			}
+		}
+		for path := range prev {
+			if _, ok := cur[path]; !ok {
+				w.emit(path)
+			}
+		}
+		prev = cur
+	}
+}
+
+func (p *poller) scan(w *Watcher) map[string]fileState {
+	states := make(map[string]fileState)
+	p.mu.Lock()
+	roots := append([]string(nil), p.roots...)
+	p.mu.Unlock()
+
+	for _, root := range roots {
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if w.skipped(path) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() {
+				states[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+			}
+			return nil
+		})
+	}
+	return states
+}
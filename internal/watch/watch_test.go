@@ -0,0 +1,55 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatcher_ReportsChanges(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := New(root, func(rel string) bool { return strings.HasPrefix(rel, ".git") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Changes inside skipped trees are not reported
+	if err := os.WriteFile(filepath.Join(root, ".git", "index"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files in newly created directories are reported
+	sub := filepath.Join(root, "project", "main")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	target := filepath.Join(sub, "TASK.md")
+	if err := os.WriteFile(target, []byte("# Task"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case path := <-w.Events:
+			if strings.Contains(path, ".git") {
+				t.Fatalf("skipped path reported: %s", path)
+			}
+			if path == target {
+				return
+			}
+		case err := <-w.Errors:
+			t.Fatal(err)
+		case <-deadline:
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}